@@ -0,0 +1,53 @@
+import { NextRequest, NextResponse } from 'next/server';
+
+const GO_BACKEND_URL = process.env.GO_BACKEND_URL || 'http://localhost:8080';
+
+// 房间事件流为长连接，不缓存
+export const dynamic = 'force-dynamic';
+
+export async function GET(
+  request: NextRequest,
+  { params }: { params: Promise<{ code: string }> }
+) {
+  try {
+    const { code } = await params;
+    const url = `${GO_BACKEND_URL}/api/rooms/${encodeURIComponent(code)}/events`;
+
+    console.log('API Route: Streaming room events, proxying to:', url);
+
+    // 透传 Last-Event-ID，EventSource 断线重连时可以续传
+    const headers: HeadersInit = { Accept: 'text/event-stream' };
+    const lastEventId = request.headers.get('last-event-id');
+    if (lastEventId) {
+      headers['Last-Event-ID'] = lastEventId;
+    }
+
+    const response = await fetch(url, {
+      method: 'GET',
+      headers,
+      signal: request.signal,
+    });
+
+    if (!response.ok || !response.body || response.status === 204) {
+      return new NextResponse(response.status === 204 ? null : await response.text(), {
+        status: response.status,
+        headers: { 'Content-Type': response.headers.get('Content-Type') || 'application/json' },
+      });
+    }
+
+    return new NextResponse(response.body, {
+      status: 200,
+      headers: {
+        'Content-Type': 'text/event-stream',
+        'Cache-Control': 'no-cache',
+        Connection: 'keep-alive',
+      },
+    });
+  } catch (error) {
+    console.error('API Route Error:', error);
+    return NextResponse.json(
+      { error: 'Failed to stream room events', details: error instanceof Error ? error.message : 'Unknown error' },
+      { status: 500 }
+    );
+  }
+}
@@ -10,6 +10,7 @@ import { Upload, Download } from 'lucide-react';
 import { WebRTCFileUpload } from '@/components/webrtc/WebRTCFileUpload';
 import { WebRTCFileReceive } from '@/components/webrtc/WebRTCFileReceive';
 import type { FileInfo } from '@/types';
+import { withBasePath } from '@/lib/runtime-config';
 
 export const WebRTCFileTransfer: React.FC = () => {
   const { showToast } = useToast();
@@ -105,7 +106,7 @@ export const WebRTCFileTransfer: React.FC = () => {
       console.log('选中文件数:', selectedFiles.length);
       
       // 创建后端房间 - 简化版本，不发送无用的文件信息
-      const response = await fetch('/api/create-room', {
+      const response = await fetch(withBasePath('/api/create-room'), {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
@@ -1,7 +1,7 @@
 "use client";
 
 import React, { useState, useRef, useEffect, useCallback } from 'react';
-import { useSharedWebRTCManager } from '@/hooks/connection';
+import { useSharedWebRTCManager, useSenderWait } from '@/hooks/connection';
 import { useChatBusiness, type ChatMessage } from '@/hooks/text-transfer';
 import { useURLHandler } from '@/hooks/ui';
 import { Button } from '@/components/ui/button';
@@ -13,7 +13,7 @@ import {
 } from 'lucide-react';
 import RoomInfoDisplay from '@/components/RoomInfoDisplay';
 import { ConnectionStatus } from '@/components/ConnectionStatus';
-import { checkRoomStatus } from '@/lib/room-utils';
+import { withBasePath } from '@/lib/runtime-config';
 
 // ── 单条消息气泡组件 ──
 
@@ -147,6 +147,7 @@ const TypingIndicator: React.FC = () => (
 
 export const WebRTCChat: React.FC = () => {
   const { showToast } = useToast();
+  const { waitForSender, cancelWait } = useSenderWait();
 
   // 模式状态
   const [mode, setMode] = useState<'send' | 'receive'>('send');
@@ -198,7 +199,7 @@ export const WebRTCChat: React.FC = () => {
     if (isCreating) return;
     setIsCreating(true);
     try {
-      const response = await fetch('/api/create-room', {
+      const response = await fetch(withBasePath('/api/create-room'), {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({}),
@@ -225,7 +226,7 @@ export const WebRTCChat: React.FC = () => {
 
     setIsJoining(true);
     try {
-      const result = await checkRoomStatus(finalCode);
+      const result = await waitForSender(finalCode);
       if (!result.success) {
         showToast(result.error || '加入房间失败', 'error');
         return;
@@ -237,11 +238,12 @@ export const WebRTCChat: React.FC = () => {
     } finally {
       setIsJoining(false);
     }
-  }, [inputCode, isJoining, connection, showToast]);
+  }, [inputCode, isJoining, connection, showToast, waitForSender]);
 
   // ── 重新开始 ──
 
   const restart = useCallback(() => {
+    cancelWait();
     chat.clearMessages();
     connection.disconnect();
     setRoomCode('');
@@ -250,7 +252,7 @@ export const WebRTCChat: React.FC = () => {
     setPreviewImage(null);
     hasAutoJoinedRef.current = false;
     clearURLParams();
-  }, [chat, connection, clearURLParams]);
+  }, [chat, connection, clearURLParams, cancelWait]);
 
   // ── 发送消息 ──
 
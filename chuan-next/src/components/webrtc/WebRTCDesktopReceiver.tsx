@@ -10,7 +10,8 @@ import DesktopViewer from '@/components/DesktopViewer';
 import { ConnectionStatus } from '@/components/ConnectionStatus';
 import VoiceChatPanel from '@/components/VoiceChatPanel';
 import { ConfirmDialog } from '@/components/ui/confirm-dialog';
-import { validateRoomCode, checkRoomStatus, handleNetworkError } from '@/lib/room-utils';
+import { validateRoomCode, handleNetworkError } from '@/lib/room-utils';
+import { useSenderWait } from '@/hooks/connection';
 
 interface WebRTCDesktopReceiverProps {
   className?: string;
@@ -25,6 +26,7 @@ export default function WebRTCDesktopReceiver({ className, initialCode, onConnec
   const [showPeerLeftDialog, setShowPeerLeftDialog] = useState(false); // 发送方退出提示
   const hasTriedAutoJoin = React.useRef(false); // 添加 ref 来跟踪是否已尝试自动加入
   const { showToast } = useToast();
+  const { waitForSender } = useSenderWait();
 
   // 使用桌面共享业务逻辑
   const desktopShare = useDesktopShareBusiness();
@@ -58,7 +60,7 @@ export default function WebRTCDesktopReceiver({ className, initialCode, onConnec
     try {
       console.log('[DesktopShareReceiver] 开始验证房间状态...');
       
-      const result = await checkRoomStatus(trimmedCode);
+      const result = await waitForSender(trimmedCode);
       if (!result.success) {
         showToast(result.error || '房间验证失败', "error");
         return;
@@ -79,7 +81,7 @@ export default function WebRTCDesktopReceiver({ className, initialCode, onConnec
       setIsLoading(false);
       setIsJoiningRoom(false);
     }
-  }, [desktopShare, inputCode, isJoiningRoom, showToast]);
+  }, [desktopShare, inputCode, isJoiningRoom, showToast, waitForSender]);
 
   // 停止观看
   const handleStopViewing = useCallback(async () => {
@@ -143,7 +145,7 @@ export default function WebRTCDesktopReceiver({ className, initialCode, onConnec
         
         try {
           console.log('[WebRTCDesktopReceiver] 验证房间状态...');
-          const result = await checkRoomStatus(trimmedCode);
+          const result = await waitForSender(trimmedCode);
           
           if (!result.success) {
             showToast(result.error || '房间验证失败', "error");
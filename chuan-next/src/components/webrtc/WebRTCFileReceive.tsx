@@ -6,7 +6,7 @@ import { Input } from '@/components/ui/input';
 import { Download, FileText, Image, Video, Music, Archive } from 'lucide-react';
 import { useToast } from '@/components/ui/toast-simple';
 import { ConnectionStatus } from '@/components/ConnectionStatus';
-import { checkRoomStatus } from '@/lib/room-utils';
+import { useSenderWait } from '@/hooks/connection';
 import type { FileInfo } from '@/types';
 
 const getFileIcon = (mimeType: string) => {
@@ -75,6 +75,7 @@ export function WebRTCFileReceive({
   const [pickupCode, setPickupCode] = useState('');
   const [isValidating, setIsValidating] = useState(false);
   const { showToast } = useToast();
+  const { waitForSender } = useSenderWait();
 
   // 使用传入的取件码或本地状态的取件码
   const displayPickupCode = propPickupCode || pickupCode;
@@ -85,7 +86,7 @@ export function WebRTCFileReceive({
       setIsValidating(true);
       console.log('开始验证取件码:', code);
       
-      const result = await checkRoomStatus(code);
+      const result = await waitForSender(code);
       
       if (!result.success) {
         showToast(result.error || '取件码验证失败', 'error');
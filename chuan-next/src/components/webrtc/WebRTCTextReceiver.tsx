@@ -1,7 +1,7 @@
 "use client";
 
 import React, { useState, useRef, useEffect, useCallback } from 'react';
-import { useSharedWebRTCManager } from '@/hooks/connection';
+import { useSharedWebRTCManager, useSenderWait } from '@/hooks/connection';
 import { useTextTransferBusiness } from '@/hooks/text-transfer';
 import { useFileTransferBusiness } from '@/hooks/file-transfer';
 import { Button } from '@/components/ui/button';
@@ -9,7 +9,6 @@ import { Input } from '@/components/ui/input';
 import { useToast } from '@/components/ui/toast-simple';
 import { MessageSquare, Image, Download } from 'lucide-react';
 import { ConnectionStatus } from '@/components/ConnectionStatus';
-import { checkRoomStatus } from '@/lib/room-utils';
 
 interface WebRTCTextReceiverProps {
   initialCode?: string;
@@ -25,6 +24,7 @@ export const WebRTCTextReceiver: React.FC<WebRTCTextReceiverProps> = ({
   onConnectionChange
 }) => {
   const { showToast } = useToast();
+  const { waitForSender } = useSenderWait();
 
   // 状态管理
   const [pickupCode, setPickupCode] = useState('');
@@ -137,7 +137,7 @@ export const WebRTCTextReceiver: React.FC<WebRTCTextReceiverProps> = ({
     try {
       console.log('=== 开始加入房间 ===', code);
       
-      const result = await checkRoomStatus(code);
+      const result = await waitForSender(code);
       if (!result.success) {
         showToast(result.error || '加入房间失败', "error");
         return;
@@ -156,7 +156,7 @@ export const WebRTCTextReceiver: React.FC<WebRTCTextReceiverProps> = ({
     } finally {
       setIsValidating(false);
     }
-  }, [connectAll, showToast]);
+  }, [connectAll, showToast, waitForSender]);
 
   // 复制文本到剪贴板
   const copyToClipboard = async (text: string) => {
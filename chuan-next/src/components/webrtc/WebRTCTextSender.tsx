@@ -9,6 +9,7 @@ import { useToast } from '@/components/ui/toast-simple';
 import { MessageSquare, Image, Send, Copy } from 'lucide-react';
 import RoomInfoDisplay from '@/components/RoomInfoDisplay';
 import { ConnectionStatus } from '@/components/ConnectionStatus';
+import { withBasePath } from '@/lib/runtime-config';
 
 interface WebRTCTextSenderProps {
   onRestart?: () => void;
@@ -116,7 +117,7 @@ export const WebRTCTextSender: React.FC<WebRTCTextSenderProps> = ({ onRestart, o
       const currentText = textInput.trim();
       
       // 创建后端房间 - 简化版本，不发送无用的文本信息
-      const response = await fetch('/api/create-room', {
+      const response = await fetch(withBasePath('/api/create-room'), {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
@@ -1,5 +1,6 @@
 // 连接相关的 hooks
 export { useRoomConnection } from './useRoomConnection';
+export { useSenderWait } from './useSenderWait';
 export { useSharedWebRTCManager } from './useSharedWebRTCManager';
 export { useWebRTCSupport } from './useWebRTCSupport';
 
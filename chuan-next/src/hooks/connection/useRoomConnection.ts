@@ -1,6 +1,7 @@
 import { useState, useCallback } from 'react';
 import { useToast } from '@/components/ui/toast-simple';
-import { validateRoomCode, checkRoomStatus } from '@/lib/room-utils';
+import { validateRoomCode } from '@/lib/room-utils';
+import { useSenderWait } from './useSenderWait';
 
 interface UseRoomConnectionProps {
   connect: (code: string, role: 'sender' | 'receiver') => void;
@@ -11,6 +12,7 @@ interface UseRoomConnectionProps {
 export const useRoomConnection = ({ connect, isConnecting, isConnected }: UseRoomConnectionProps) => {
   const { showToast } = useToast();
   const [isJoiningRoom, setIsJoiningRoom] = useState(false);
+  const { waitForSender } = useSenderWait();
 
   // 加入房间 (接收模式)
   const joinRoom = useCallback(async (code: string) => {
@@ -34,7 +36,7 @@ export const useRoomConnection = ({ connect, isConnecting, isConnected }: UseRoo
     
     try {
       console.log('检查房间状态...');
-      const result = await checkRoomStatus(code.trim());
+      const result = await waitForSender(code.trim());
       
       if (!result.success) {
         showToast(result.error || '检查房间状态失败', "error");
@@ -52,7 +54,7 @@ export const useRoomConnection = ({ connect, isConnecting, isConnected }: UseRoo
     } finally {
       setIsJoiningRoom(false);
     }
-  }, [isConnecting, isConnected, isJoiningRoom, showToast, connect]);
+  }, [isConnecting, isConnected, isJoiningRoom, showToast, connect, waitForSender]);
 
   return {
     joinRoom,
@@ -0,0 +1,37 @@
+import { useCallback, useEffect, useRef } from 'react';
+import { useToast } from '@/components/ui/toast-simple';
+import { waitForSender as waitForSenderOnline } from '@/lib/room-utils';
+
+/**
+ * 加入房间前检查房间状态，发送方不在线时通过房间事件流等待其上线
+ * 重复调用或组件卸载时取消上一次等待
+ */
+export const useSenderWait = () => {
+  const { showToast } = useToast();
+  const controllerRef = useRef<AbortController | null>(null);
+
+  const cancelWait = useCallback(() => {
+    controllerRef.current?.abort();
+    controllerRef.current = null;
+  }, []);
+
+  const waitForSender = useCallback(async (code: string) => {
+    cancelWait();
+    const controller = new AbortController();
+    controllerRef.current = controller;
+    try {
+      return await waitForSenderOnline(code, {
+        signal: controller.signal,
+        onWaiting: () => showToast('发送方暂未上线，上线后将自动连接', 'info'),
+      });
+    } finally {
+      if (controllerRef.current === controller) {
+        controllerRef.current = null;
+      }
+    }
+  }, [cancelWait, showToast]);
+
+  useEffect(() => cancelWait, [cancelWait]);
+
+  return { waitForSender, cancelWait };
+};
@@ -784,7 +784,7 @@ export function useWebRTCConnectionCore(
               stateManager.updateState({
                 isPeerConnected: false,
                 isConnected: false,
-                error: '对方已退出共享',
+                error: message.payload?.reason === 'closed_by_admin' ? '房间已被管理员关闭' : '对方已退出共享',
                 canRetry: false
               });
               // 清理P2P连接
@@ -1,6 +1,7 @@
 import { useState, useRef, useCallback, useEffect } from 'react';
 import type { WebRTCConnection } from '../connection/types';
 import { useSharedWebRTCManager } from '../connection/useSharedWebRTCManager';
+import { withBasePath } from '@/lib/runtime-config';
 
 interface DesktopShareState {
   isSharing: boolean;
@@ -219,7 +220,7 @@ export function useDesktopShareBusiness(externalConnection?: WebRTCConnection) {
 
   // 创建房间 - 统一使用后端生成房间码
   const createRoomFromBackend = useCallback(async (): Promise<string> => {
-    const response = await fetch('/api/create-room', {
+    const response = await fetch(withBasePath('/api/create-room'), {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
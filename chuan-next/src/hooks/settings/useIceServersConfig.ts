@@ -1,4 +1,5 @@
 import { useState, useEffect, useCallback } from 'react';
+import { getRuntimeConfig } from '@/lib/runtime-config';
 
 export interface IceServerConfig {
   id: string;
@@ -50,6 +51,23 @@ const DEFAULT_ICE_SERVERS: IceServerConfig[] = [
 
 const STORAGE_KEY = 'webrtc-ice-servers-config-090901';
 
+// 默认服务器：优先使用服务器下发的 ICE 服务器，未配置时使用内置列表
+function getDefaultIceServers(): IceServerConfig[] {
+  const serverProvided = getRuntimeConfig()?.ice_servers;
+  if (!serverProvided || serverProvided.length === 0) {
+    return DEFAULT_ICE_SERVERS;
+  }
+  return serverProvided.map((server, index) => ({
+    id: `server-${index}`,
+    urls: server.urls,
+    username: server.username,
+    credential: server.credential,
+    type: /^turns?:/i.test(server.urls) ? 'turn' : 'stun',
+    enabled: true,
+    isDefault: true,
+  }));
+}
+
 export function useIceServersConfig() {
   const [iceServers, setIceServers] = useState<IceServerConfig[]>([]);
   const [isLoading, setIsLoading] = useState(true);
@@ -64,15 +82,15 @@ export function useIceServersConfig() {
         const serversWithDefaults = parsed.map((server: any) => ({
           ...server,
           isDefault: server.isDefault !== undefined ? server.isDefault : 
-            DEFAULT_ICE_SERVERS.some(defaultServer => defaultServer.id === server.id)
+            getDefaultIceServers().some(defaultServer => defaultServer.id === server.id)
         }));
         setIceServers(serversWithDefaults);
       } else {
-        setIceServers(DEFAULT_ICE_SERVERS);
+        setIceServers(getDefaultIceServers());
       }
     } catch (error) {
       console.error('加载ICE服务器配置失败:', error);
-      setIceServers(DEFAULT_ICE_SERVERS);
+      setIceServers(getDefaultIceServers());
     } finally {
       setIsLoading(false);
     }
@@ -121,7 +139,7 @@ export function useIceServersConfig() {
 
   // 恢复默认配置
   const resetToDefault = useCallback(() => {
-    saveConfig(DEFAULT_ICE_SERVERS);
+    saveConfig(getDefaultIceServers());
   }, [saveConfig]);
 
   // 获取WebRTC格式的配置
@@ -203,7 +221,7 @@ export function getIceServersConfig(): RTCIceServer[] {
     const saved = localStorage.getItem(STORAGE_KEY);
     if (!saved) {
       // 返回默认配置的WebRTC格式
-      return DEFAULT_ICE_SERVERS
+      return getDefaultIceServers()
         .filter(server => server.enabled)
         .map(server => {
           const rtcServer: RTCIceServer = {
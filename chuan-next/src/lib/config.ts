@@ -2,6 +2,8 @@
  * 环境配置管理
  */
 
+import { withBasePath } from './runtime-config';
+
 // 安全的环境变量访问
 const getEnv = (key: string, defaultValue: string = '') => {
   try {
@@ -36,9 +38,9 @@ const getCurrentWsUrl = () => {
       return `ws://${window.location.hostname}:8080`;
     }
     
-    // 生产模式或通过 Go 服务器访问：使用当前域名和端口
+    // 生产模式或通过 Go 服务器访问：使用当前域名和端口，部署在子路径下时加上路径前缀
     const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-    return `${protocol}//${window.location.host}`;
+    return `${protocol}//${window.location.host}${withBasePath('')}`;
   }
   // 服务器端返回空字符串，强制在客户端计算
   return '';
@@ -107,9 +109,11 @@ export function getApiUrl(path: string): string {
  */
 export function getDirectBackendUrl(path: string): string {
   // 实时获取当前域名（支持动态域名）
-  const baseUrl = (getEnv('NEXT_PUBLIC_BACKEND_URL') || getCurrentBaseUrl()).replace(/\/$/, '')
+  const envUrl = getEnv('NEXT_PUBLIC_BACKEND_URL')
+  const baseUrl = (envUrl || getCurrentBaseUrl()).replace(/\/$/, '')
   const apiPath = path.startsWith('/') ? path : `/${path}`
-  return `${baseUrl}${apiPath}`
+  // 使用当前域名时，部署在子路径下需要加上路径前缀
+  return envUrl ? `${baseUrl}${apiPath}` : `${baseUrl}${withBasePath(apiPath)}`
 }
 
 /**
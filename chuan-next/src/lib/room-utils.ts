@@ -3,10 +3,14 @@
  * 统一房间代码验证和房间状态检查逻辑
  */
 
+import type { RoomEvent, RoomEventType } from '@/types';
+import { withBasePath } from '@/lib/runtime-config';
+
 export interface RoomValidationResult {
   success: boolean;
   error?: string;
   data?: Record<string, unknown>;
+  senderOffline?: boolean; // 房间有效但发送方不在线，可以订阅房间事件等待
 }
 
 /**
@@ -26,7 +30,7 @@ export function validateRoomCode(code: string): string | null {
  */
 export async function checkRoomStatus(code: string): Promise<RoomValidationResult> {
   try {
-    const response = await fetch(`/api/room-info?code=${code}`);
+    const response = await fetch(withBasePath(`/api/room-info?code=${code}`));
 
     if (!response.ok) {
       return {
@@ -62,6 +66,7 @@ export async function checkRoomStatus(code: string): Promise<RoomValidationResul
       return {
         success: false,
         error: '发送方不在线，请确认取件码是否正确或联系发送方',
+        senderOffline: true,
       };
     }
 
@@ -75,6 +80,126 @@ export async function checkRoomStatus(code: string): Promise<RoomValidationResul
   }
 }
 
+const ROOM_EVENT_TYPES: RoomEventType[] = [
+  'created',
+  'sender_online',
+  'sender_offline',
+  'receiver_online',
+  'receiver_offline',
+  'relay_started',
+  'relay_ended',
+  'expired',
+];
+
+/**
+ * 订阅房间状态变化（SSE），代替轮询 /api/room-info
+ * 断线后 EventSource 自动携带 Last-Event-ID 续传；收到 expired 后自动结束订阅
+ * 返回取消订阅函数
+ */
+export function subscribeRoomEvents(
+  code: string,
+  onEvent: (event: RoomEvent) => void,
+  onError?: () => void,
+): () => void {
+  const source = new EventSource(withBasePath(`/api/rooms/${encodeURIComponent(code)}/events`));
+
+  const listener = (message: MessageEvent) => {
+    try {
+      const event = JSON.parse(message.data) as RoomEvent;
+      onEvent(event);
+      if (event.type === 'expired') {
+        source.close();
+      }
+    } catch (error) {
+      console.error('解析房间事件失败:', error);
+    }
+  };
+  ROOM_EVENT_TYPES.forEach((type) => source.addEventListener(type, listener));
+
+  // 房间不存在（404）或已结束（204）时 EventSource 不再重连
+  source.onerror = () => {
+    if (source.readyState === EventSource.CLOSED) {
+      onError?.();
+    }
+  };
+
+  return () => source.close();
+}
+
+export interface WaitForSenderOptions {
+  signal?: AbortSignal;
+  onWaiting?: () => void; // 发送方不在线、开始等待时调用一次
+}
+
+/**
+ * 检查房间状态，发送方不在线时订阅房间事件等待其上线，而不是直接失败
+ * 收到 sender_online 后重新检查一次（补发的历史事件也会触发，以最新状态为准）；
+ * 房间过期、事件流结束或 signal 中止时返回失败
+ */
+export function waitForSender(code: string, options: WaitForSenderOptions = {}): Promise<RoomValidationResult> {
+  const { signal, onWaiting } = options;
+  const cancelled: RoomValidationResult = { success: false, error: '已取消等待发送方' };
+  if (signal?.aborted) {
+    return Promise.resolve(cancelled);
+  }
+
+  return new Promise((resolve) => {
+    let settled = false;
+    let waiting = false;
+    let checking = false;
+    let streamClosed = false;
+    let unsubscribe = () => {};
+
+    const finish = (result: RoomValidationResult) => {
+      if (settled) return;
+      settled = true;
+      unsubscribe();
+      signal?.removeEventListener('abort', onAbort);
+      resolve(result);
+    };
+    const onAbort = () => finish(cancelled);
+    signal?.addEventListener('abort', onAbort);
+
+    const check = async () => {
+      if (checking || settled) return;
+      checking = true;
+      const result = await checkRoomStatus(code);
+      checking = false;
+      if (!result.senderOffline) {
+        finish(result);
+        return;
+      }
+      if (streamClosed) {
+        finish({ success: false, error: '房间已结束，请联系发送方重新创建' });
+        return;
+      }
+      if (!waiting) {
+        waiting = true;
+        onWaiting?.();
+      }
+    };
+
+    // 先订阅再检查，检查期间上线的发送方不会被错过
+    unsubscribe = subscribeRoomEvents(
+      code,
+      (event) => {
+        if (event.type === 'sender_online') {
+          check();
+        } else if (event.type === 'expired') {
+          finish({ success: false, error: '房间已过期，请联系发送方重新创建' });
+        }
+      },
+      () => {
+        streamClosed = true;
+        if (waiting) {
+          finish({ success: false, error: '房间已结束，请联系发送方重新创建' });
+        }
+      },
+    );
+    check();
+  });
+}
+
 /**
  * 网络错误统一处理
  */
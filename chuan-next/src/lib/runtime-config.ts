@@ -0,0 +1,46 @@
+/**
+ * 运行时配置
+ * Go 服务器把配置注入到页面的 window.__CHUAN_CONFIG__（内容同 /api/client-config），
+ * 修改 STUN 服务器、功能开关等无需重新构建前端
+ */
+
+export interface RuntimeIceServer {
+  urls: string;
+  username?: string;
+  credential?: string;
+}
+
+export interface RuntimeConfig {
+  ice_servers?: RuntimeIceServer[];
+  features: {
+    relay: boolean;
+    desktop_share: boolean;
+    max_file_size: number; // 0 表示不限制
+  };
+  branding: {
+    name?: string;
+    logo_url?: string;
+  };
+  public_url?: string;
+  base_path?: string; // 部署在子路径下时的路径前缀，如 /tools/chuan
+}
+
+declare global {
+  interface Window {
+    __CHUAN_CONFIG__?: RuntimeConfig;
+  }
+}
+
+// 获取注入的运行时配置，通过 Next.js 开发服务器访问或服务器关闭注入时返回 undefined
+export function getRuntimeConfig(): RuntimeConfig | undefined {
+  if (typeof window === 'undefined') {
+    return undefined;
+  }
+  return window.__CHUAN_CONFIG__;
+}
+
+// 为以 / 开头的接口路径加上服务器部署的路径前缀
+export function withBasePath(path: string): string {
+  const basePath = getRuntimeConfig()?.base_path ?? '';
+  return `${basePath}${path}`;
+}
@@ -21,6 +21,7 @@ export const apiRoutes = [
   '/api/create-text-room',
   '/api/get-text-content',
   '/api/room-info',
+  '/api/rooms/[code]/events',
   '/api/room-status',
   '/api/update-files',
 ]
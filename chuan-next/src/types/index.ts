@@ -34,3 +34,22 @@ export interface RoomStatus {
   }[];
   created_at: string;
 }
+
+// 房间事件流（GET /api/rooms/{code}/events）推送的状态变化
+export type RoomEventType =
+  | 'created'
+  | 'sender_online'
+  | 'sender_offline'
+  | 'receiver_online'
+  | 'receiver_offline'
+  | 'relay_started'
+  | 'relay_ended'
+  | 'expired';
+
+export interface RoomEvent {
+  id: number;
+  type: RoomEventType;
+  room: string;
+  time: string;
+  data?: Record<string, unknown>;
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"chuan/internal/api"
+	"chuan/internal/i18n"
+)
+
+// adminCookieName 管理面板登录后保存的会话 Cookie
+const adminCookieName = "chuan_admin"
+
+// adminAuth 管理接口的令牌校验中间件，令牌可热更新；未配置令牌时管理接口不可用
+type adminAuth struct {
+	token atomic.Pointer[string]
+}
+
+func newAdminAuth(token string) *adminAuth {
+	a := &adminAuth{}
+	a.Update(token)
+	return a
+}
+
+// Update 替换管理令牌
+func (a *adminAuth) Update(token string) {
+	a.token.Store(&token)
+}
+
+// Handler 校验 Authorization: Bearer <token>
+// GET 请求也接受管理面板登录后的 Cookie，修改类请求只接受 Bearer 令牌，避免跨站请求伪造
+func (a *adminAuth) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := *a.token.Load()
+		if token == "" {
+			api.WriteError(w, r, http.StatusNotFound, api.CodeAdminDisabled, nil)
+			return
+		}
+
+		if !a.bearerValid(r, token) && !(r.Method == http.MethodGet && a.cookieValid(r, token)) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			api.WriteError(w, r, http.StatusUnauthorized, api.CodeUnauthorized, nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Dashboard 已登录时显示管理面板，否则显示登录页
+func (a *adminAuth) Dashboard(dashboard, login http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := *a.token.Load()
+		if token == "" {
+			http.Error(w, i18n.T(api.Language(w, r), "error."+api.CodeAdminDisabled), http.StatusNotFound)
+			return
+		}
+		if a.cookieValid(r, token) {
+			dashboard(w, r)
+		} else {
+			login(w, r)
+		}
+	}
+}
+
+// Login 校验表单提交的令牌，成功后写入会话 Cookie
+func (a *adminAuth) Login(w http.ResponseWriter, r *http.Request) {
+	token := *a.token.Load()
+	if token == "" {
+		http.Error(w, i18n.T(api.Language(w, r), "error."+api.CodeAdminDisabled), http.StatusNotFound)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 4096)
+	provided := r.PostFormValue("token")
+	if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+		http.Redirect(w, r, api.BasePath(r)+"/admin?error=1", http.StatusSeeOther)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminCookieName,
+		Value:    adminSessionValue(token),
+		Path:     api.BasePath(r) + "/admin",
+		HttpOnly: true,
+		Secure:   api.Scheme(r) == "https",
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, api.BasePath(r)+"/admin", http.StatusSeeOther)
+}
+
+// Logout 清除会话 Cookie
+func (a *adminAuth) Logout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminCookieName,
+		Value:    "",
+		Path:     api.BasePath(r) + "/admin",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   api.Scheme(r) == "https",
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, api.BasePath(r)+"/admin", http.StatusSeeOther)
+}
+
+func (a *adminAuth) bearerValid(r *http.Request, token string) bool {
+	provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+func (a *adminAuth) cookieValid(r *http.Request, token string) bool {
+	cookie, err := r.Cookie(adminCookieName)
+	return err == nil && subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(adminSessionValue(token))) == 1
+}
+
+// adminSessionValue 由管理令牌派生会话 Cookie 的值，令牌更换后旧 Cookie 随即失效
+func adminSessionValue(token string) string {
+	sum := sha256.Sum256([]byte("chuan-admin:" + token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"chuan/internal/api"
+)
+
+// TestAdminAuthErrors 未启用和未授权时返回统一的错误格式，修改类请求不接受 Cookie
+func TestAdminAuthErrors(t *testing.T) {
+	const token = "0123456789abcdef"
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	cookie := &http.Cookie{Name: adminCookieName, Value: adminSessionValue(token)}
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		auth   string
+		cookie bool
+		status int
+		code   string
+	}{
+		{"未配置令牌", "", http.MethodGet, "Bearer " + token, false, http.StatusNotFound, api.CodeAdminDisabled},
+		{"缺少令牌", token, http.MethodGet, "", false, http.StatusUnauthorized, api.CodeUnauthorized},
+		{"令牌错误", token, http.MethodGet, "Bearer wrong-token-123456", false, http.StatusUnauthorized, api.CodeUnauthorized},
+		{"修改类请求只接受 Bearer", token, http.MethodDelete, "", true, http.StatusUnauthorized, api.CodeUnauthorized},
+		{"Bearer 令牌", token, http.MethodDelete, "Bearer " + token, false, http.StatusOK, ""},
+		{"GET 请求接受 Cookie", token, http.MethodGet, "", true, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/admin/api/rooms?lang=en", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			if tt.cookie {
+				req.AddCookie(cookie)
+			}
+			rec := httptest.NewRecorder()
+			newAdminAuth(tt.token).Handler(ok).ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("状态码 = %d, 期望 %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.code == "" {
+				return
+			}
+			var body api.ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error.Code != tt.code || body.Error.Message == "" {
+				t.Fatalf("错误响应 = %s, 期望错误码 %s", rec.Body, tt.code)
+			}
+			if rec.Header().Get("Content-Language") != "en" {
+				t.Errorf("应按 lang 参数返回英文提示: %s", rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"chuan/internal/audit"
+)
+
+// runAuditCommand 处理 audit 子命令
+// audit verify 按时间顺序校验审计日志（含已轮转的文件）的哈希链，未指定 -file 时使用配置中的 audit.file
+func runAuditCommand(args []string) error {
+	if len(args) == 0 || args[0] != "verify" {
+		return fmt.Errorf("用法: audit verify [-file path] [参数]")
+	}
+	args = args[1:]
+
+	// 取出 -file，其余参数交给 loadConfig
+	var path string
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !strings.HasPrefix(args[i], "-") || name != "file" {
+			rest = append(rest, args[i])
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return fmt.Errorf("-file 缺少参数")
+			}
+			i++
+			value = args[i]
+		}
+		path = value
+	}
+
+	if path == "" {
+		config, err := loadConfig(rest)
+		if err != nil {
+			return err
+		}
+		path = config.Audit.File
+	}
+	if path == "" {
+		return fmt.Errorf("未配置审计日志文件，请通过 -file 或 AUDIT_FILE 指定")
+	}
+
+	files, err := audit.Files(path)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("审计日志不存在: %s", path)
+	}
+	count, err := audit.Verify(files...)
+	if err != nil {
+		return fmt.Errorf("❌ 审计日志校验失败 (已通过 %d 条): %w", count, err)
+	}
+	fmt.Printf("✅ 审计日志校验通过: %d 个文件, %d 条记录\n", len(files), count)
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+
+	"chuan/internal/api"
+	"chuan/internal/auth"
+	"chuan/internal/logging"
+)
+
+// roomAuth 创建/加入房间的身份验证中间件，配置可热更新
+type roomAuth struct {
+	state atomic.Pointer[roomAuthState]
+}
+
+type roomAuthState struct {
+	authenticator *auth.Authenticator
+	createRoom    bool
+	join          string
+}
+
+func newRoomAuth(config *Config) *roomAuth {
+	a := &roomAuth{}
+	a.Update(config)
+	return a
+}
+
+// Update 应用新的认证配置，配置已通过 Validate 校验
+// 重新读取 htpasswd 文件失败时保留原有配置
+func (a *roomAuth) Update(config *Config) {
+	authenticator, err := auth.New(config.Auth.authConfig())
+	if err != nil {
+		logging.Errorf("❌ 加载身份验证配置失败，保留原配置: %v", err)
+		return
+	}
+	a.state.Store(&roomAuthState{
+		authenticator: authenticator,
+		createRoom:    config.Auth.CreateRoom,
+		join:          config.Auth.Join,
+	})
+}
+
+// RequireCreate 按 auth.create_room 要求创建房间的请求通过身份验证
+func (a *roomAuth) RequireCreate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := a.state.Load()
+		a.serve(w, r, next, state, state.createRoom)
+	})
+}
+
+// RequireJoin 按 auth.join 要求加入房间（信令、SSE、中继连接）的请求通过身份验证，角色取自 role 查询参数
+func (a *roomAuth) RequireJoin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := a.state.Load()
+		required := state.join == joinAll || (state.join == joinSender && r.URL.Query().Get("role") != "receiver")
+		a.serve(w, r, next, state, required)
+	})
+}
+
+// serve 校验凭据并把调用方存入请求上下文
+// 不要求认证时，携带有效凭据的请求仍会记录调用方，凭据无效则忽略
+func (a *roomAuth) serve(w http.ResponseWriter, r *http.Request, next http.Handler, state *roomAuthState, required bool) {
+	if !state.authenticator.Enabled() {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	principal, err := state.authenticator.Authenticate(r)
+	if err != nil {
+		if !required {
+			next.ServeHTTP(w, r)
+			return
+		}
+		code := api.CodeInvalidCredentials
+		if errors.Is(err, auth.ErrNoCredentials) {
+			code = api.CodeUnauthorized
+		} else if !errors.Is(err, auth.ErrInvalidCredentials) {
+			logging.Errorf("❌ 身份验证出错: %v", err)
+		}
+		w.Header().Set("WWW-Authenticate", state.authenticator.Challenge())
+		if api.IsVersioned(r.Context()) {
+			api.WriteError(w, r, http.StatusUnauthorized, code, nil)
+			return
+		}
+		api.WriteLegacyError(w, r, http.StatusUnauthorized, code)
+		return
+	}
+
+	logging.Debugf("🔐 身份验证通过: %s (%s) %s %s", principal.Subject, principal.Method, r.Method, r.URL.Path)
+	next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"chuan/internal/api"
+	"chuan/internal/logging"
+)
+
+// basePathPattern 路径前缀：以 / 开头的一段或多段，只包含 URL 中无需转义的字符
+var basePathPattern = regexp.MustCompile(`^(/[A-Za-z0-9._~-]+)+$`)
+
+// validBasePath 校验路径前缀，不允许 . 和 .. 路径段
+func validBasePath(prefix string) bool {
+	if !basePathPattern.MatchString(prefix) {
+		return false
+	}
+	for _, segment := range strings.Split(prefix[1:], "/") {
+		if segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// basePath 返回规范化的部署路径前缀，部署在根路径时为空字符串
+func (c ServerConfig) basePath() string {
+	return strings.TrimRight(c.BasePath, "/")
+}
+
+// withBasePath 子路径部署：只处理 basePath 下的请求，去掉前缀后交给 next
+// 对外的路径前缀（反向代理去掉的 X-Forwarded-Prefix 加上 basePath）记录到请求上下文，用于生成链接、重定向地址和改写前端页面
+func withBasePath(basePath string, next http.Handler) http.Handler {
+	if basePath != "" {
+		next = http.StripPrefix(basePath, next)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 同一地址经过不同前缀的代理访问时，页面和重定向地址不同
+		w.Header().Add("Vary", "X-Forwarded-Prefix")
+		prefix := forwardedPrefix(r) + basePath
+
+		if basePath != "" {
+			rest, ok := strings.CutPrefix(r.URL.Path, basePath)
+			switch {
+			case (ok && rest == "") || r.URL.Path == "/":
+				// 补全末尾的 /，根路径跳转到部署路径
+				target := prefix + "/"
+				if r.URL.RawQuery != "" {
+					target += "?" + r.URL.RawQuery
+				}
+				http.Redirect(w, r, target, http.StatusFound)
+				return
+			case !ok || rest[0] != '/':
+				http.NotFound(w, r)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, api.WithBasePath(r, prefix))
+	})
+}
+
+// forwardedPrefix 返回反向代理通过 X-Forwarded-Prefix 告知的、转发前去掉的路径前缀，无效时忽略
+// 来自不可信地址的请求头已由 trustedProxies 删除
+func forwardedPrefix(r *http.Request) string {
+	value := r.Header.Get("X-Forwarded-Prefix")
+	if value == "" {
+		return ""
+	}
+	// 经过多层代理时取第一个值
+	value, _, _ = strings.Cut(value, ",")
+	prefix := strings.TrimRight(strings.TrimSpace(value), "/")
+	if prefix == "" {
+		return ""
+	}
+	if !validBasePath(prefix) {
+		logging.Debugf("忽略无效的 X-Forwarded-Prefix: %q", value)
+		return ""
+	}
+	return prefix
+}
@@ -2,22 +2,602 @@ package main
 
 import (
 	"bufio"
-	"flag"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
-	"strconv"
 	"strings"
+	"time"
+
+	"chuan/internal/accounting"
+	"chuan/internal/audit"
+	"chuan/internal/auth"
+	"chuan/internal/events"
+	"chuan/internal/logging"
+	"chuan/internal/services"
+	"chuan/internal/web"
+	"chuan/internal/webhook"
 )
 
 // Config 应用配置结构
+// 可以来自 YAML/TOML 配置文件、.chuan.env、环境变量和命令行参数，优先级见 showHelp
 type Config struct {
-	Port        int
-	FrontendDir string
+	Server       ServerConfig       `yaml:"server" toml:"server"`
+	WebSocket    WebSocketConfig    `yaml:"websocket" toml:"websocket"`
+	Rooms        RoomsConfig        `yaml:"rooms" toml:"rooms"`
+	Relay        RelayConfig        `yaml:"relay" toml:"relay"`
+	Origins      OriginsConfig      `yaml:"origins" toml:"origins"`
+	CORS         CORSConfig         `yaml:"cors" toml:"cors"`
+	TLS          TLSConfig          `yaml:"tls" toml:"tls"`
+	Security     SecurityConfig     `yaml:"security" toml:"security"`
+	Client       ClientConfig       `yaml:"client" toml:"client"`
+	WebTransport WebTransportConfig `yaml:"webtransport" toml:"webtransport"`
+	Log          LogConfig          `yaml:"log" toml:"log"`
+	Admin        AdminConfig        `yaml:"admin" toml:"admin"`
+	Auth         AuthConfig         `yaml:"auth" toml:"auth"`
+	Accounting   AccountingConfig   `yaml:"accounting" toml:"accounting"`
+	Audit        AuditConfig        `yaml:"audit" toml:"audit"`
+	Webhooks     WebhookConfig      `yaml:"webhooks" toml:"webhooks"`
+
+	source string // 加载的配置文件路径，未使用配置文件时为空
+}
+
+// ServerConfig HTTP 服务器配置
+type ServerConfig struct {
+	Port            int           `yaml:"port" toml:"port"`
+	FrontendDir     string        `yaml:"frontend_dir" toml:"frontend_dir"`
+	BasePath        string        `yaml:"base_path" toml:"base_path"`             // 部署路径前缀，如 /tools/chuan，为空时部署在根路径
+	TrustedProxies  []string      `yaml:"trusted_proxies" toml:"trusted_proxies"` // 可信反向代理的 IP 或 CIDR，只解析来自这些地址的 X-Forwarded-For 等请求头
+	ReadTimeout     time.Duration `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	WatchConfig     bool          `yaml:"watch_config" toml:"watch_config"` // 配置文件变化时自动重新加载
+}
+
+// WebSocketConfig 信令 WebSocket 配置
+type WebSocketConfig struct {
+	PingInterval   time.Duration `yaml:"ping_interval" toml:"ping_interval"`     // 心跳间隔
+	PongTimeout    time.Duration `yaml:"pong_timeout" toml:"pong_timeout"`       // 心跳超时，超时未收到数据即判定对端失联
+	SendQueueSize  int           `yaml:"send_queue_size" toml:"send_queue_size"` // 信令客户端出站队列容量
+	WriteTimeout   time.Duration `yaml:"write_timeout" toml:"write_timeout"`     // 信令和中继消息写超时
+	OverflowPolicy string        `yaml:"overflow_policy" toml:"overflow_policy"` // 出站队列溢出策略: drop | close
+}
+
+// RoomsConfig 房间配置
+type RoomsConfig struct {
+	TTL             time.Duration `yaml:"ttl" toml:"ttl"`                           // 房间默认有效期，无人使用的房间超过后被清理
+	MinTTL          time.Duration `yaml:"min_ttl" toml:"min_ttl"`                   // 创建房间时可请求的最短有效期
+	MaxTTL          time.Duration `yaml:"max_ttl" toml:"max_ttl"`                   // 创建房间时可请求的最长有效期
+	IdleTimeout     time.Duration `yaml:"idle_timeout" toml:"idle_timeout"`         // 所有人离开后房间保留的时间
+	MaxLifetime     time.Duration `yaml:"max_lifetime" toml:"max_lifetime"`         // 房间最长使用时间，超过后断开所有连接，0 表示不限制
+	CleanupInterval time.Duration `yaml:"cleanup_interval" toml:"cleanup_interval"` // 过期房间清理间隔
+	CodeLength      int           `yaml:"code_length" toml:"code_length"`           // 取件码长度
+	Reserved        []string      `yaml:"reserved" toml:"reserved"`                 // 保留房间码，格式为 房间码:API Key 名称 或 房间码:身份
+	Persistent      []string      `yaml:"persistent" toml:"persistent"`             // 持久房间码，不会被清理
+}
+
+// reservedCodes 解析保留房间码，身份不含 ":" 时视为 API Key 名称
+func (c RoomsConfig) reservedCodes() ([]services.ReservedCode, error) {
+	var codes []services.ReservedCode
+	seen := make(map[string]bool)
+	for _, entry := range c.Reserved {
+		code, owner, ok := strings.Cut(entry, ":")
+		code = services.NormalizePickupCode(code)
+		if !ok || owner == "" {
+			return nil, fmt.Errorf("%q 缺少所属身份，格式为 房间码:API Key 名称", entry)
+		}
+		if !services.ValidPickupCode(code, c.CodeLength) {
+			return nil, fmt.Errorf("房间码 %q 需要是 %d 位，只能包含 %s", code, c.CodeLength, services.PickupCodeChars)
+		}
+		if seen[code] {
+			return nil, fmt.Errorf("房间码 %q 重复", code)
+		}
+		seen[code] = true
+		if !strings.Contains(owner, ":") {
+			owner = auth.MethodAPIKey + ":" + owner
+		}
+		codes = append(codes, services.ReservedCode{Code: code, Owner: owner})
+	}
+	return codes, nil
+}
+
+// persistentCodes 返回规范化后的持久房间码
+func (c RoomsConfig) persistentCodes() []string {
+	codes := make([]string, len(c.Persistent))
+	for i, code := range c.Persistent {
+		codes[i] = services.NormalizePickupCode(code)
+	}
+	return codes
+}
+
+// RelayConfig WebSocket 中继配置
+type RelayConfig struct {
+	Enabled        bool  `yaml:"enabled" toml:"enabled"`                   // 是否接受中继连接，关闭后前端只使用 P2P
+	BufferSize     int   `yaml:"buffer_size" toml:"buffer_size"`           // 读写缓冲区大小（字节）
+	MaxMessageSize int64 `yaml:"max_message_size" toml:"max_message_size"` // 单条消息最大尺寸（字节）
+}
+
+// OriginsConfig 跨域来源策略，同时用于 CORS 和 WebSocket/WebTransport 升级校验
+type OriginsConfig struct {
+	// Allowed 允许的跨域来源，为空时只允许同源: "*"、精确来源 https://example.com、子域名通配 https://*.example.com、正则 regex:<表达式>
+	Allowed []string `yaml:"allowed" toml:"allowed"`
+}
+
+// policy 返回解析后的来源策略，配置已通过 Validate 校验
+func (c OriginsConfig) policy() *services.OriginPolicy {
+	policy, _ := services.NewOriginPolicy(c.Allowed)
+	return policy
+}
+
+// CORSConfig 跨域配置，允许的来源见 OriginsConfig
+type CORSConfig struct {
+	AllowCredentials bool `yaml:"allow_credentials" toml:"allow_credentials"`
+	MaxAge           int  `yaml:"max_age" toml:"max_age"` // 预检请求缓存时间（秒）
+}
+
+// TLSConfig HTTPS 配置
+type TLSConfig struct {
+	Cert         string `yaml:"cert" toml:"cert"`                   // 证书文件，与 Key 同时设置时启用 HTTPS
+	Key          string `yaml:"key" toml:"key"`                     // 私钥文件
+	RedirectPort int    `yaml:"redirect_port" toml:"redirect_port"` // 把该端口的 HTTP 请求重定向到 HTTPS，0 表示不启用
+}
+
+// Enabled 是否启用 HTTPS
+func (c TLSConfig) Enabled() bool {
+	return c.Cert != "" && c.Key != ""
+}
+
+// SecurityConfig 安全响应头配置
+type SecurityConfig struct {
+	CSP               string `yaml:"csp" toml:"csp"`                               // 前端页面的 Content-Security-Policy，off 表示不设置；页面内联脚本的哈希自动加入 script-src
+	CSPReportOnly     bool   `yaml:"csp_report_only" toml:"csp_report_only"`       // 只上报违规不拦截 (Content-Security-Policy-Report-Only)
+	HSTSMaxAge        int    `yaml:"hsts_max_age" toml:"hsts_max_age"`             // HTTPS 请求的 Strict-Transport-Security 有效期（秒），0 表示不设置
+	ReferrerPolicy    string `yaml:"referrer_policy" toml:"referrer_policy"`       // Referrer-Policy，为空时不设置
+	PermissionsPolicy string `yaml:"permissions_policy" toml:"permissions_policy"` // Permissions-Policy，为空时不设置
+	COOP              string `yaml:"coop" toml:"coop"`                             // Cross-Origin-Opener-Policy，为空时不设置
+	COEP              string `yaml:"coep" toml:"coep"`                             // Cross-Origin-Embedder-Policy，为空时不设置
+}
+
+// cspEnabled 是否为前端页面设置 CSP
+func (c SecurityConfig) cspEnabled() bool {
+	return c.CSP != "" && !strings.EqualFold(c.CSP, cspOff)
+}
+
+// ClientConfig 运行时下发给前端的配置，通过 /api/client-config 提供，并可注入到前端页面的 window.__CHUAN_CONFIG__
+type ClientConfig struct {
+	Inject         bool     `yaml:"inject" toml:"inject"`                   // 在前端页面中注入 window.__CHUAN_CONFIG__
+	ICEServers     []string `yaml:"ice_servers" toml:"ice_servers"`         // STUN/TURN 地址，为空时前端使用内置的默认列表
+	TURNUsername   string   `yaml:"turn_username" toml:"turn_username"`     // turn:/turns: 地址使用的用户名
+	TURNCredential string   `yaml:"turn_credential" toml:"turn_credential"` // turn:/turns: 地址使用的密码
+	DesktopShare   bool     `yaml:"desktop_share" toml:"desktop_share"`     // 显示桌面共享
+	MaxFileSize    int64    `yaml:"max_file_size" toml:"max_file_size"`     // 单个文件最大字节数，0 表示不限制
+	BrandName      string   `yaml:"brand_name" toml:"brand_name"`           // 站点名称，为空时使用前端内置名称
+	BrandLogo      string   `yaml:"brand_logo" toml:"brand_logo"`           // 站点图标地址
+	PublicURL      string   `yaml:"public_url" toml:"public_url"`           // 对外访问地址，为空时前端使用当前页面地址
+}
+
+// iceServerSchemes ICE 服务器地址允许的协议
+var iceServerSchemes = []string{"stun:", "stuns:", "turn:", "turns:"}
+
+// isTURN 判断 ICE 服务器地址是否为 TURN 服务器
+func isTURN(server string) bool {
+	return strings.HasPrefix(server, "turn:") || strings.HasPrefix(server, "turns:")
+}
+
+// WebTransportConfig WebTransport 中继配置
+type WebTransportConfig struct {
+	Port int    `yaml:"port" toml:"port"` // UDP 端口，0 表示不启用
+	Cert string `yaml:"cert" toml:"cert"` // 证书文件，为空时复用 TLS 证书或使用自签名证书
+	Key  string `yaml:"key" toml:"key"`   // 私钥文件
+}
+
+// LogConfig 日志配置
+type LogConfig struct {
+	Level string `yaml:"level" toml:"level"` // debug | info | warn | error
+}
+
+// level 返回解析后的日志级别，配置已通过 Validate 校验
+func (c LogConfig) level() logging.Level {
+	level, _ := logging.ParseLevel(c.Level)
+	return level
+}
+
+// AdminConfig 管理接口配置
+type AdminConfig struct {
+	Token string `yaml:"token" toml:"token"` // 管理接口令牌，为空时不启用管理接口
+}
+
+// 加入房间的认证策略
+const (
+	joinOpen   = "open"   // 任何人凭取件码即可加入
+	joinSender = "sender" // 发送方需要认证，接收方凭取件码即可加入
+	joinAll    = "all"    // 发送方和接收方都需要认证
+)
+
+// AuthConfig 创建/加入房间的身份验证配置
+type AuthConfig struct {
+	CreateRoom bool       `yaml:"create_room" toml:"create_room"` // 创建房间是否需要认证
+	Join       string     `yaml:"join" toml:"join"`               // 加入房间的认证策略: open | sender | all
+	APIKeys    []string   `yaml:"api_keys" toml:"api_keys"`       // 静态 API Key，格式为 "名称:密钥" 或仅 "密钥"
+	Htpasswd   string     `yaml:"htpasswd" toml:"htpasswd"`       // bcrypt htpasswd 文件，用于 HTTP Basic 认证
+	OIDC       OIDCConfig `yaml:"oidc" toml:"oidc"`
+}
+
+// OIDCConfig OIDC Bearer 令牌校验配置
+type OIDCConfig struct {
+	Issuer   string `yaml:"issuer" toml:"issuer"`     // 令牌签发者，未设置 jwks_url 时通过 OIDC 发现获取 JWKS
+	JWKSURL  string `yaml:"jwks_url" toml:"jwks_url"` // JWKS 地址
+	Audience string `yaml:"audience" toml:"audience"` // 设置后校验令牌的 aud
+}
+
+// authConfig 转换为认证后端配置
+func (c AuthConfig) authConfig() auth.Config {
+	return auth.Config{
+		APIKeys:      c.APIKeys,
+		HtpasswdFile: c.Htpasswd,
+		OIDC: auth.OIDCConfig{
+			Issuer:   c.OIDC.Issuer,
+			JWKSURL:  c.OIDC.JWKSURL,
+			Audience: c.OIDC.Audience,
+		},
+	}
+}
+
+// Required 是否有需要认证的操作
+func (c AuthConfig) Required() bool {
+	return c.CreateRoom || c.Join != joinOpen
+}
+
+// AccountingConfig 用量统计和配额，按房间创建者（认证身份）计算，匿名创建的房间只统计不限制
+type AccountingConfig struct {
+	DB                string `yaml:"db" toml:"db"`                                   // bbolt 数据库文件，为空时用量只保存在内存中
+	DailyRooms        int64  `yaml:"daily_rooms" toml:"daily_rooms"`                 // 每日创建房间数上限，0 表示不限制
+	MonthlyRooms      int64  `yaml:"monthly_rooms" toml:"monthly_rooms"`             // 每月创建房间数上限
+	DailyRelayBytes   int64  `yaml:"daily_relay_bytes" toml:"daily_relay_bytes"`     // 每日中继流量上限（字节）
+	MonthlyRelayBytes int64  `yaml:"monthly_relay_bytes" toml:"monthly_relay_bytes"` // 每月中继流量上限（字节）
+}
+
+// quota 转换为配额
+func (c AccountingConfig) quota() accounting.Quota {
+	return accounting.Quota{
+		DailyRooms:        c.DailyRooms,
+		MonthlyRooms:      c.MonthlyRooms,
+		DailyRelayBytes:   c.DailyRelayBytes,
+		MonthlyRelayBytes: c.MonthlyRelayBytes,
+	}
+}
+
+// AuditConfig 审计日志，记录房间创建、加入/离开、中继会话、文件分享和管理操作
+type AuditConfig struct {
+	File      string `yaml:"file" toml:"file"`             // JSONL 文件路径，为空时不记录
+	MaxSize   int64  `yaml:"max_size" toml:"max_size"`     // 单个文件的最大字节数，超过后轮转，0 表示不轮转
+	MaxFiles  int    `yaml:"max_files" toml:"max_files"`   // 保留的轮转文件数，0 表示全部保留
+	HashChain bool   `yaml:"hash_chain" toml:"hash_chain"` // 每条记录带上前一条的哈希，用于发现篡改
+}
+
+// options 转换为审计日志参数
+func (c AuditConfig) options() audit.Options {
+	return audit.Options{
+		Path:      c.File,
+		MaxSize:   c.MaxSize,
+		MaxFiles:  c.MaxFiles,
+		HashChain: c.HashChain,
+	}
+}
+
+// WebhookConfig 把房间事件推送到外部地址（如聊天机器人）
+type WebhookConfig struct {
+	URLs        []string      `yaml:"urls" toml:"urls"`                 // 接收事件的地址，为空时不启用
+	Secret      string        `yaml:"secret" toml:"secret"`             // HMAC-SHA256 签名密钥
+	Events      []string      `yaml:"events" toml:"events"`             // 订阅的事件类型
+	Timeout     time.Duration `yaml:"timeout" toml:"timeout"`           // 单次请求超时
+	MaxAttempts int           `yaml:"max_attempts" toml:"max_attempts"` // 每个事件最多投递次数（含第一次）
+	DeadLetter  string        `yaml:"dead_letter" toml:"dead_letter"`   // 投递失败的事件写入的 JSONL 文件
+
+	IncludeIdentity   bool `yaml:"include_identity" toml:"include_identity"`       // 请求体是否包含用户身份
+	IncludeRemoteAddr bool `yaml:"include_remote_addr" toml:"include_remote_addr"` // 请求体是否包含客户端 IP
+}
+
+// options 转换为 webhook 投递参数
+func (c WebhookConfig) options() webhook.Options {
+	return webhook.Options{
+		URLs:        c.URLs,
+		Secret:      c.Secret,
+		Events:      c.Events,
+		Timeout:     c.Timeout,
+		MaxAttempts: c.MaxAttempts,
+		DeadLetter:  c.DeadLetter,
+
+		IncludeIdentity:   c.IncludeIdentity,
+		IncludeRemoteAddr: c.IncludeRemoteAddr,
+	}
+}
+
+// clientConfig 转换为下发给前端的配置，中继开关沿用 relay.enabled
+func (c *Config) clientConfig() web.ClientConfig {
+	config := web.ClientConfig{
+		Features: web.ClientFeatures{
+			Relay:        c.Relay.Enabled,
+			DesktopShare: c.Client.DesktopShare,
+			MaxFileSize:  c.Client.MaxFileSize,
+		},
+		Branding: web.ClientBranding{
+			Name:    c.Client.BrandName,
+			LogoURL: c.Client.BrandLogo,
+		},
+		PublicURL: strings.TrimRight(c.Client.PublicURL, "/"),
+	}
+	for _, server := range c.Client.ICEServers {
+		ice := web.ICEServer{URLs: server}
+		if isTURN(server) {
+			ice.Username, ice.Credential = c.Client.TURNUsername, c.Client.TURNCredential
+		}
+		config.ICEServers = append(config.ICEServers, ice)
+	}
+	return config
+}
+
+// defaultConfig 返回默认配置
+func defaultConfig() *Config {
+	opts := services.DefaultOptions()
+	return &Config{
+		Server: ServerConfig{
+			Port:            8080,
+			ReadTimeout:     30 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     120 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+			TrustedProxies:  []string{"127.0.0.0/8", "::1"},
+		},
+		WebSocket: WebSocketConfig{
+			PingInterval:   opts.PingInterval,
+			PongTimeout:    opts.PongTimeout,
+			SendQueueSize:  opts.SendQueueSize,
+			WriteTimeout:   opts.WriteTimeout,
+			OverflowPolicy: opts.OverflowPolicy,
+		},
+		Rooms: RoomsConfig{
+			TTL:             opts.RoomTTL,
+			MinTTL:          opts.MinRoomTTL,
+			MaxTTL:          opts.MaxRoomTTL,
+			IdleTimeout:     opts.IdleTimeout,
+			MaxLifetime:     opts.MaxLifetime,
+			CleanupInterval: opts.CleanupInterval,
+			CodeLength:      opts.CodeLength,
+		},
+		Relay: RelayConfig{
+			Enabled:        opts.RelayEnabled,
+			BufferSize:     opts.RelayBufferSize,
+			MaxMessageSize: opts.RelayMaxMessageSize,
+		},
+		Origins: OriginsConfig{
+			Allowed: []string{}, // 只允许同源，允许所有来源需要显式配置 *
+		},
+		CORS: CORSConfig{
+			MaxAge: 300,
+		},
+		Client: ClientConfig{
+			Inject:       true,
+			DesktopShare: true,
+		},
+		Security: SecurityConfig{
+			CSP:               defaultCSP,
+			HSTSMaxAge:        365 * 24 * 3600,
+			ReferrerPolicy:    "same-origin",
+			PermissionsPolicy: "display-capture=(self), microphone=(self), camera=(), geolocation=(), payment=(), usb=()",
+			COOP:              "same-origin",
+		},
+		Log: LogConfig{
+			Level: logging.LevelInfo.String(),
+		},
+		Auth: AuthConfig{
+			Join: joinOpen,
+		},
+		Audit: AuditConfig{
+			MaxSize: 100 << 20,
+		},
+		Webhooks: WebhookConfig{
+			Events:      []string{events.RoomCreated, events.ParticipantJoined, events.RelaySessionEnded, events.RoomExpired},
+			Timeout:     10 * time.Second,
+			MaxAttempts: 5,
+		},
+	}
 }
 
+// serviceOptions 转换为服务运行参数
+func (c *Config) serviceOptions() services.Options {
+	opts := services.Options{
+		PingInterval:   c.WebSocket.PingInterval,
+		PongTimeout:    c.WebSocket.PongTimeout,
+		SendQueueSize:  c.WebSocket.SendQueueSize,
+		WriteTimeout:   c.WebSocket.WriteTimeout,
+		OverflowPolicy: c.WebSocket.OverflowPolicy,
+
+		RoomTTL:         c.Rooms.TTL,
+		MinRoomTTL:      c.Rooms.MinTTL,
+		MaxRoomTTL:      c.Rooms.MaxTTL,
+		IdleTimeout:     c.Rooms.IdleTimeout,
+		MaxLifetime:     c.Rooms.MaxLifetime,
+		CleanupInterval: c.Rooms.CleanupInterval,
+		CodeLength:      c.Rooms.CodeLength,
+		PersistentCodes: c.Rooms.persistentCodes(),
+
+		RelayBufferSize:     c.Relay.BufferSize,
+		RelayMaxMessageSize: c.Relay.MaxMessageSize,
+		RelayEnabled:        c.Relay.Enabled,
+
+		Origins: c.Origins.policy(),
+
+		Quota: c.Accounting.quota(),
+	}
+	// 保留房间码在 Validate 中已校验，这里不会出错
+	opts.ReservedCodes, _ = c.Rooms.reservedCodes()
+	return opts
+}
+
+// Validate 校验配置，一次性返回所有问题
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	validPort := func(port int) bool { return port > 0 && port <= 65535 }
+
+	check(validPort(c.Server.Port), "server.port (PORT) 必须在 1-65535 之间，当前为 %d", c.Server.Port)
+	if basePath := c.Server.basePath(); basePath != "" {
+		check(validBasePath(basePath), "server.base_path (BASE_PATH) 无效: %q，应为 /tools/chuan 形式的路径", c.Server.BasePath)
+	}
+	if _, err := parseTrustedProxies(c.Server.TrustedProxies); err != nil {
+		check(false, "server.trusted_proxies (TRUSTED_PROXIES) 无效: %v", err)
+	}
+	check(c.Server.ReadTimeout > 0, "server.read_timeout (HTTP_READ_TIMEOUT) 必须大于 0")
+	check(c.Server.WriteTimeout > 0, "server.write_timeout (HTTP_WRITE_TIMEOUT) 必须大于 0")
+	check(c.Server.IdleTimeout > 0, "server.idle_timeout (HTTP_IDLE_TIMEOUT) 必须大于 0")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout (SHUTDOWN_TIMEOUT) 必须大于 0")
+
+	// 心跳超时必须大于心跳间隔，否则正常连接也会被判定为失联
+	check(c.WebSocket.PingInterval > 0, "websocket.ping_interval (WS_PING_INTERVAL) 必须大于 0")
+	check(c.WebSocket.PongTimeout > c.WebSocket.PingInterval,
+		"websocket.pong_timeout (WS_PONG_TIMEOUT) 必须大于 websocket.ping_interval，当前为 %v <= %v",
+		c.WebSocket.PongTimeout, c.WebSocket.PingInterval)
+	check(c.WebSocket.SendQueueSize > 0, "websocket.send_queue_size (WS_SEND_QUEUE_SIZE) 必须大于 0")
+	check(c.WebSocket.WriteTimeout > 0, "websocket.write_timeout (WS_WRITE_TIMEOUT) 必须大于 0")
+	check(c.WebSocket.OverflowPolicy == services.OverflowDrop || c.WebSocket.OverflowPolicy == services.OverflowClose,
+		"websocket.overflow_policy (WS_OVERFLOW_POLICY) 只能是 %s 或 %s，当前为 %q",
+		services.OverflowDrop, services.OverflowClose, c.WebSocket.OverflowPolicy)
+
+	check(c.Rooms.MinTTL > 0 && c.Rooms.MinTTL <= c.Rooms.TTL && c.Rooms.TTL <= c.Rooms.MaxTTL,
+		"房间有效期需满足 0 < rooms.min_ttl (ROOM_MIN_TTL) <= rooms.ttl (ROOM_TTL) <= rooms.max_ttl (ROOM_MAX_TTL)，当前为 %v、%v、%v",
+		c.Rooms.MinTTL, c.Rooms.TTL, c.Rooms.MaxTTL)
+	check(c.Rooms.IdleTimeout > 0, "rooms.idle_timeout (ROOM_IDLE_TIMEOUT) 必须大于 0")
+	check(c.Rooms.MaxLifetime == 0 || c.Rooms.MaxLifetime >= c.Rooms.MaxTTL,
+		"rooms.max_lifetime (ROOM_MAX_LIFETIME) 不能小于 rooms.max_ttl (ROOM_MAX_TTL)，0 表示不限制")
+	check(c.Rooms.CleanupInterval > 0, "rooms.cleanup_interval (ROOM_CLEANUP_INTERVAL) 必须大于 0")
+	check(c.Rooms.CodeLength >= 4 && c.Rooms.CodeLength <= 16,
+		"rooms.code_length (ROOM_CODE_LENGTH) 必须在 4-16 之间，当前为 %d", c.Rooms.CodeLength)
+	if _, err := c.Rooms.reservedCodes(); err != nil {
+		check(false, "rooms.reserved (ROOM_RESERVED_CODES) 无效: %v", err)
+	}
+	for _, code := range c.Rooms.persistentCodes() {
+		check(services.ValidPickupCode(code, c.Rooms.CodeLength),
+			"rooms.persistent (ROOM_PERSISTENT_CODES) 中的房间码 %q 需要是 %d 位，只能包含 %s", code, c.Rooms.CodeLength, services.PickupCodeChars)
+	}
+
+	check(c.Relay.BufferSize > 0, "relay.buffer_size (RELAY_BUFFER_SIZE) 必须大于 0")
+	check(c.Relay.MaxMessageSize > 0, "relay.max_message_size (RELAY_MAX_MESSAGE_SIZE) 必须大于 0")
+
+	if policy, err := services.NewOriginPolicy(c.Origins.Allowed); err != nil {
+		check(false, "origins.allowed (ALLOWED_ORIGINS) 无效: %v", err)
+	} else {
+		// 允许任意来源携带凭据等同于关闭同源保护
+		check(!(policy.AllowAll() && c.CORS.AllowCredentials),
+			"cors.allow_credentials (CORS_ALLOW_CREDENTIALS) 不能与 origins.allowed 中的 * 同时使用，请列出具体来源")
+	}
+	check(c.CORS.MaxAge >= 0, "cors.max_age (CORS_MAX_AGE) 不能为负数")
+
+	check((c.TLS.Cert == "") == (c.TLS.Key == ""), "tls.cert (TLS_CERT) 和 tls.key (TLS_KEY) 需要同时设置")
+	if c.TLS.Enabled() {
+		check(fileExists(c.TLS.Cert), "tls.cert (TLS_CERT) 文件不存在: %s", c.TLS.Cert)
+		check(fileExists(c.TLS.Key), "tls.key (TLS_KEY) 文件不存在: %s", c.TLS.Key)
+	}
+	if c.TLS.RedirectPort != 0 {
+		check(c.TLS.Enabled(), "tls.redirect_port (HTTP_REDIRECT_PORT) 需要同时启用 HTTPS")
+		check(validPort(c.TLS.RedirectPort) && c.TLS.RedirectPort != c.Server.Port,
+			"tls.redirect_port (HTTP_REDIRECT_PORT) 必须在 1-65535 之间且不同于 server.port，当前为 %d", c.TLS.RedirectPort)
+	}
+
+	if c.Security.cspEnabled() {
+		check(validCSP(c.Security.CSP), "security.csp (CSP) 无效，应为分号分隔的 \"指令 来源...\"，不设置请使用 %s", cspOff)
+	}
+	check(c.Security.HSTSMaxAge >= 0, "security.hsts_max_age (HSTS_MAX_AGE) 不能为负数")
+	check(c.Security.ReferrerPolicy == "" || referrerPolicies[c.Security.ReferrerPolicy],
+		"security.referrer_policy (REFERRER_POLICY) 无效: %s", c.Security.ReferrerPolicy)
+	check(!strings.ContainsAny(c.Security.PermissionsPolicy, "\r\n"), "security.permissions_policy (PERMISSIONS_POLICY) 不能包含换行")
+	check(c.Security.COOP == "" || openerPolicies[c.Security.COOP],
+		"security.coop (COOP) 必须是 same-origin、same-origin-allow-popups、noopener-allow-popups 或 unsafe-none，当前为 %s", c.Security.COOP)
+	check(c.Security.COEP == "" || embedderPolicies[c.Security.COEP],
+		"security.coep (COEP) 必须是 require-corp、credentialless 或 unsafe-none，当前为 %s", c.Security.COEP)
+
+	for _, server := range c.Client.ICEServers {
+		valid := false
+		for _, scheme := range iceServerSchemes {
+			valid = valid || (strings.HasPrefix(server, scheme) && len(server) > len(scheme))
+		}
+		check(valid, "client.ice_servers (ICE_SERVERS) 包含无效地址 %q，需要以 stun:、stuns:、turn: 或 turns: 开头", server)
+		if isTURN(server) {
+			check(c.Client.TURNUsername != "" && c.Client.TURNCredential != "",
+				"client.ice_servers (ICE_SERVERS) 包含 TURN 服务器 %q，需要同时设置 client.turn_username (TURN_USERNAME) 和 client.turn_credential (TURN_CREDENTIAL)", server)
+		}
+	}
+	check(c.Client.MaxFileSize >= 0, "client.max_file_size (MAX_FILE_SIZE) 不能为负数，0 表示不限制")
+	if c.Client.PublicURL != "" {
+		u, err := url.Parse(c.Client.PublicURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.RawQuery == "" && u.Fragment == "",
+			"client.public_url (PUBLIC_URL) 必须是 http:// 或 https:// 开头的地址，不能包含查询参数，当前为 %q", c.Client.PublicURL)
+	}
+
+	if c.WebTransport.Port != 0 {
+		check(validPort(c.WebTransport.Port), "webtransport.port (WT_PORT) 必须在 1-65535 之间，当前为 %d", c.WebTransport.Port)
+	}
+	check((c.WebTransport.Cert == "") == (c.WebTransport.Key == ""),
+		"webtransport.cert (WT_CERT) 和 webtransport.key (WT_KEY) 需要同时设置")
+
+	_, err := logging.ParseLevel(c.Log.Level)
+	check(err == nil, "log.level (LOG_LEVEL) 无效: %v", err)
+
+	check(c.Admin.Token == "" || len(c.Admin.Token) >= 16, "admin.token (ADMIN_TOKEN) 至少需要 16 个字符")
+
+	check(c.Accounting.DailyRooms >= 0 && c.Accounting.MonthlyRooms >= 0 &&
+		c.Accounting.DailyRelayBytes >= 0 && c.Accounting.MonthlyRelayBytes >= 0,
+		"accounting 配额 (QUOTA_*) 不能为负数，0 表示不限制")
+	check(c.Audit.MaxSize >= 0, "audit.max_size (AUDIT_MAX_SIZE) 不能为负数，0 表示不轮转")
+	check(c.Audit.MaxFiles >= 0, "audit.max_files (AUDIT_MAX_FILES) 不能为负数，0 表示全部保留")
+
+	for _, raw := range c.Webhooks.URLs {
+		u, err := url.Parse(raw)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"webhooks.urls (WEBHOOK_URLS) 包含无效地址 %q，需要以 http:// 或 https:// 开头", raw)
+	}
+	for _, eventType := range c.Webhooks.Events {
+		check(events.Known(eventType), "webhooks.events (WEBHOOK_EVENTS) 包含未知事件 %q，可选: %s",
+			eventType, strings.Join(events.Types, ", "))
+	}
+	check(c.Webhooks.Timeout > 0, "webhooks.timeout (WEBHOOK_TIMEOUT) 必须大于 0")
+	check(c.Webhooks.MaxAttempts >= 1, "webhooks.max_attempts (WEBHOOK_MAX_ATTEMPTS) 至少为 1")
+
+	check(c.Auth.Join == joinOpen || c.Auth.Join == joinSender || c.Auth.Join == joinAll,
+		"auth.join (AUTH_JOIN) 只能是 %s、%s 或 %s，当前为 %q", joinOpen, joinSender, joinAll, c.Auth.Join)
+	if authenticator, err := auth.New(c.Auth.authConfig()); err != nil {
+		check(false, "auth 配置无效: %v", err)
+	} else {
+		check(!c.Auth.Required() || authenticator.Enabled(),
+			"auth.create_room (AUTH_CREATE_ROOM) 或 auth.join (AUTH_JOIN) 需要认证时，至少配置 auth.api_keys、auth.htpasswd 或 auth.oidc 中的一种")
+	}
+
+	return errors.Join(errs...)
+}
+
+// fileExists 判断文件是否存在
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// envFileKeys 上次从环境变量文件设置的变量
+var envFileKeys = map[string]bool{}
+
 // loadEnvFile 加载环境变量文件
+// 重新加载时先清除上次由文件设置的变量，使文件中的修改和删除都能生效
 func loadEnvFile(filename string) error {
+	for key := range envFileKeys {
+		os.Unsetenv(key)
+	}
+	envFileKeys = map[string]bool{}
+
 	file, err := os.Open(filename)
 	if err != nil {
 		return err
@@ -27,73 +607,97 @@ func loadEnvFile(filename string) error {
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
-		
+
 		// 跳过空行和注释行
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
-		
+
 		// 解析 KEY=VALUE 格式
 		parts := strings.SplitN(line, "=", 2)
 		if len(parts) == 2 {
 			key := strings.TrimSpace(parts[0])
 			value := strings.TrimSpace(parts[1])
-			
+
 			// 移除值两端的引号
 			if (strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"")) ||
-			   (strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'")) {
+				(strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'")) {
 				value = value[1 : len(value)-1]
 			}
-			
+
 			// 只有当环境变量不存在时才设置
 			if os.Getenv(key) == "" {
 				os.Setenv(key, value)
+				envFileKeys[key] = true
 			}
 		}
 	}
-	
+
 	return scanner.Err()
 }
 
 // showHelp 显示帮助信息
 func showHelp() {
+	defaults := defaultConfig()
+
 	fmt.Println("文件传输服务器")
 	fmt.Println("用法:")
+	fmt.Println("  ./file-transfer-server [参数]")
+	fmt.Println("  ./file-transfer-server config print [-format yaml|toml] [参数]  - 输出最终生效的配置")
+	fmt.Println("  ./file-transfer-server audit verify [-file path]                - 校验审计日志的哈希链")
+	fmt.Println("  ./file-transfer-server room events [-server URL] <房间码>        - 逐行输出房间事件 (JSON)")
+	fmt.Println("  ./file-transfer-server room wait [-server URL] [-for 事件] [-timeout 时长] <房间码> - 等待房间事件 (默认 sender_online)")
 	fmt.Println("  配置文件:")
-	fmt.Println("    .chuan.env             - 自动加载的配置文件")
+	fmt.Println("    chuan.yaml / chuan.toml - 自动加载的结构化配置文件 (也可通过 -config 或 CHUAN_CONFIG 指定)")
+	fmt.Println("    .chuan.env             - 自动加载的环境变量文件")
 	fmt.Println("  环境变量:")
-	fmt.Println("    PORT=8080              - 服务器监听端口")
-	fmt.Println("    FRONTEND_DIR=/path     - 外部前端文件目录 (可选)")
+	for _, s := range defaults.settings() {
+		fmt.Printf("    %-36s - %s\n", s.env+"="+s.value.String(), s.usage)
+	}
 	fmt.Println("  命令行参数:")
-	flag.PrintDefaults()
+	fmt.Printf("    %-36s - %s\n", "-config <path>", "YAML/TOML 配置文件路径 (可通过 CHUAN_CONFIG 环境变量设置)")
+	for _, s := range defaults.settings() {
+		fmt.Printf("    %-36s - 同 %s\n", "-"+s.flag+" <value>", s.env)
+	}
 	fmt.Println("")
-	fmt.Println("配置优先级: 命令行参数 > 环境变量 > 配置文件 > 默认值")
+	fmt.Println("配置优先级: 命令行参数 > 环境变量 > .chuan.env > 配置文件 > 默认值")
+	fmt.Println("收到 SIGHUP 时重新加载配置 (端口、证书路径等标记为需要重启的配置项除外)")
 	fmt.Println("")
 	fmt.Println("示例:")
 	fmt.Println("  ./file-transfer-server")
 	fmt.Println("  ./file-transfer-server -port 3000")
+	fmt.Println("  ./file-transfer-server -config /etc/chuan/chuan.yaml")
+	fmt.Println("  ./file-transfer-server config print -format toml")
+	fmt.Println("  ./file-transfer-server room wait -server https://chuan.example.com -for receiver_online QA2024")
 	fmt.Println("  PORT=8080 FRONTEND_DIR=./dist ./file-transfer-server")
 }
 
-// loadConfig 加载应用配置
-func loadConfig() *Config {
+// loadConfig 按 默认值 → 配置文件 → 环境变量 → 命令行参数 的顺序加载应用配置
+func loadConfig(args []string) (*Config, error) {
 	// 首先尝试加载 .chuan.env 文件
 	if err := loadEnvFile(".chuan.env"); err == nil {
 		log.Printf("📄 已加载配置文件: .chuan.env")
 	}
 
-	// 从环境变量获取配置，如果没有则使用默认值
-	defaultPort := 8080
-	if envPort := os.Getenv("PORT"); envPort != "" {
-		if port, err := strconv.Atoi(envPort); err == nil {
-			defaultPort = port
+	config := defaultConfig()
+
+	if path := configFilePath(args); path != "" {
+		if err := loadConfigFile(path, config); err != nil {
+			return nil, err
 		}
+		config.source = path
+		log.Printf("📄 已加载配置文件: %s", path)
 	}
 
-	// 定义命令行参数
-	var port = flag.Int("port", defaultPort, "服务器监听端口 (可通过 PORT 环境变量设置)")
-	var help = flag.Bool("help", false, "显示帮助信息")
-	flag.Parse()
+	if err := config.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	fs := newFlagSet(config)
+	help := fs.Bool("help", false, "显示帮助信息")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
 
 	// 显示帮助信息
 	if *help {
@@ -101,24 +705,79 @@ func loadConfig() *Config {
 		os.Exit(0)
 	}
 
-	config := &Config{
-		Port:        *port,
-		FrontendDir: os.Getenv("FRONTEND_DIR"),
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("配置无效:\n%w", err)
 	}
 
-	return config
+	return config, nil
 }
 
 // logConfig 记录配置信息
 func logConfig(config *Config) {
 	// 记录前端配置信息
-	if config.FrontendDir != "" {
-		if info, err := os.Stat(config.FrontendDir); err == nil && info.IsDir() {
-			log.Printf("✅ 使用外部前端目录: %s", config.FrontendDir)
+	if config.Server.FrontendDir != "" {
+		if info, err := os.Stat(config.Server.FrontendDir); err == nil && info.IsDir() {
+			log.Printf("✅ 使用外部前端目录: %s", config.Server.FrontendDir)
 		} else {
-			log.Printf("⚠️ 外部前端目录不可用: %s, 回退到内嵌文件", config.FrontendDir)
+			log.Printf("⚠️ 外部前端目录不可用: %s, 回退到内嵌文件", config.Server.FrontendDir)
 		}
 	} else {
 		log.Printf("📦 使用内嵌前端文件")
 	}
+
+	if basePath := config.Server.basePath(); basePath != "" {
+		log.Printf("📁 部署路径: %s/", basePath)
+	}
+	switch policy := config.Origins.policy(); {
+	case policy.AllowAll():
+		log.Printf("⚠️ 允许所有跨域来源连接 WebSocket/WebTransport，存在跨站 WebSocket 劫持风险")
+	case len(config.Origins.Allowed) == 0:
+		log.Printf("🌐 跨域来源: 只允许同源")
+	default:
+		log.Printf("🌐 跨域来源: 同源, %s", strings.Join(config.Origins.Allowed, ", "))
+	}
+	if len(config.Server.TrustedProxies) > 0 {
+		log.Printf("🔀 可信反向代理: %s", strings.Join(config.Server.TrustedProxies, ", "))
+	} else {
+		log.Printf("🔀 未配置可信反向代理，忽略 X-Forwarded-For 等请求头")
+	}
+
+	log.Printf("💓 WebSocket 心跳: 间隔=%v, 超时=%v", config.WebSocket.PingInterval, config.WebSocket.PongTimeout)
+	log.Printf("🏠 房间: 有效期=%v (可请求 %v-%v), 空闲保留=%v, 最长使用时间=%v, 取件码长度=%d",
+		config.Rooms.TTL, config.Rooms.MinTTL, config.Rooms.MaxTTL, config.Rooms.IdleTimeout, config.Rooms.MaxLifetime, config.Rooms.CodeLength)
+
+	if config.TLS.Enabled() {
+		log.Printf("🔒 HTTPS 已启用: 证书=%s", config.TLS.Cert)
+	}
+
+	log.Printf("🛡️ 安全响应头: CSP=%s, HSTS=%ds, Referrer-Policy=%s, COOP=%s, COEP=%s",
+		config.Security.cspMode(), config.Security.HSTSMaxAge, config.Security.ReferrerPolicy, config.Security.COOP, config.Security.COEP)
+
+	if !config.Relay.Enabled {
+		log.Printf("🚫 数据中继已关闭，只允许 P2P 传输")
+	}
+	if len(config.Client.ICEServers) > 0 {
+		log.Printf("🧊 下发给前端的 ICE 服务器: %s", strings.Join(config.Client.ICEServers, ","))
+	}
+
+	if config.WebTransport.Port > 0 {
+		log.Printf("⚡ WebTransport 中继已启用: UDP :%d", config.WebTransport.Port)
+	}
+
+	if config.Auth.Required() {
+		log.Printf("🔐 身份验证已启用: 创建房间=%v, 加入房间=%s", config.Auth.CreateRoom, config.Auth.Join)
+	}
+
+	if config.Accounting.DB != "" {
+		log.Printf("📊 用量统计保存到: %s", config.Accounting.DB)
+	}
+
+	if config.Audit.File != "" {
+		log.Printf("📝 审计日志: %s (哈希链=%v)", config.Audit.File, config.Audit.HashChain)
+	}
+
+	if len(config.Webhooks.URLs) > 0 {
+		log.Printf("📮 Webhook: %d 个地址, 事件=%s, 包含身份=%v, 包含客户端 IP=%v", len(config.Webhooks.URLs),
+			strings.Join(config.Webhooks.Events, ","), config.Webhooks.IncludeIdentity, config.Webhooks.IncludeRemoteAddr)
+	}
 }
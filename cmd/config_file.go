@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFiles 未指定配置文件时在当前目录下依次查找的文件
+var defaultConfigFiles = []string{"chuan.yaml", "chuan.yml", "chuan.toml"}
+
+// secretSettings 敏感配置项，输出配置和记录配置变化时隐藏其值
+// Slack、Discord 等聊天机器人的 webhook 地址在路径中包含令牌，同样视为敏感配置
+var secretSettings = map[string]bool{
+	"ADMIN_TOKEN":     true,
+	"AUTH_API_KEYS":   true,
+	"WEBHOOK_URLS":    true,
+	"WEBHOOK_SECRET":  true,
+	"TURN_CREDENTIAL": true,
+}
+
+// maskSecret 隐藏敏感配置的值，只保留是否设置
+func maskSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "******"
+}
+
+// setting 一个可通过环境变量和命令行参数设置的配置项
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	value flag.Value
+}
+
+// settings 返回绑定到当前配置字段的所有配置项
+func (c *Config) settings() []setting {
+	return []setting{
+		{"PORT", "port", "服务器监听端口", (*intValue)(&c.Server.Port)},
+		{"FRONTEND_DIR", "frontend-dir", "外部前端文件目录 (可选)", (*stringValue)(&c.Server.FrontendDir)},
+		{"BASE_PATH", "base-path", "部署路径前缀 (如 /tools/chuan)，所有接口和前端页面都在该路径下；反向代理去掉前缀转发时改用 X-Forwarded-Prefix 请求头", (*stringValue)(&c.Server.BasePath)},
+		{"TRUSTED_PROXIES", "trusted-proxies", "可信反向代理的 IP 或 CIDR，逗号分隔；只有来自这些地址的请求才解析 X-Forwarded-For、X-Real-IP、Forwarded、X-Forwarded-Proto 和 X-Forwarded-Prefix", (*stringListValue)(&c.Server.TrustedProxies)},
+		{"HTTP_READ_TIMEOUT", "http-read-timeout", "HTTP 读超时", (*durationValue)(&c.Server.ReadTimeout)},
+		{"HTTP_WRITE_TIMEOUT", "http-write-timeout", "HTTP 写超时", (*durationValue)(&c.Server.WriteTimeout)},
+		{"HTTP_IDLE_TIMEOUT", "http-idle-timeout", "HTTP 空闲连接超时", (*durationValue)(&c.Server.IdleTimeout)},
+		{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "优雅关闭的最长等待时间", (*durationValue)(&c.Server.ShutdownTimeout)},
+		{"WATCH_CONFIG", "watch-config", "配置文件变化时自动重新加载", (*boolValue)(&c.Server.WatchConfig)},
+
+		{"WS_PING_INTERVAL", "ping-interval", "WebSocket 心跳间隔", (*durationValue)(&c.WebSocket.PingInterval)},
+		{"WS_PONG_TIMEOUT", "pong-timeout", "WebSocket 心跳超时", (*durationValue)(&c.WebSocket.PongTimeout)},
+		{"WS_SEND_QUEUE_SIZE", "send-queue-size", "信令客户端出站队列容量", (*intValue)(&c.WebSocket.SendQueueSize)},
+		{"WS_WRITE_TIMEOUT", "ws-write-timeout", "信令和中继消息写超时", (*durationValue)(&c.WebSocket.WriteTimeout)},
+		{"WS_OVERFLOW_POLICY", "overflow-policy", "出站队列溢出策略 (drop 丢弃消息 / close 断开连接)", (*stringValue)(&c.WebSocket.OverflowPolicy)},
+
+		{"ROOM_TTL", "room-ttl", "房间默认有效期 (无人使用的房间超过后被清理)", (*durationValue)(&c.Rooms.TTL)},
+		{"ROOM_MIN_TTL", "room-min-ttl", "创建房间时可请求的最短有效期", (*durationValue)(&c.Rooms.MinTTL)},
+		{"ROOM_MAX_TTL", "room-max-ttl", "创建房间时可请求的最长有效期", (*durationValue)(&c.Rooms.MaxTTL)},
+		{"ROOM_IDLE_TIMEOUT", "room-idle-timeout", "所有人离开后房间保留的时间，期间可重新加入", (*durationValue)(&c.Rooms.IdleTimeout)},
+		{"ROOM_MAX_LIFETIME", "room-max-lifetime", "房间最长使用时间，超过后断开所有连接，0 表示不限制", (*durationValue)(&c.Rooms.MaxLifetime)},
+		{"ROOM_CLEANUP_INTERVAL", "room-cleanup-interval", "过期房间清理间隔", (*durationValue)(&c.Rooms.CleanupInterval)},
+		{"ROOM_CODE_LENGTH", "code-length", "取件码长度", (*intValue)(&c.Rooms.CodeLength)},
+		{"ROOM_RESERVED_CODES", "room-reserved-codes", "保留房间码，逗号分隔，格式为 房间码:API Key 名称 (或 房间码:basic:alice 等身份)", (*stringListValue)(&c.Rooms.Reserved)},
+		{"ROOM_PERSISTENT_CODES", "room-persistent-codes", "持久房间码，逗号分隔，不会被过期清理，为空时重置有效期", (*stringListValue)(&c.Rooms.Persistent)},
+
+		{"RELAY_ENABLED", "relay-enabled", "是否启用数据中继 (P2P 失败时的降级方案)", (*boolValue)(&c.Relay.Enabled)},
+		{"RELAY_BUFFER_SIZE", "relay-buffer-size", "中继读写缓冲区大小 (字节)", (*intValue)(&c.Relay.BufferSize)},
+		{"RELAY_MAX_MESSAGE_SIZE", "relay-max-message-size", "中继单条消息最大尺寸 (字节)", (*int64Value)(&c.Relay.MaxMessageSize)},
+
+		{"ALLOWED_ORIGINS", "allowed-origins", "允许的跨域来源 (CORS 和 WebSocket 共用)，逗号分隔，支持 *、https://*.example.com、regex:<表达式>；默认只允许同源", (*stringListValue)(&c.Origins.Allowed)},
+		{"CORS_ALLOW_CREDENTIALS", "cors-allow-credentials", "跨域请求是否允许携带凭据", (*boolValue)(&c.CORS.AllowCredentials)},
+		{"CORS_MAX_AGE", "cors-max-age", "跨域预检请求缓存时间 (秒)", (*intValue)(&c.CORS.MaxAge)},
+
+		{"TLS_CERT", "tls-cert", "HTTPS 证书 (可选，收到 SIGHUP 或文件变化时自动重新加载)", (*stringValue)(&c.TLS.Cert)},
+		{"TLS_KEY", "tls-key", "HTTPS 私钥", (*stringValue)(&c.TLS.Key)},
+		{"HTTP_REDIRECT_PORT", "http-redirect-port", "启用 HTTPS 时的 HTTP 重定向端口，0 表示不启用", (*intValue)(&c.TLS.RedirectPort)},
+
+		{"CSP", "csp", "前端页面的 Content-Security-Policy，页面内联脚本的哈希自动加入 script-src，off 表示不设置", (*stringValue)(&c.Security.CSP)},
+		{"CSP_REPORT_ONLY", "csp-report-only", "CSP 只上报违规 (到 /api/csp-report) 不拦截", (*boolValue)(&c.Security.CSPReportOnly)},
+		{"HSTS_MAX_AGE", "hsts-max-age", "HTTPS 响应的 Strict-Transport-Security 有效期 (秒)，0 表示不设置", (*intValue)(&c.Security.HSTSMaxAge)},
+		{"REFERRER_POLICY", "referrer-policy", "Referrer-Policy 响应头", (*stringValue)(&c.Security.ReferrerPolicy)},
+		{"PERMISSIONS_POLICY", "permissions-policy", "Permissions-Policy 响应头", (*stringValue)(&c.Security.PermissionsPolicy)},
+		{"COOP", "coop", "Cross-Origin-Opener-Policy 响应头 (same-origin / same-origin-allow-popups / unsafe-none)", (*stringValue)(&c.Security.COOP)},
+		{"COEP", "coep", "Cross-Origin-Embedder-Policy 响应头 (require-corp / credentialless)，为空时不设置", (*stringValue)(&c.Security.COEP)},
+
+		{"CLIENT_CONFIG_INJECT", "client-config-inject", "在前端页面中注入 window.__CHUAN_CONFIG__ (内容同 /api/client-config)", (*boolValue)(&c.Client.Inject)},
+		{"ICE_SERVERS", "ice-servers", "下发给前端的 STUN/TURN 服务器，逗号分隔，为空时前端使用内置列表", (*stringListValue)(&c.Client.ICEServers)},
+		{"TURN_USERNAME", "turn-username", "TURN 服务器用户名", (*stringValue)(&c.Client.TURNUsername)},
+		{"TURN_CREDENTIAL", "turn-credential", "TURN 服务器密码 (会下发给浏览器)", (*stringValue)(&c.Client.TURNCredential)},
+		{"DESKTOP_SHARE", "desktop-share", "前端是否显示桌面共享", (*boolValue)(&c.Client.DesktopShare)},
+		{"MAX_FILE_SIZE", "max-file-size", "前端允许发送的单个文件最大字节数，0 表示不限制", (*int64Value)(&c.Client.MaxFileSize)},
+		{"BRAND_NAME", "brand-name", "前端显示的站点名称", (*stringValue)(&c.Client.BrandName)},
+		{"BRAND_LOGO", "brand-logo", "前端显示的站点图标地址", (*stringValue)(&c.Client.BrandLogo)},
+		{"PUBLIC_URL", "public-url", "对外访问地址 (用于生成分享链接)，为空时使用当前页面地址", (*stringValue)(&c.Client.PublicURL)},
+
+		{"WT_PORT", "wt-port", "WebTransport 中继 UDP 端口，0 表示不启用", (*intValue)(&c.WebTransport.Port)},
+		{"WT_CERT", "wt-cert", "WebTransport 证书 (未设置时复用 TLS 证书，均未设置时使用自签名证书)", (*stringValue)(&c.WebTransport.Cert)},
+		{"WT_KEY", "wt-key", "WebTransport 私钥", (*stringValue)(&c.WebTransport.Key)},
+
+		{"ADMIN_TOKEN", "admin-token", "管理接口 (/admin/api) 令牌，为空时不启用", (*stringValue)(&c.Admin.Token)},
+
+		{"AUTH_CREATE_ROOM", "auth-create-room", "创建房间是否需要身份验证", (*boolValue)(&c.Auth.CreateRoom)},
+		{"AUTH_JOIN", "auth-join", "加入房间的认证策略 (open 凭取件码加入 / sender 仅发送方需认证 / all 都需认证)", (*stringValue)(&c.Auth.Join)},
+		{"AUTH_API_KEYS", "auth-api-keys", "静态 API Key，逗号分隔，格式为 名称:密钥 或 密钥", (*stringListValue)(&c.Auth.APIKeys)},
+		{"AUTH_HTPASSWD", "auth-htpasswd", "HTTP Basic 认证使用的 bcrypt htpasswd 文件 (htpasswd -B 生成)", (*stringValue)(&c.Auth.Htpasswd)},
+		{"OIDC_ISSUER", "oidc-issuer", "OIDC 令牌签发者 (未设置 OIDC_JWKS_URL 时通过 OIDC 发现获取 JWKS)", (*stringValue)(&c.Auth.OIDC.Issuer)},
+		{"OIDC_JWKS_URL", "oidc-jwks-url", "OIDC JWKS 地址", (*stringValue)(&c.Auth.OIDC.JWKSURL)},
+		{"OIDC_AUDIENCE", "oidc-audience", "OIDC 令牌的 aud，设置后校验", (*stringValue)(&c.Auth.OIDC.Audience)},
+
+		{"ACCOUNTING_DB", "accounting-db", "用量统计数据库文件 (bbolt)，为空时只保存在内存中", (*stringValue)(&c.Accounting.DB)},
+		{"QUOTA_DAILY_ROOMS", "quota-daily-rooms", "每个身份每日创建房间数上限，0 表示不限制", (*int64Value)(&c.Accounting.DailyRooms)},
+		{"QUOTA_MONTHLY_ROOMS", "quota-monthly-rooms", "每个身份每月创建房间数上限", (*int64Value)(&c.Accounting.MonthlyRooms)},
+		{"QUOTA_DAILY_RELAY_BYTES", "quota-daily-relay-bytes", "每个身份每日中继流量上限 (字节)", (*int64Value)(&c.Accounting.DailyRelayBytes)},
+		{"QUOTA_MONTHLY_RELAY_BYTES", "quota-monthly-relay-bytes", "每个身份每月中继流量上限 (字节)", (*int64Value)(&c.Accounting.MonthlyRelayBytes)},
+
+		{"AUDIT_FILE", "audit-file", "审计日志文件 (JSONL)，为空时不记录", (*stringValue)(&c.Audit.File)},
+		{"AUDIT_MAX_SIZE", "audit-max-size", "审计日志单个文件的最大字节数，超过后轮转，0 表示不轮转", (*int64Value)(&c.Audit.MaxSize)},
+		{"AUDIT_MAX_FILES", "audit-max-files", "保留的已轮转审计日志文件数，0 表示全部保留", (*intValue)(&c.Audit.MaxFiles)},
+		{"AUDIT_HASH_CHAIN", "audit-hash-chain", "审计日志是否启用哈希链 (用于发现篡改)", (*boolValue)(&c.Audit.HashChain)},
+
+		{"WEBHOOK_URLS", "webhook-urls", "接收房间事件的 webhook 地址，逗号分隔，为空时不启用", (*stringListValue)(&c.Webhooks.URLs)},
+		{"WEBHOOK_SECRET", "webhook-secret", "webhook 请求的 HMAC-SHA256 签名密钥", (*stringValue)(&c.Webhooks.Secret)},
+		{"WEBHOOK_EVENTS", "webhook-events", "订阅的事件类型，逗号分隔", (*stringListValue)(&c.Webhooks.Events)},
+		{"WEBHOOK_TIMEOUT", "webhook-timeout", "webhook 单次请求超时", (*durationValue)(&c.Webhooks.Timeout)},
+		{"WEBHOOK_MAX_ATTEMPTS", "webhook-max-attempts", "每个事件最多投递次数 (失败后按指数退避重试)", (*intValue)(&c.Webhooks.MaxAttempts)},
+		{"WEBHOOK_DEAD_LETTER", "webhook-dead-letter", "投递失败的事件写入的死信文件 (JSONL)", (*stringValue)(&c.Webhooks.DeadLetter)},
+		{"WEBHOOK_INCLUDE_IDENTITY", "webhook-include-identity", "webhook 请求体是否包含用户身份 (identity、data.owner)", (*boolValue)(&c.Webhooks.IncludeIdentity)},
+		{"WEBHOOK_INCLUDE_REMOTE_ADDR", "webhook-include-remote-addr", "webhook 请求体是否包含客户端 IP", (*boolValue)(&c.Webhooks.IncludeRemoteAddr)},
+
+		{"LOG_LEVEL", "log-level", "日志级别 (debug 输出逐条消息日志 / info / warn 只输出警告和错误 / error 只输出错误)", (*stringValue)(&c.Log.Level)},
+	}
+}
+
+// applyEnv 用环境变量覆盖配置
+func (c *Config) applyEnv() error {
+	var errs []error
+	for _, s := range c.settings() {
+		if value := os.Getenv(s.env); value != "" {
+			if err := s.value.Set(value); err != nil {
+				errs = append(errs, fmt.Errorf("环境变量 %s 的值无效 %q: %v", s.env, value, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// newFlagSet 创建绑定到配置字段的命令行参数集合
+func newFlagSet(c *Config) *flag.FlagSet {
+	fs := flag.NewFlagSet("file-transfer-server", flag.ContinueOnError)
+	fs.Usage = showHelp
+	fs.String("config", "", "YAML/TOML 配置文件路径 (可通过 CHUAN_CONFIG 环境变量设置)")
+	for _, s := range c.settings() {
+		fs.Var(s.value, s.flag, fmt.Sprintf("%s (可通过 %s 环境变量设置)", s.usage, s.env))
+	}
+	return fs
+}
+
+// configFilePath 确定配置文件路径：-config 参数 > CHUAN_CONFIG 环境变量 > 当前目录下的默认文件
+func configFilePath(args []string) string {
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+
+	if path := os.Getenv("CHUAN_CONFIG"); path != "" {
+		return path
+	}
+
+	for _, name := range defaultConfigFiles {
+		if fileExists(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// loadConfigFile 根据扩展名解析 YAML 或 TOML 配置文件，未知的配置项视为错误
+func loadConfigFile(path string, config *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(config); err != nil && err != io.EOF {
+			return fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(data), config)
+		if err != nil {
+			return fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			keys := make([]string, len(undecoded))
+			for i, key := range undecoded {
+				keys[i] = key.String()
+			}
+			return fmt.Errorf("配置文件 %s 包含未知配置项: %s", path, strings.Join(keys, ", "))
+		}
+	default:
+		return fmt.Errorf("不支持的配置文件格式: %s (仅支持 .yaml/.yml/.toml)", path)
+	}
+	return nil
+}
+
+// writeConfig 以 YAML 或 TOML 格式输出配置，敏感配置项的值会被隐藏
+func writeConfig(w io.Writer, config *Config, format string) error {
+	masked := *config
+	for _, s := range masked.settings() {
+		if secretSettings[s.env] {
+			s.value.Set(maskSecret(s.value.String()))
+		}
+	}
+	config = &masked
+
+	switch format {
+	case "yaml", "yml":
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(config); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case "toml":
+		return toml.NewEncoder(w).Encode(config)
+	default:
+		return fmt.Errorf("不支持的输出格式: %s (仅支持 yaml/toml)", format)
+	}
+}
+
+// runConfigCommand 处理 config 子命令
+func runConfigCommand(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return fmt.Errorf("用法: config print [-format yaml|toml] [参数]")
+	}
+	args = args[1:]
+
+	// 取出 -format，其余参数交给 loadConfig，保证输出与实际启动时完全一致
+	format := "yaml"
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !strings.HasPrefix(args[i], "-") || name != "format" {
+			rest = append(rest, args[i])
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return fmt.Errorf("-format 缺少参数")
+			}
+			i++
+			value = args[i]
+		}
+		format = value
+	}
+
+	config, err := loadConfig(rest)
+	if err != nil {
+		return err
+	}
+	return writeConfig(os.Stdout, config, format)
+}
+
+// 以下类型把配置字段适配为 flag.Value，供环境变量和命令行参数共用同一套解析逻辑
+
+type intValue int
+
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*v = intValue(n)
+	return nil
+}
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+type int64Value int64
+
+func (v *int64Value) Set(s string) error {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*v = int64Value(n)
+	return nil
+}
+
+func (v *int64Value) String() string { return strconv.FormatInt(int64(*v), 10) }
+
+type boolValue bool
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+func (v *boolValue) String() string   { return strconv.FormatBool(bool(*v)) }
+func (v *boolValue) IsBoolFlag() bool { return true }
+
+type stringValue string
+
+func (v *stringValue) Set(s string) error {
+	*v = stringValue(s)
+	return nil
+}
+
+func (v *stringValue) String() string { return string(*v) }
+
+type durationValue time.Duration
+
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+
+// stringListValue 逗号分隔的字符串列表
+type stringListValue []string
+
+func (v *stringListValue) Set(s string) error {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	*v = list
+	return nil
+}
+
+func (v *stringListValue) String() string { return strings.Join(*v, ",") }
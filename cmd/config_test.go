@@ -0,0 +1,304 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// isolateConfig 在临时目录中加载配置，清除会影响结果的环境变量，结束后撤销 .chuan.env 设置的变量
+func isolateConfig(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("CHUAN_CONFIG", "")
+	for _, s := range defaultConfig().settings() {
+		t.Setenv(s.env, "")
+	}
+	t.Cleanup(func() {
+		for key := range envFileKeys {
+			os.Unsetenv(key)
+		}
+		envFileKeys = map[string]bool{}
+	})
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestConfigPrecedence 命令行参数 > 环境变量 > .chuan.env > 配置文件 > 默认值，每一层只覆盖自己设置的配置项
+func TestConfigPrecedence(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     bool
+		envFile  bool
+		env      bool
+		flag     bool
+		wantPort int
+	}{
+		{"默认值", false, false, false, false, 8080},
+		{"配置文件覆盖默认值", true, false, false, false, 1001},
+		{".chuan.env 覆盖配置文件", true, true, false, false, 1002},
+		{"环境变量覆盖 .chuan.env", true, true, true, false, 1003},
+		{"命令行参数覆盖环境变量", true, true, true, true, 1004},
+		{"命令行参数覆盖配置文件", true, false, false, true, 1004},
+		{"环境变量覆盖配置文件", true, false, true, false, 1003},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateConfig(t)
+			var args []string
+			if tt.file {
+				// 配置文件同时设置其他配置项，确认不会被只设置了 PORT 的上层覆盖
+				writeFile(t, "chuan.yaml", "server:\n  port: 1001\nlog:\n  level: warn\n")
+			}
+			if tt.envFile {
+				writeFile(t, ".chuan.env", "PORT=1002\n")
+			}
+			if tt.env {
+				t.Setenv("PORT", "1003")
+			}
+			if tt.flag {
+				args = []string{"-port", "1004"}
+			}
+
+			config, err := loadConfig(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Server.Port != tt.wantPort {
+				t.Errorf("server.port = %d, 期望 %d", config.Server.Port, tt.wantPort)
+			}
+			wantLevel := defaultConfig().Log.Level
+			if tt.file {
+				wantLevel = "warn"
+			}
+			if config.Log.Level != wantLevel {
+				t.Errorf("log.level = %q, 期望 %q", config.Log.Level, wantLevel)
+			}
+		})
+	}
+}
+
+// TestEnvFileDoesNotOverrideEnv .chuan.env 只设置尚未设置的环境变量，重新加载时撤销上次设置的变量
+func TestEnvFileDoesNotOverrideEnv(t *testing.T) {
+	isolateConfig(t)
+	t.Setenv("PORT", "1003")
+	writeFile(t, ".chuan.env", "PORT=1002\nLOG_LEVEL='debug'\n")
+
+	config, err := loadConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Server.Port != 1003 || config.Log.Level != "debug" {
+		t.Fatalf("port = %d, log.level = %q", config.Server.Port, config.Log.Level)
+	}
+	if envFileKeys["PORT"] || !envFileKeys["LOG_LEVEL"] {
+		t.Fatalf("只应记录由文件设置的变量: %v", envFileKeys)
+	}
+
+	// 从文件中删除的变量在重新加载后不再生效
+	writeFile(t, ".chuan.env", "")
+	if config, err = loadConfig(nil); err != nil {
+		t.Fatal(err)
+	}
+	if config.Log.Level != defaultConfig().Log.Level {
+		t.Fatalf("log.level = %q, 删除后应恢复默认值", config.Log.Level)
+	}
+}
+
+// TestConfigFilePath -config 参数 > CHUAN_CONFIG > 当前目录的 chuan.yaml
+func TestConfigFilePath(t *testing.T) {
+	isolateConfig(t)
+	writeFile(t, "chuan.yaml", "server:\n  port: 1001\n")
+	writeFile(t, "env.yaml", "server:\n  port: 1002\n")
+	writeFile(t, "flag.toml", "[server]\nport = 1003\n")
+
+	load := func(args ...string) int {
+		t.Helper()
+		config, err := loadConfig(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return config.Server.Port
+	}
+	if port := load(); port != 1001 {
+		t.Errorf("自动加载 chuan.yaml: port = %d", port)
+	}
+	t.Setenv("CHUAN_CONFIG", "env.yaml")
+	if port := load(); port != 1002 {
+		t.Errorf("CHUAN_CONFIG: port = %d", port)
+	}
+	if port := load("-config", "flag.toml"); port != 1003 {
+		t.Errorf("-config: port = %d", port)
+	}
+	if port := load("-config=flag.toml"); port != 1003 {
+		t.Errorf("-config=: port = %d", port)
+	}
+}
+
+// TestConfigFileUnknownKey 配置文件中的未知配置项被拒绝，避免拼写错误被忽略
+func TestConfigFileUnknownKey(t *testing.T) {
+	isolateConfig(t)
+	writeFile(t, "chuan.yaml", "server:\n  prot: 1001\n")
+	if _, err := loadConfig(nil); err == nil {
+		t.Fatal("未知配置项应返回错误")
+	}
+}
+
+// TestLoadConfigRejectsInvalid 任何一层设置的无效值都会在加载时被拒绝
+func TestLoadConfigRejectsInvalid(t *testing.T) {
+	isolateConfig(t)
+	t.Setenv("PORT", "70000")
+	_, err := loadConfig(nil)
+	if err == nil || !strings.Contains(err.Error(), "配置无效") || !strings.Contains(err.Error(), "PORT") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+// TestValidate 每条校验规则单独违反时都会被拒绝，错误信息指出对应的配置项
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	cert, key := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeFile(t, cert, "cert")
+	writeFile(t, key, "key")
+
+	if err := defaultConfig().Validate(); err != nil {
+		t.Fatalf("默认配置应通过校验: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string
+	}{
+		{"端口为 0", func(c *Config) { c.Server.Port = 0 }, "PORT"},
+		{"端口超出范围", func(c *Config) { c.Server.Port = 65536 }, "PORT"},
+		{"部署路径不以 / 开头", func(c *Config) { c.Server.BasePath = "tools" }, "BASE_PATH"},
+		{"部署路径包含 ..", func(c *Config) { c.Server.BasePath = "/tools/../admin" }, "BASE_PATH"},
+		{"可信代理无效", func(c *Config) { c.Server.TrustedProxies = []string{"proxy.local"} }, "TRUSTED_PROXIES"},
+		{"可信代理 CIDR 无效", func(c *Config) { c.Server.TrustedProxies = []string{"10.0.0.0/33"} }, "TRUSTED_PROXIES"},
+		{"读超时为 0", func(c *Config) { c.Server.ReadTimeout = 0 }, "HTTP_READ_TIMEOUT"},
+		{"写超时为 0", func(c *Config) { c.Server.WriteTimeout = 0 }, "HTTP_WRITE_TIMEOUT"},
+		{"空闲超时为 0", func(c *Config) { c.Server.IdleTimeout = 0 }, "HTTP_IDLE_TIMEOUT"},
+		{"关闭超时为 0", func(c *Config) { c.Server.ShutdownTimeout = 0 }, "SHUTDOWN_TIMEOUT"},
+
+		{"心跳间隔为 0", func(c *Config) { c.WebSocket.PingInterval = 0 }, "WS_PING_INTERVAL"},
+		{"心跳超时不大于心跳间隔", func(c *Config) { c.WebSocket.PongTimeout = c.WebSocket.PingInterval }, "WS_PONG_TIMEOUT"},
+		{"发送队列为 0", func(c *Config) { c.WebSocket.SendQueueSize = 0 }, "WS_SEND_QUEUE_SIZE"},
+		{"WebSocket 写超时为 0", func(c *Config) { c.WebSocket.WriteTimeout = 0 }, "WS_WRITE_TIMEOUT"},
+		{"溢出策略未知", func(c *Config) { c.WebSocket.OverflowPolicy = "block" }, "WS_OVERFLOW_POLICY"},
+
+		{"最短有效期为 0", func(c *Config) { c.Rooms.MinTTL = 0 }, "ROOM_MIN_TTL"},
+		{"默认有效期小于最短有效期", func(c *Config) { c.Rooms.TTL = c.Rooms.MinTTL - time.Second }, "ROOM_TTL"},
+		{"默认有效期大于最长有效期", func(c *Config) { c.Rooms.TTL = c.Rooms.MaxTTL + time.Second }, "ROOM_MAX_TTL"},
+		{"空闲时间为 0", func(c *Config) { c.Rooms.IdleTimeout = 0 }, "ROOM_IDLE_TIMEOUT"},
+		{"最长使用时间小于最长有效期", func(c *Config) { c.Rooms.MaxLifetime = c.Rooms.MaxTTL - time.Second }, "ROOM_MAX_LIFETIME"},
+		{"清理间隔为 0", func(c *Config) { c.Rooms.CleanupInterval = 0 }, "ROOM_CLEANUP_INTERVAL"},
+		{"房间码过短", func(c *Config) { c.Rooms.CodeLength = 3 }, "ROOM_CODE_LENGTH"},
+		{"房间码过长", func(c *Config) { c.Rooms.CodeLength = 17 }, "ROOM_CODE_LENGTH"},
+		{"保留房间码缺少身份", func(c *Config) { c.Rooms.Reserved = []string{"QA2024"} }, "ROOM_RESERVED_CODES"},
+		{"保留房间码长度不符", func(c *Config) { c.Rooms.Reserved = []string{"QA20:ci"} }, "ROOM_RESERVED_CODES"},
+		{"保留房间码重复", func(c *Config) { c.Rooms.Reserved = []string{"QA2024:ci", "qa2024:qa"} }, "ROOM_RESERVED_CODES"},
+		{"持久房间码包含无效字符", func(c *Config) { c.Rooms.Persistent = []string{"QA202O"} }, "ROOM_PERSISTENT_CODES"},
+
+		{"中继缓冲区为 0", func(c *Config) { c.Relay.BufferSize = 0 }, "RELAY_BUFFER_SIZE"},
+		{"中继消息上限为 0", func(c *Config) { c.Relay.MaxMessageSize = 0 }, "RELAY_MAX_MESSAGE_SIZE"},
+
+		{"来源规则无效", func(c *Config) { c.Origins.Allowed = []string{"transfer.example.com"} }, "ALLOWED_ORIGINS"},
+		{"任意来源携带凭据", func(c *Config) {
+			c.Origins.Allowed = []string{"*"}
+			c.CORS.AllowCredentials = true
+		}, "CORS_ALLOW_CREDENTIALS"},
+		{"预检缓存时间为负数", func(c *Config) { c.CORS.MaxAge = -1 }, "CORS_MAX_AGE"},
+
+		{"只设置证书", func(c *Config) { c.TLS.Cert = cert }, "TLS_KEY"},
+		{"证书文件不存在", func(c *Config) { c.TLS.Cert, c.TLS.Key = filepath.Join(dir, "missing.pem"), key }, "TLS_CERT"},
+		{"私钥文件不存在", func(c *Config) { c.TLS.Cert, c.TLS.Key = cert, filepath.Join(dir, "missing.pem") }, "TLS_KEY"},
+		{"重定向端口未启用 HTTPS", func(c *Config) { c.TLS.RedirectPort = 80 }, "HTTP_REDIRECT_PORT"},
+		{"重定向端口与服务端口相同", func(c *Config) {
+			c.TLS.Cert, c.TLS.Key = cert, key
+			c.TLS.RedirectPort = c.Server.Port
+		}, "HTTP_REDIRECT_PORT"},
+		{"重定向端口超出范围", func(c *Config) {
+			c.TLS.Cert, c.TLS.Key = cert, key
+			c.TLS.RedirectPort = 65536
+		}, "HTTP_REDIRECT_PORT"},
+
+		{"CSP 无效", func(c *Config) { c.Security.CSP = "default-src 'self'\nscript-src *" }, "CSP"},
+		{"HSTS 为负数", func(c *Config) { c.Security.HSTSMaxAge = -1 }, "HSTS_MAX_AGE"},
+		{"Referrer-Policy 未知", func(c *Config) { c.Security.ReferrerPolicy = "sometimes" }, "REFERRER_POLICY"},
+		{"Permissions-Policy 包含换行", func(c *Config) { c.Security.PermissionsPolicy = "camera=()\r\nX-Evil: 1" }, "PERMISSIONS_POLICY"},
+		{"COOP 未知", func(c *Config) { c.Security.COOP = "same-site" }, "COOP"},
+		{"COEP 未知", func(c *Config) { c.Security.COEP = "require-cors" }, "COEP"},
+
+		{"ICE 地址协议无效", func(c *Config) { c.Client.ICEServers = []string{"http://stun.example.com"} }, "ICE_SERVERS"},
+		{"ICE 地址只有协议", func(c *Config) { c.Client.ICEServers = []string{"stun:"} }, "ICE_SERVERS"},
+		{"TURN 缺少凭据", func(c *Config) { c.Client.ICEServers = []string{"turn:turn.example.com:3478"} }, "TURN_CREDENTIAL"},
+		{"文件大小为负数", func(c *Config) { c.Client.MaxFileSize = -1 }, "MAX_FILE_SIZE"},
+		{"公开地址协议无效", func(c *Config) { c.Client.PublicURL = "ftp://chuan.example.com" }, "PUBLIC_URL"},
+		{"公开地址包含查询参数", func(c *Config) { c.Client.PublicURL = "https://chuan.example.com/?a=1" }, "PUBLIC_URL"},
+
+		{"WebTransport 端口超出范围", func(c *Config) { c.WebTransport.Port = 70000 }, "WT_PORT"},
+		{"只设置 WebTransport 证书", func(c *Config) { c.WebTransport.Cert = cert }, "WT_KEY"},
+
+		{"日志级别未知", func(c *Config) { c.Log.Level = "verbose" }, "LOG_LEVEL"},
+		{"管理令牌过短", func(c *Config) { c.Admin.Token = "short" }, "ADMIN_TOKEN"},
+
+		{"配额为负数", func(c *Config) { c.Accounting.MonthlyRelayBytes = -1 }, "QUOTA_"},
+		{"审计日志大小为负数", func(c *Config) { c.Audit.MaxSize = -1 }, "AUDIT_MAX_SIZE"},
+		{"审计日志数量为负数", func(c *Config) { c.Audit.MaxFiles = -1 }, "AUDIT_MAX_FILES"},
+
+		{"webhook 地址无效", func(c *Config) { c.Webhooks.URLs = []string{"hooks.example.com/x"} }, "WEBHOOK_URLS"},
+		{"webhook 事件未知", func(c *Config) { c.Webhooks.Events = []string{"room.renamed"} }, "WEBHOOK_EVENTS"},
+		{"webhook 超时为 0", func(c *Config) { c.Webhooks.Timeout = 0 }, "WEBHOOK_TIMEOUT"},
+		{"webhook 尝试次数为 0", func(c *Config) { c.Webhooks.MaxAttempts = 0 }, "WEBHOOK_MAX_ATTEMPTS"},
+
+		{"加入策略未知", func(c *Config) {
+			c.Auth.Join = "everyone"
+			c.Auth.APIKeys = []string{"ci:0123456789abcdef"}
+		}, "只能是"},
+		{"htpasswd 文件不存在", func(c *Config) { c.Auth.Htpasswd = filepath.Join(dir, "missing.htpasswd") }, "auth 配置无效"},
+		{"需要认证但未配置凭据", func(c *Config) { c.Auth.CreateRoom = true }, "AUTH_CREATE_ROOM"},
+		{"接收方需要认证但未配置凭据", func(c *Config) { c.Auth.Join = joinAll }, "AUTH_JOIN"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := defaultConfig()
+			tt.modify(config)
+			err := config.Validate()
+			if err == nil {
+				t.Fatal("应被拒绝")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("错误信息应包含 %q: %v", tt.want, err)
+			}
+			// 每个用例只违反一条规则
+			if joined, ok := err.(interface{ Unwrap() []error }); ok && len(joined.Unwrap()) != 1 {
+				t.Fatalf("期望 1 个错误, 实际 %d: %v", len(joined.Unwrap()), err)
+			}
+		})
+	}
+}
+
+// TestValidateReportsAllErrors 同时存在多个错误时一次全部报告
+func TestValidateReportsAllErrors(t *testing.T) {
+	config := defaultConfig()
+	config.Server.Port = 0
+	config.Log.Level = "verbose"
+	config.Admin.Token = "short"
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("应被拒绝")
+	}
+	for _, want := range []string{"PORT", "LOG_LEVEL", "ADMIN_TOKEN"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("错误信息应包含 %q: %v", want, err)
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"fmt"
+	"log"
 	"os"
+
+	"chuan/internal/logging"
 )
 
 func main() {
@@ -11,15 +15,53 @@ func main() {
 		return
 	}
 
+	// config 子命令：输出最终生效的配置
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := runConfigCommand(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// audit 子命令：校验审计日志的哈希链
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		if err := runAuditCommand(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// room 子命令：订阅房间事件，等待对方上线
+	if len(os.Args) > 1 && os.Args[1] == "room" {
+		if err := runRoomCommand(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// 加载配置
-	config := loadConfig()
+	config, err := loadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
 
 	// 记录配置信息
 	logConfig(config)
 
-	// 设置路由
-	router := setupRouter()
+	logging.SetLevel(config.Log.level())
+
+	// 初始化处理器并设置路由
+	h, err := setupHandler(config)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	rt := newHTTPRuntime(config)
+	router := setupRouter(config, h, rt)
 
-	// 运行服务器（包含启动和优雅关闭）
-	RunServer(config, router)
+	// 运行服务器（包含启动和优雅关闭），SIGHUP 时重新加载配置
+	reloader := newConfigReloader(os.Args[1:], config, h, rt)
+	RunServer(config, router, h, reloader)
 }
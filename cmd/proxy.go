@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+
+	"chuan/internal/api"
+)
+
+// 经过反向代理时 r.RemoteAddr 是代理的地址，真实的客户端 IP 和协议在代理添加的请求头中。
+// 这些请求头可以由客户端任意伪造，只有连接来自 server.trusted_proxies 中的地址时才解析，
+// 否则删除，之后的处理器（包括 X-Forwarded-Prefix）都不会读到伪造的值。
+
+// forwardingHeaders 由反向代理添加、只接受可信代理设置的请求头
+var forwardingHeaders = []string{"Forwarded", "X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Prefix", "X-Real-IP"}
+
+// parseTrustedProxies 解析 IP 或 CIDR 列表，单个 IP 视为只包含该地址的网段
+func parseTrustedProxies(list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, entry := range list {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("无效的 CIDR: %q", entry)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 IP: %q", entry)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// proxySet 可信代理的网段
+type proxySet []netip.Prefix
+
+func (s proxySet) contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range s {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedHop 转发链中的一个地址，proto 为该地址访问下一跳时使用的协议（只有 Forwarded 请求头提供）
+type forwardedHop struct {
+	addr  netip.Addr
+	proto string
+}
+
+// trustedProxies 可在运行时替换可信代理列表的中间件，解析出的客户端 IP 和协议记录到请求上下文（api.ClientIP、api.Scheme）
+type trustedProxies struct {
+	current atomic.Pointer[proxySet]
+}
+
+func newTrustedProxies(config *Config) *trustedProxies {
+	t := &trustedProxies{}
+	t.Update(config)
+	return t
+}
+
+// Update 替换可信代理列表，之后的请求立即生效
+func (t *trustedProxies) Update(config *Config) {
+	// 配置已通过 Validate 校验
+	prefixes, _ := parseTrustedProxies(config.Server.TrustedProxies)
+	set := proxySet(prefixes)
+	t.current.Store(&set)
+}
+
+// Handler 解析真实的客户端 IP 和协议；连接不是来自可信代理时删除转发相关的请求头
+func (t *trustedProxies) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		set := *t.current.Load()
+		peer := remoteIP(r)
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+
+		if !peer.IsValid() || !set.contains(peer) {
+			if hasForwardingHeaders(r) {
+				r = r.Clone(r.Context())
+				for _, name := range forwardingHeaders {
+					r.Header.Del(name)
+				}
+			}
+			next.ServeHTTP(w, api.WithClient(r, clientIPString(peer, r), scheme))
+			return
+		}
+
+		client := forwardedHop{addr: peer}
+		proto := ""
+		switch {
+		case r.Header.Get("Forwarded") != "":
+			client = set.client(parseForwarded(r.Header.Values("Forwarded")), peer)
+			proto = client.proto
+		case r.Header.Get("X-Forwarded-For") != "":
+			client = set.client(parseForwardedFor(r.Header.Values("X-Forwarded-For")), peer)
+		default:
+			if addr, ok := parseNode(r.Header.Get("X-Real-IP")); ok {
+				client.addr = addr
+			}
+		}
+		if proto == "" {
+			// 经过多层代理时第一个值是面向客户端的代理设置的
+			proto, _, _ = strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+		}
+		if proto = strings.ToLower(strings.TrimSpace(proto)); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		next.ServeHTTP(w, api.WithClient(r, clientIPString(client.addr, r), scheme))
+	})
+}
+
+// client 从右向左跳过可信代理，返回第一个不可信的地址，即真实的客户端
+// 遇到 unknown 或无法解析的地址时无法继续追溯，返回最后一个可信的地址；整条链都可信时返回最左边的地址
+func (s proxySet) client(hops []forwardedHop, peer netip.Addr) forwardedHop {
+	client := forwardedHop{addr: peer}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !hops[i].addr.IsValid() {
+			break
+		}
+		client = hops[i]
+		if !s.contains(client.addr) {
+			break
+		}
+	}
+	return client
+}
+
+// parseForwardedFor 解析 X-Forwarded-For: client, proxy1, proxy2
+func parseForwardedFor(values []string) []forwardedHop {
+	var hops []forwardedHop
+	for _, value := range values {
+		for _, node := range strings.Split(value, ",") {
+			addr, _ := parseNode(node)
+			hops = append(hops, forwardedHop{addr: addr})
+		}
+	}
+	return hops
+}
+
+// parseForwarded 解析 RFC 7239 Forwarded: for=192.0.2.60;proto=https, for="[2001:db8::1]:4711"
+func parseForwarded(values []string) []forwardedHop {
+	var hops []forwardedHop
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			var hop forwardedHop
+			for _, pair := range strings.Split(element, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				val = strings.Trim(val, `"`)
+				switch strings.ToLower(key) {
+				case "for":
+					hop.addr, _ = parseNode(val)
+				case "proto":
+					hop.proto = val
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// parseNode 解析转发请求头中的地址，可以带端口，IPv6 可以带方括号
+func parseNode(node string) (netip.Addr, bool) {
+	node = strings.TrimSpace(node)
+	if addrPort, err := netip.ParseAddrPort(node); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	node = strings.TrimSuffix(strings.TrimPrefix(node, "["), "]")
+	addr, err := netip.ParseAddr(node)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// remoteIP 返回连接的对端地址
+func remoteIP(r *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, _ := netip.ParseAddr(host)
+	return addr.Unmap()
+}
+
+// clientIPString 地址无效时（如 RemoteAddr 不是 IP 地址）使用原始的 RemoteAddr
+func clientIPString(addr netip.Addr, r *http.Request) string {
+	if addr.IsValid() {
+		return addr.String()
+	}
+	return r.RemoteAddr
+}
+
+func hasForwardingHeaders(r *http.Request) bool {
+	for _, name := range forwardingHeaders {
+		if r.Header.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"chuan/internal/api"
+)
+
+// proxyResult 经过中间件后，之后的处理器看到的客户端信息和请求头
+type proxyResult struct {
+	ip     string
+	scheme string
+	prefix string
+	xff    string
+}
+
+func serveThroughProxies(t *testing.T, trusted []string, remoteAddr string, header http.Header) proxyResult {
+	t.Helper()
+	config := defaultConfig()
+	config.Server.TrustedProxies = trusted
+	var got proxyResult
+	handler := newTrustedProxies(config).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = proxyResult{
+			ip:     api.ClientIP(r),
+			scheme: api.Scheme(r),
+			prefix: r.Header.Get("X-Forwarded-Prefix"),
+			xff:    r.Header.Get("X-Forwarded-For"),
+		}
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/api/room-info", nil)
+	req.RemoteAddr = remoteAddr
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	return got
+}
+
+// TestTrustedProxiesHandler 只有来自可信代理的连接才解析转发请求头
+func TestTrustedProxiesHandler(t *testing.T) {
+	trusted := []string{"10.0.0.0/8", "2001:db8:ffff::1"}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		want       proxyResult
+	}{
+		{
+			"不可信的对端伪造的请求头被删除",
+			"203.0.113.9:5000",
+			http.Header{
+				"X-Forwarded-For":    {"1.2.3.4"},
+				"X-Forwarded-Proto":  {"https"},
+				"X-Forwarded-Prefix": {"/evil"},
+				"Forwarded":          {"for=1.2.3.4;proto=https"},
+				"X-Real-Ip":          {"1.2.3.4"},
+			},
+			proxyResult{ip: "203.0.113.9", scheme: "http"},
+		},
+		{
+			"可信代理保留 X-Forwarded-Prefix",
+			"10.0.0.1:5000",
+			http.Header{"X-Forwarded-For": {"198.51.100.7"}, "X-Forwarded-Prefix": {"/chuan"}},
+			proxyResult{ip: "198.51.100.7", scheme: "http", prefix: "/chuan", xff: "198.51.100.7"},
+		},
+		{
+			"从右向左在第一个不可信的地址停止，左侧伪造的地址被忽略",
+			"10.0.0.1:5000",
+			http.Header{"X-Forwarded-For": {"1.2.3.4, 198.51.100.7, 10.0.0.2"}},
+			proxyResult{ip: "198.51.100.7", scheme: "http", xff: "1.2.3.4, 198.51.100.7, 10.0.0.2"},
+		},
+		{
+			"多个 X-Forwarded-For 请求头按顺序拼接",
+			"10.0.0.1:5000",
+			http.Header{"X-Forwarded-For": {"1.2.3.4, 198.51.100.7", "10.0.0.2"}},
+			proxyResult{ip: "198.51.100.7", scheme: "http", xff: "1.2.3.4, 198.51.100.7"},
+		},
+		{
+			"整条链都可信时取最左边的地址",
+			"10.0.0.1:5000",
+			http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			proxyResult{ip: "10.0.0.3", scheme: "http", xff: "10.0.0.3, 10.0.0.2"},
+		},
+		{
+			"X-Forwarded-For 中的 unknown 无法继续追溯，取最后一个可信的地址",
+			"10.0.0.1:5000",
+			http.Header{"X-Forwarded-For": {"198.51.100.7, unknown, 10.0.0.2"}},
+			proxyResult{ip: "10.0.0.2", scheme: "http", xff: "198.51.100.7, unknown, 10.0.0.2"},
+		},
+		{
+			"Forwarded 的 for=unknown 使用对端地址",
+			"10.0.0.1:5000",
+			http.Header{"Forwarded": {"for=unknown"}},
+			proxyResult{ip: "10.0.0.1", scheme: "http"},
+		},
+		{
+			"Forwarded 的混淆标识无法继续追溯",
+			"10.0.0.1:5000",
+			http.Header{"Forwarded": {`for=198.51.100.7, for="_hidden", for=10.0.0.2`}},
+			proxyResult{ip: "10.0.0.2", scheme: "http"},
+		},
+		{
+			"Forwarded 的 proto 属于客户端所在的一跳",
+			"10.0.0.1:5000",
+			http.Header{"Forwarded": {"for=198.51.100.7;proto=https, for=10.0.0.2;proto=http"}},
+			proxyResult{ip: "198.51.100.7", scheme: "https"},
+		},
+		{
+			"Forwarded 带端口和方括号的 IPv6，优先于 X-Forwarded-For",
+			"10.0.0.1:5000",
+			http.Header{"Forwarded": {`For="[2001:db8::7]:4711";Proto=HTTPS`}, "X-Forwarded-For": {"198.51.100.7"}},
+			proxyResult{ip: "2001:db8::7", scheme: "https", xff: "198.51.100.7"},
+		},
+		{
+			"IPv4 映射的 IPv6 对端按 IPv4 匹配可信代理",
+			"[::ffff:10.0.0.1]:5000",
+			http.Header{"X-Forwarded-For": {"198.51.100.7"}},
+			proxyResult{ip: "198.51.100.7", scheme: "http", xff: "198.51.100.7"},
+		},
+		{
+			"不可信的 IPv4 映射对端记录为 IPv4",
+			"[::ffff:203.0.113.9]:5000",
+			http.Header{"X-Forwarded-For": {"1.2.3.4"}},
+			proxyResult{ip: "203.0.113.9", scheme: "http"},
+		},
+		{
+			"X-Forwarded-For 中 IPv4 映射的代理地址同样可信",
+			"10.0.0.1:5000",
+			http.Header{"X-Forwarded-For": {"198.51.100.7, ::ffff:10.0.0.2"}},
+			proxyResult{ip: "198.51.100.7", scheme: "http", xff: "198.51.100.7, ::ffff:10.0.0.2"},
+		},
+		{
+			"单个 IPv6 可信代理",
+			"[2001:db8:ffff::1]:5000",
+			http.Header{"X-Forwarded-For": {"2001:db8::7"}},
+			proxyResult{ip: "2001:db8::7", scheme: "http", xff: "2001:db8::7"},
+		},
+		{
+			"X-Forwarded-Proto 有多个值时使用第一个",
+			"10.0.0.1:5000",
+			http.Header{"X-Forwarded-Proto": {"https, http"}},
+			proxyResult{ip: "10.0.0.1", scheme: "https"},
+		},
+		{
+			"X-Forwarded-Proto 的第一个值为 http",
+			"10.0.0.1:5000",
+			http.Header{"X-Forwarded-Proto": {" HTTP , https"}},
+			proxyResult{ip: "10.0.0.1", scheme: "http"},
+		},
+		{
+			"X-Forwarded-Proto 不是 http/https 时忽略",
+			"10.0.0.1:5000",
+			http.Header{"X-Forwarded-Proto": {"ftp"}},
+			proxyResult{ip: "10.0.0.1", scheme: "http"},
+		},
+		{
+			"没有 X-Forwarded-For 时使用 X-Real-IP",
+			"10.0.0.1:5000",
+			http.Header{"X-Real-Ip": {"198.51.100.7"}},
+			proxyResult{ip: "198.51.100.7", scheme: "http"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serveThroughProxies(t, trusted, tt.remoteAddr, tt.header); got != tt.want {
+				t.Fatalf("结果 = %+v, 期望 %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestProxySetClient 从右向左跳过可信代理
+func TestProxySetClient(t *testing.T) {
+	set := proxySet{netip.MustParsePrefix("10.0.0.0/8")}
+	peer := netip.MustParseAddr("10.0.0.1")
+	hop := func(addr string) forwardedHop {
+		a, _ := parseNode(addr)
+		return forwardedHop{addr: a}
+	}
+	tests := []struct {
+		name string
+		hops []forwardedHop
+		want string
+	}{
+		{"没有转发记录", nil, "10.0.0.1"},
+		{"第一个不可信的地址", []forwardedHop{hop("1.2.3.4"), hop("198.51.100.7"), hop("10.0.0.2")}, "198.51.100.7"},
+		{"最右边就不可信", []forwardedHop{hop("10.0.0.3"), hop("198.51.100.7")}, "198.51.100.7"},
+		{"全部可信", []forwardedHop{hop("10.0.0.3"), hop("10.0.0.2")}, "10.0.0.3"},
+		{"最右边无法解析", []forwardedHop{hop("198.51.100.7"), hop("unknown")}, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := set.client(tt.hops, peer); got.addr.String() != tt.want {
+				t.Fatalf("client = %v, 期望 %s", got.addr, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseForwarded 多个请求头和元素按顺序解析，键不区分大小写，无法解析的节点保留为无效地址
+func TestParseForwarded(t *testing.T) {
+	hops := parseForwarded([]string{
+		`for=192.0.2.60;proto=http;by=203.0.113.43, FOR="[2001:db8:cafe::17]:4711"`,
+		`for=_gazonk, for=unknown;proto=https, proto=https`,
+	})
+	want := []struct {
+		addr  string
+		proto string
+	}{
+		{"192.0.2.60", "http"},
+		{"2001:db8:cafe::17", ""},
+		{"invalid IP", ""},
+		{"invalid IP", "https"},
+		{"invalid IP", "https"},
+	}
+	if len(hops) != len(want) {
+		t.Fatalf("解析出 %d 个节点, 期望 %d: %+v", len(hops), len(want), hops)
+	}
+	for i, w := range want {
+		if hops[i].addr.String() != w.addr || hops[i].proto != w.proto {
+			t.Errorf("节点 %d = %v/%q, 期望 %s/%q", i, hops[i].addr, hops[i].proto, w.addr, w.proto)
+		}
+	}
+
+	xff := parseForwardedFor([]string{"198.51.100.7:8080, [2001:db8::1]:443", " unknown ,::ffff:192.0.2.1"})
+	var got []string
+	for _, h := range xff {
+		got = append(got, h.addr.String())
+	}
+	if wantXFF := []string{"198.51.100.7", "2001:db8::1", "invalid IP", "192.0.2.1"}; !slices.Equal(got, wantXFF) {
+		t.Fatalf("parseForwardedFor = %q, 期望 %q", got, wantXFF)
+	}
+}
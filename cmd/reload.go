@@ -0,0 +1,144 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"chuan/internal/handlers"
+	"chuan/internal/logging"
+)
+
+// configWatchInterval 检查配置文件变化的间隔
+const configWatchInterval = 5 * time.Second
+
+// restartOnlySettings 在启动时绑定、修改后需要重启才能生效的配置项
+var restartOnlySettings = map[string]bool{
+	"PORT":               true,
+	"FRONTEND_DIR":       true,
+	"BASE_PATH":          true,
+	"HTTP_READ_TIMEOUT":  true,
+	"HTTP_WRITE_TIMEOUT": true,
+	"HTTP_IDLE_TIMEOUT":  true,
+	"WATCH_CONFIG":       true,
+	"TLS_CERT":           true,
+	"TLS_KEY":            true,
+	"HTTP_REDIRECT_PORT": true,
+	"WT_PORT":            true,
+	"WT_CERT":            true,
+	"WT_KEY":             true,
+	"ACCOUNTING_DB":      true,
+	"AUDIT_FILE":         true,
+	"AUDIT_MAX_SIZE":     true,
+	"AUDIT_MAX_FILES":    true,
+	"AUDIT_HASH_CHAIN":   true,
+
+	"WEBHOOK_URLS":                true,
+	"WEBHOOK_SECRET":              true,
+	"WEBHOOK_EVENTS":              true,
+	"WEBHOOK_TIMEOUT":             true,
+	"WEBHOOK_MAX_ATTEMPTS":        true,
+	"WEBHOOK_DEAD_LETTER":         true,
+	"WEBHOOK_INCLUDE_IDENTITY":    true,
+	"WEBHOOK_INCLUDE_REMOTE_ADDR": true,
+}
+
+// configReloader 重新加载配置，并把可热更新的部分应用到运行中的服务
+// 只在 WaitForShutdown 所在的协程中调用，current 不需要加锁
+type configReloader struct {
+	args    []string
+	current *Config
+	handler *handlers.Handler
+	runtime *httpRuntime
+}
+
+func newConfigReloader(args []string, config *Config, h *handlers.Handler, rt *httpRuntime) *configReloader {
+	return &configReloader{
+		args:    args,
+		current: config,
+		handler: h,
+		runtime: rt,
+	}
+}
+
+// Reload 重新执行 loadConfig；配置无效时保留当前配置，需要重启的配置项保持原值
+func (r *configReloader) Reload() {
+	next, err := loadConfig(r.args)
+	if err != nil {
+		logging.Errorf("⚠️ 重新加载配置失败，继续使用当前配置: %v", err)
+		return
+	}
+
+	oldSettings := r.current.settings()
+	changed := 0
+	for i, s := range next.settings() {
+		oldValue, newValue := oldSettings[i].value.String(), s.value.String()
+		if oldValue == newValue {
+			continue
+		}
+		// 先隐藏敏感配置的值，两条日志都只输出隐藏后的值
+		oldShown, newShown := oldValue, newValue
+		if secretSettings[s.env] {
+			oldShown, newShown = maskSecret(oldValue), maskSecret(newValue)
+		}
+		if restartOnlySettings[s.env] {
+			log.Printf("⚠️ 配置项 %s 已修改 (%s → %s)，需要重启才能生效", s.env, oldShown, newShown)
+			s.value.Set(oldValue)
+			continue
+		}
+		log.Printf("🔄 配置项 %s: %s → %s", s.env, oldShown, newShown)
+		changed++
+	}
+
+	if changed == 0 {
+		log.Printf("🔄 已重新加载配置，没有可热更新的变化")
+		return
+	}
+
+	r.apply(next)
+	r.current = next
+	log.Printf("✅ 已应用 %d 项配置变化，已建立的连接不受影响", changed)
+}
+
+// apply 把配置应用到运行中的服务
+func (r *configReloader) apply(config *Config) {
+	logging.SetLevel(config.Log.level())
+	r.handler.UpdateOptions(config.serviceOptions())
+	r.runtime.Update(config)
+}
+
+// watchConfigFile 定期检查配置文件，发生变化时通过 changed 通知，直到 stop 关闭
+func watchConfigFile(path string, changed chan<- struct{}, stop <-chan struct{}) {
+	log.Printf("👀 监视配置文件变化: %s", path)
+
+	lastModTime := fileModTime(path)
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			modTime := fileModTime(path)
+			if modTime.IsZero() || !modTime.After(lastModTime) {
+				continue
+			}
+			lastModTime = modTime
+			log.Printf("📄 检测到配置文件变化: %s", path)
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// fileModTime 返回文件修改时间，文件不存在时返回零值
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureLog 收集 fn 执行期间标准库 log 的输出
+func captureLog(t *testing.T, fn func()) string {
+	t.Helper()
+	var buf bytes.Buffer
+	output := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(output)
+	fn()
+	return buf.String()
+}
+
+// TestReloadMasksSecrets 需要重启的敏感配置项被修改时，日志中也不出现原值
+func TestReloadMasksSecrets(t *testing.T) {
+	t.Chdir(t.TempDir())
+	write := func(secret, token string) {
+		yaml := "webhooks:\n  urls: [https://hooks.example.com/services/" + token + "]\n  secret: " + secret + "\n"
+		if err := os.WriteFile("chuan.yaml", []byte(yaml), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("old-secret", "OLDTOKEN")
+	config, err := loadConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloader := newConfigReloader(nil, config, nil, nil)
+
+	write("new-secret", "NEWTOKEN")
+	output := captureLog(t, reloader.Reload)
+
+	for _, leaked := range []string{"old-secret", "new-secret", "OLDTOKEN", "NEWTOKEN"} {
+		if strings.Contains(output, leaked) {
+			t.Errorf("重新加载日志泄露了 %q:\n%s", leaked, output)
+		}
+	}
+	if !strings.Contains(output, "WEBHOOK_SECRET 已修改 (****** → ******)") {
+		t.Errorf("应记录被隐藏的配置变化:\n%s", output)
+	}
+	// 需要重启的配置项保持原值
+	if reloader.current.Webhooks.Secret != "old-secret" {
+		t.Errorf("WEBHOOK_SECRET 不应热更新, 实际 %q", reloader.current.Webhooks.Secret)
+	}
+}
+
+// TestWriteConfigMasksSecrets config print 不输出密钥和包含令牌的 webhook 地址
+func TestWriteConfigMasksSecrets(t *testing.T) {
+	config := defaultConfig()
+	config.Admin.Token = "admin-token"
+	config.Webhooks.Secret = "hook-secret"
+	config.Webhooks.URLs = []string{"https://discord.com/api/webhooks/1/TOKEN"}
+
+	for _, format := range []string{"yaml", "toml"} {
+		var buf bytes.Buffer
+		if err := writeConfig(&buf, config, format); err != nil {
+			t.Fatal(err)
+		}
+		for _, leaked := range []string{"admin-token", "hook-secret", "TOKEN"} {
+			if strings.Contains(buf.String(), leaked) {
+				t.Errorf("%s 输出泄露了 %q", format, leaked)
+			}
+		}
+	}
+	if config.Webhooks.URLs[0] != "https://discord.com/api/webhooks/1/TOKEN" || config.Admin.Token != "admin-token" {
+		t.Error("输出配置不应修改原配置")
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"chuan/internal/api"
+	"chuan/internal/services"
+)
+
+// roomEventsRetry 事件流意外断开后重新连接前的等待时间（测试中缩短）
+var roomEventsRetry = 3 * time.Second
+
+// errRoomEnded 房间已过期或已结束，事件流不会再有新事件
+var errRoomEnded = errors.New("房间已结束")
+
+// runRoomCommand 处理 room 子命令，通过 /api/v1/rooms/{code}/events 订阅房间事件，代替脚本轮询房间状态
+// room events 逐行输出事件（JSON），房间结束后退出；room wait 等到指定事件后退出
+func runRoomCommand(args []string) error {
+	usage := "用法: room events|wait [-server URL] [-for 事件类型] [-timeout 时长] <房间码>"
+	if len(args) == 0 || (args[0] != "events" && args[0] != "wait") {
+		return errors.New(usage)
+	}
+	sub := args[0]
+
+	fs := flag.NewFlagSet("room "+sub, flag.ContinueOnError)
+	server := fs.String("server", "http://localhost:8080", "服务器地址（包含 BASE_PATH）")
+	waitFor := fs.String("for", services.RoomEventSenderOnline, "room wait 等待的事件类型，多个以逗号分隔")
+	timeout := fs.Duration("timeout", 0, "最长等待时间，0 表示不限制")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New(usage)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	room := strings.TrimRight(*server, "/") + api.Prefix + "/rooms/" + url.PathEscape(fs.Arg(0))
+
+	if sub == "events" {
+		err := followRoomEvents(ctx, room+"/events", func(e services.RoomEvent) (bool, error) {
+			line, _ := json.Marshal(e)
+			fmt.Println(string(line))
+			return false, nil
+		})
+		if errors.Is(err, errRoomEnded) {
+			return nil
+		}
+		return err
+	}
+
+	matched, err := waitRoomEvent(ctx, room, strings.Split(*waitFor, ","))
+	switch {
+	case err == nil:
+		fmt.Printf("✅ 房间 %s: %s\n", fs.Arg(0), matched)
+		return nil
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("❌ 等待 %s 超时", *waitFor)
+	default:
+		return fmt.Errorf("❌ 等待 %s 失败: %w", *waitFor, err)
+	}
+}
+
+// waitRoomEvent 等待 targets 中的任一事件，返回匹配的事件类型
+// 事件流会补发保留的历史事件，其中的 sender_online/receiver_online 可能已经过时，
+// 因此在线类事件以房间当前状态为准：订阅前先查询一次，之后每收到一次再确认
+func waitRoomEvent(ctx context.Context, room string, targets []string) (string, error) {
+	online := func() (string, error) {
+		var status api.Room
+		if err := getJSON(ctx, room, &status); err != nil {
+			return "", err
+		}
+		switch {
+		case status.SenderOnline && slices.Contains(targets, services.RoomEventSenderOnline):
+			return services.RoomEventSenderOnline, nil
+		case status.ReceiverOnline && slices.Contains(targets, services.RoomEventReceiverOnline):
+			return services.RoomEventReceiverOnline, nil
+		}
+		return "", nil
+	}
+
+	matched, err := online()
+	if err != nil || matched != "" {
+		return matched, err
+	}
+	err = followRoomEvents(ctx, room+"/events", func(e services.RoomEvent) (bool, error) {
+		if !slices.Contains(targets, e.Type) {
+			return false, nil
+		}
+		if e.Type == services.RoomEventSenderOnline || e.Type == services.RoomEventReceiverOnline {
+			var err error
+			matched, err = online()
+			return matched != "", err
+		}
+		matched = e.Type
+		return true, nil
+	})
+	return matched, err
+}
+
+// getJSON 请求 v1 接口并解析响应，失败时返回服务器给出的错误信息
+func getJSON(ctx context.Context, target string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return statusError(resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// followRoomEvents 读取房间事件流，依次交给 handle，handle 返回 true 或错误时结束
+// 连接意外断开时带 Last-Event-ID 重连，补发的历史事件同样会交给 handle；房间结束时返回 errRoomEnded
+func followRoomEvents(ctx context.Context, endpoint string, handle func(services.RoomEvent) (bool, error)) error {
+	var lastID uint64
+	for {
+		done, err := readRoomEvents(ctx, endpoint, &lastID, handle)
+		if done || errors.Is(err, errRoomEnded) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var status *roomEventsStatusError
+		if errors.As(err, &status) {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "⚠️ 房间事件流断开，%v 后重连: %v\n", roomEventsRetry, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(roomEventsRetry):
+		}
+	}
+}
+
+// roomEventsStatusError 服务器拒绝请求（如房间不存在），重连没有意义
+type roomEventsStatusError struct {
+	status  int
+	message string
+}
+
+func (e *roomEventsStatusError) Error() string {
+	if e.message != "" {
+		return fmt.Sprintf("HTTP %d: %s", e.status, e.message)
+	}
+	return fmt.Sprintf("HTTP %d", e.status)
+}
+
+// statusError 从 v1 统一错误格式中取出错误信息
+func statusError(resp *http.Response) error {
+	var body api.ErrorResponse
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	return &roomEventsStatusError{status: resp.StatusCode, message: body.Error.Message}
+}
+
+// readRoomEvents 建立一次 SSE 连接并读取到结束，done 表示 handle 已返回 true
+func readRoomEvents(ctx context.Context, endpoint string, lastID *uint64, handle func(services.RoomEvent) (bool, error)) (done bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if *lastID > 0 {
+		req.Header.Set("Last-Event-ID", strconv.FormatUint(*lastID, 10))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		// 房间已结束且没有需要补发的事件
+		return false, errRoomEnded
+	case resp.StatusCode != http.StatusOK:
+		return false, statusError(resp)
+	}
+
+	var data strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" {
+			// 只需要 data 字段，事件类型和 ID 都包含在 JSON 中；注释（ping）和 retry 忽略
+			if value, ok := strings.CutPrefix(line, "data:"); ok {
+				data.WriteString(strings.TrimPrefix(value, " "))
+			}
+			continue
+		}
+		if data.Len() == 0 {
+			continue
+		}
+		var e services.RoomEvent
+		if err := json.Unmarshal([]byte(data.String()), &e); err != nil {
+			return false, fmt.Errorf("解析房间事件失败: %w", err)
+		}
+		data.Reset()
+		if done, err := handle(e); done || err != nil {
+			return done, err
+		}
+		// 处理完成后才推进续传位置，handle 出错重连时这条事件会重新补发
+		*lastID = e.ID
+		if e.Type == services.RoomEventExpired {
+			return false, errRoomEnded
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+	return false, io.ErrUnexpectedEOF
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"chuan/internal/api"
+	"chuan/internal/services"
+)
+
+// writeSSE 按服务器的格式写出房间事件
+func writeSSE(w http.ResponseWriter, events ...services.RoomEvent) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	fmt.Fprint(w, "retry: 3000\n\n: ping\n\n")
+	for _, e := range events {
+		fmt.Fprintf(w, "id: %d\nevent: %s\ndata: {\"id\":%d,\"type\":%q,\"room\":\"QA2024\"}\n\n", e.ID, e.Type, e.ID, e.Type)
+	}
+	w.(http.Flusher).Flush()
+}
+
+// TestWaitRoomEventConfirmsOnline 补发的过时 sender_online 不会让等待提前结束
+func TestWaitRoomEventConfirmsOnline(t *testing.T) {
+	var checks atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/rooms/QA2024", func(w http.ResponseWriter, r *http.Request) {
+		// 第 1 次为订阅前的查询，第 2 次为补发的旧事件，第 3 次发送方才真正上线
+		api.WriteJSON(w, http.StatusOK, api.Room{Code: "QA2024", SenderOnline: checks.Add(1) == 3})
+	})
+	mux.HandleFunc("GET /api/v1/rooms/QA2024/events", func(w http.ResponseWriter, r *http.Request) {
+		writeSSE(w,
+			services.RoomEvent{ID: 1, Type: services.RoomEventSenderOnline},
+			services.RoomEvent{ID: 2, Type: services.RoomEventSenderOffline},
+			services.RoomEvent{ID: 3, Type: services.RoomEventReceiverOnline},
+			services.RoomEvent{ID: 4, Type: services.RoomEventSenderOnline},
+		)
+		<-r.Context().Done()
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	matched, err := waitRoomEvent(context.Background(), server.URL+"/api/v1/rooms/QA2024", []string{services.RoomEventSenderOnline})
+	if err != nil || matched != services.RoomEventSenderOnline {
+		t.Fatalf("waitRoomEvent = %q, %v", matched, err)
+	}
+	if n := checks.Load(); n != 3 {
+		t.Fatalf("期望查询房间状态 3 次, 实际 %d", n)
+	}
+}
+
+// TestFollowRoomEventsResume 连接断开后带 Last-Event-ID 重连，收到 expired 后结束
+func TestFollowRoomEventsResume(t *testing.T) {
+	retry := roomEventsRetry
+	roomEventsRetry = 10 * time.Millisecond
+	defer func() { roomEventsRetry = retry }()
+
+	var lastIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastIDs = append(lastIDs, r.Header.Get("Last-Event-ID"))
+		if len(lastIDs) == 1 {
+			writeSSE(w, services.RoomEvent{ID: 7, Type: services.RoomEventSenderOnline})
+			return
+		}
+		writeSSE(w, services.RoomEvent{ID: 8, Type: services.RoomEventExpired})
+	}))
+	defer server.Close()
+
+	var got []string
+	err := followRoomEvents(context.Background(), server.URL, func(e services.RoomEvent) (bool, error) {
+		got = append(got, e.Type)
+		return false, nil
+	})
+	if err != errRoomEnded {
+		t.Fatalf("期望 errRoomEnded, 实际 %v", err)
+	}
+	if strings.Join(got, ",") != "sender_online,expired" || strings.Join(lastIDs, ",") != ",7" {
+		t.Fatalf("事件 = %v, Last-Event-ID = %q", got, lastIDs)
+	}
+}
+
+// TestFollowRoomEventsNotFound 房间不存在时不重连，返回服务器的错误信息
+func TestFollowRoomEventsNotFound(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		api.WriteJSON(w, http.StatusNotFound, api.ErrorResponse{Error: api.Error{Code: api.CodeRoomNotFound, Message: "房间不存在"}})
+	}))
+	defer server.Close()
+
+	err := followRoomEvents(context.Background(), server.URL, func(services.RoomEvent) (bool, error) { return false, nil })
+	if err == nil || !strings.Contains(err.Error(), "HTTP 404: 房间不存在") || requests.Load() != 1 {
+		t.Fatalf("err = %v, 请求 %d 次", err, requests.Load())
+	}
+}
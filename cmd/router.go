@@ -1,64 +1,276 @@
 package main
 
 import (
+	"io"
+	"log"
 	"net/http"
+	"os"
+	"sync/atomic"
 
+	"chuan/internal/accounting"
+	"chuan/internal/api"
+	"chuan/internal/audit"
+	"chuan/internal/events"
 	"chuan/internal/handlers"
+	"chuan/internal/logging"
 	"chuan/internal/web"
+	"chuan/internal/webhook"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 )
 
-// setupRouter 设置路由和中间件
-func setupRouter() http.Handler {
-	// 初始化处理器
-	h := handlers.NewHandler()
+// setupHandler 初始化处理器、用量账本，以及订阅房间事件的审计日志和 webhook
+func setupHandler(config *Config) (*handlers.Handler, error) {
+	ledger, err := accounting.Open(config.Accounting.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	bus := events.NewBus()
+	var closers []io.Closer
+	fail := func(err error) (*handlers.Handler, error) {
+		ledger.Close()
+		for _, c := range closers {
+			c.Close()
+		}
+		return nil, err
+	}
+
+	if config.Audit.File != "" {
+		auditLog, err := audit.Open(config.Audit.options())
+		if err != nil {
+			return fail(err)
+		}
+		closers = append(closers, auditLog)
+		bus.Subscribe(func(e events.Event) {
+			if err := auditLog.Write(e); err != nil {
+				logging.Errorf("❌ 写入审计日志失败: %s %s: %v", e.Type, e.Room, err)
+			}
+		})
+	}
+
+	if len(config.Webhooks.URLs) > 0 {
+		dispatcher, err := webhook.New(config.Webhooks.options())
+		if err != nil {
+			return fail(err)
+		}
+		closers = append(closers, dispatcher)
+		bus.Subscribe(dispatcher.Handle)
+	}
+
+	h := handlers.NewHandler(config.serviceOptions(), ledger, bus)
+	for _, c := range closers {
+		h.CloseWith(c)
+	}
+	return h, nil
+}
+
+// httpRuntime 可热更新的 HTTP 层组件，配置重新加载时由 configReloader 更新
+type httpRuntime struct {
+	proxies  *trustedProxies
+	cors     *dynamicCORS
+	security *securityHeaders
+	client   *web.ClientConfigStore
+	admin    *adminAuth
+	auth     *roomAuth
+}
+
+func newHTTPRuntime(config *Config) *httpRuntime {
+	return &httpRuntime{
+		proxies:  newTrustedProxies(config),
+		cors:     newDynamicCORS(config),
+		security: newSecurityHeaders(config),
+		client:   web.NewClientConfigStore(config.clientConfig(), config.Client.Inject),
+		admin:    newAdminAuth(config.Admin.Token),
+		auth:     newRoomAuth(config),
+	}
+}
+
+// Update 应用新的配置，之后的请求立即生效
+func (rt *httpRuntime) Update(config *Config) {
+	rt.proxies.Update(config)
+	rt.cors.Update(config)
+	rt.security.Update(config)
+	rt.client.Update(config.clientConfig(), config.Client.Inject)
+	rt.admin.Update(config.Admin.Token)
+	rt.auth.Update(config)
+}
 
+// setupRouter 设置路由和中间件
+func setupRouter(config *Config, h *handlers.Handler, rt *httpRuntime) http.Handler {
 	router := chi.NewRouter()
 
 	// 设置中间件
-	setupMiddleware(router)
+	setupMiddleware(router, rt)
+
+	// 接口和管理面板的响应动态压缩；内嵌的前端文件已预压缩，不经过该中间件
+	router.Group(func(r chi.Router) {
+		r.Use(middleware.Compress(5))
 
-	// 设置API路由
-	setupAPIRoutes(router, h)
+		// 设置API路由
+		setupAPIRoutes(r, h, rt)
 
-	// 设置前端路由
-	router.Handle("/*", web.CreateFrontendHandler())
+		// 设置管理接口路由
+		setupAdminRoutes(r, h, rt)
+	})
 
-	return router
+	// 设置前端路由，页面按 security 配置返回 CSP，并注入 client 配置
+	router.Handle("/*", web.CreateFrontendHandler(web.FrontendOptions{
+		Dir:          config.Server.FrontendDir,
+		PageHeaders:  rt.security.PageHeaders,
+		ClientConfig: rt.client,
+	}))
+
+	// 最先解析真实的客户端地址，之后的访问日志、路径前缀和各处理器都使用解析结果
+	return rt.proxies.Handler(withBasePath(config.Server.basePath(), router))
 }
 
 // setupMiddleware 设置中间件
-func setupMiddleware(r *chi.Mux) {
-	r.Use(middleware.Logger)
+func setupMiddleware(r *chi.Mux, rt *httpRuntime) {
+	r.Use(requestLogger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Compress(5))
 
-	// CORS 配置
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"},
+	// CORS 配置（支持热重载）
+	r.Use(rt.cors.Handler)
+
+	// 安全响应头（支持热重载）
+	r.Use(rt.security.Handler)
+}
+
+// requestLogger 按当前日志级别输出 HTTP 访问日志，记录的是经可信代理解析后的客户端 IP
+func requestLogger(next http.Handler) http.Handler {
+	logged := middleware.RequestLogger(clientIPLogFormatter{
+		&middleware.DefaultLogFormatter{Logger: log.New(os.Stdout, "", log.LstdFlags), NoColor: false},
+	})(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if logging.Enabled(logging.LevelInfo) {
+			logged.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIPLogFormatter 访问日志中的地址替换为 api.ClientIP，不修改传给处理器的请求
+type clientIPLogFormatter struct {
+	middleware.LogFormatter
+}
+
+func (f clientIPLogFormatter) NewLogEntry(r *http.Request) middleware.LogEntry {
+	logged := *r
+	logged.RemoteAddr = api.ClientIP(r)
+	return f.LogFormatter.NewLogEntry(&logged)
+}
+
+// dynamicCORS 可在运行时替换配置的 CORS 中间件
+type dynamicCORS struct {
+	current atomic.Pointer[cors.Cors]
+}
+
+func newDynamicCORS(config *Config) *dynamicCORS {
+	d := &dynamicCORS{}
+	d.Update(config)
+	return d
+}
+
+// Update 替换 CORS 配置，之后的请求立即生效
+func (d *dynamicCORS) Update(config *Config) {
+	options := cors.Options{
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-Client-ID", "X-Signal-Token", "X-API-Key", "Last-Event-ID"},
 		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
-		MaxAge:           300,
-	}))
+		AllowCredentials: config.CORS.AllowCredentials,
+		MaxAge:           config.CORS.MaxAge,
+	}
+
+	// 与 WebSocket 升级使用同一来源策略
+	if policy := config.Origins.policy(); policy.AllowAll() {
+		options.AllowedOrigins = []string{"*"}
+	} else {
+		options.AllowOriginFunc = func(r *http.Request, origin string) bool {
+			return policy.Allowed(origin)
+		}
+	}
+	d.current.Store(cors.New(options))
+}
+
+// Handler CORS 中间件，每个请求使用当前生效的配置
+func (d *dynamicCORS) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.current.Load().Handler(next).ServeHTTP(w, r)
+	})
 }
 
 // setupAPIRoutes 设置API路由
-func setupAPIRoutes(r *chi.Mux, h *handlers.Handler) {
+// 创建房间和加入房间（建立信令、中继连接）按 auth 配置要求身份验证
+func setupAPIRoutes(r chi.Router, h *handlers.Handler, rt *httpRuntime) {
+	join := r.With(rt.auth.RequireJoin)
+
 	// WebRTC信令WebSocket路由
-	r.Get("/api/ws/webrtc", h.HandleWebRTCWebSocket)
-	r.Get("/ws/webrtc", h.HandleWebRTCWebSocket)
+	join.Get("/api/ws/webrtc", h.HandleWebRTCWebSocket)
+	join.Get("/ws/webrtc", h.HandleWebRTCWebSocket)
 
 	// WebSocket 数据中继路由（P2P降级方案）
-	r.Get("/api/ws/relay", h.HandleRelayWebSocket)
-	r.Get("/ws/relay", h.HandleRelayWebSocket)
+	join.Get("/api/ws/relay", h.HandleRelayWebSocket)
+	join.Get("/ws/relay", h.HandleRelayWebSocket)
+
+	// SSE + HTTP POST 信令路由（WebSocket被代理拦截时的降级方案）
+	// 提交信令时已通过 SSE 连接下发的令牌校验，不再重复认证
+	join.Get("/api/signal/{code}/events", h.HandleSignalEvents)
+	r.Post("/api/signal/{code}", h.HandleSignalPost)
 
 	// WebRTC房间API
-	r.Post("/api/create-room", h.CreateRoomHandler)
+	r.With(rt.auth.RequireCreate).Post("/api/create-room", h.CreateRoomHandler)
 	r.Get("/api/room-info", h.WebRTCRoomStatusHandler)
 	r.Get("/api/webrtc-room-status", h.WebRTCRoomStatusHandler)
+
+	// 房间状态变化事件流（SSE，或 chuan.room-events 子协议的 WebSocket），支持 Last-Event-ID 断线续传
+	r.Get("/api/rooms/{code}/events", h.HandleRoomEvents)
+
+	// v1 接口：按资源组织路由，使用标准状态码和统一的错误格式
+	r.Route(api.Prefix, func(r chi.Router) {
+		r.Use(api.Versioned)
+		r.NotFound(h.APINotFoundHandler)
+		r.MethodNotAllowed(h.APIMethodNotAllowedHandler)
+
+		for _, op := range h.Operations() {
+			route := r
+			if op.Auth == api.AuthCreate {
+				route = r.With(rt.auth.RequireCreate)
+			}
+			route.Method(op.Method, op.Path, op.Handler)
+		}
+	})
+
+	// 接口文档：v1 REST 接口 (OpenAPI 3) 和 WebSocket 消息格式 (AsyncAPI)
+	r.Get("/api/openapi.json", h.OpenAPIHandler)
+	r.Get("/api/asyncapi.json", h.AsyncAPIHandler)
+
+	// 前端运行时配置（ICE 服务器、功能开关、站点名称等），支持热重载
+	r.Get("/api/client-config", rt.client.ServeHTTP)
+
+	// 浏览器上报的 CSP 违规报告（report-uri 和 Reporting API 两种格式）
+	r.Post(cspReportPath, h.CSPReportHandler)
+}
+
+// setupAdminRoutes 设置管理面板和管理接口路由，需要 Authorization: Bearer <admin.token> 或管理面板登录
+func setupAdminRoutes(r chi.Router, h *handlers.Handler, rt *httpRuntime) {
+	r.Get("/admin", rt.admin.Dashboard(h.AdminDashboardHandler, h.AdminLoginPageHandler))
+	r.Post("/admin/login", rt.admin.Login)
+	r.Post("/admin/logout", rt.admin.Logout)
+
+	r.Route("/admin/api", func(r chi.Router) {
+		r.Use(rt.admin.Handler)
+		r.Get("/stats", h.AdminStatsHandler)
+		r.Get("/stats/stream", h.AdminStatsStreamHandler)
+		r.Get("/rooms", h.AdminListRoomsHandler)
+		r.Get("/rooms/{code}", h.AdminGetRoomHandler)
+		r.Delete("/rooms/{code}", h.AdminCloseRoomHandler)
+		r.Post("/rooms/{code}/extend", h.AdminExtendRoomHandler)
+		r.Get("/usage", h.AdminListUsageHandler)
+		r.Get("/usage.csv", h.AdminExportUsageHandler)
+		r.Get("/usage/{identity}", h.AdminGetUsageHandler)
+	})
 }
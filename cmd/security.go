@@ -0,0 +1,207 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"chuan/internal/api"
+)
+
+// cspOff 不设置 CSP 时 security.csp 的取值（环境变量无法设置为空字符串）
+const cspOff = "off"
+
+// defaultCSP 默认的前端页面 CSP
+// 脚本只允许同源文件和页面中的内联脚本（按哈希放行）；React 渲染的 style 属性无法用哈希放行，样式允许内联；
+// 帮助页面引用了外部图片，图片允许任意 https 来源
+const defaultCSP = "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data: blob: https:; font-src 'self' data:; connect-src 'self'; media-src 'self' blob:; " +
+	"worker-src 'self' blob:; object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'"
+
+// CSP 违规报告的收集地址，策略中没有 report-uri/report-to 时自动加入
+const (
+	cspReportPath     = "/api/csp-report"
+	cspReportEndpoint = "csp-endpoint"
+)
+
+var referrerPolicies = map[string]bool{
+	"no-referrer": true, "no-referrer-when-downgrade": true, "origin": true, "origin-when-cross-origin": true,
+	"same-origin": true, "strict-origin": true, "strict-origin-when-cross-origin": true, "unsafe-url": true,
+}
+
+var openerPolicies = map[string]bool{
+	"same-origin": true, "same-origin-allow-popups": true, "noopener-allow-popups": true, "unsafe-none": true,
+}
+
+var embedderPolicies = map[string]bool{
+	"require-corp": true, "credentialless": true, "unsafe-none": true,
+}
+
+var cspDirectiveName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9-]*$`)
+
+// validCSP 校验策略的基本格式：不含换行，每条指令以合法的指令名开头
+func validCSP(policy string) bool {
+	if strings.ContainsAny(policy, "\r\n") {
+		return false
+	}
+	for _, directive := range strings.Split(policy, ";") {
+		fields := strings.Fields(directive)
+		if len(fields) > 0 && !cspDirectiveName.MatchString(fields[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// cspMode 返回 CSP 的生效方式，用于日志
+func (c SecurityConfig) cspMode() string {
+	switch {
+	case !c.cspEnabled():
+		return "关闭"
+	case c.CSPReportOnly:
+		return "仅上报"
+	default:
+		return "启用"
+	}
+}
+
+// securityHeaders 可在运行时替换配置的安全响应头中间件
+type securityHeaders struct {
+	current atomic.Pointer[securityPolicy]
+}
+
+// securityPolicy 由配置生成的响应头
+type securityPolicy struct {
+	common    http.Header // 所有响应都设置的头
+	hsts      string      // HTTPS 请求的 Strict-Transport-Security，为空时不设置
+	cspHeader string      // Content-Security-Policy 或 Content-Security-Policy-Report-Only，不设置 CSP 时为空
+	csp       string
+	report    bool // 策略中没有指定报告地址，返回时加入 /api/csp-report
+}
+
+func newSecurityHeaders(config *Config) *securityHeaders {
+	s := &securityHeaders{}
+	s.Update(config)
+	return s
+}
+
+// Update 替换响应头配置，之后的请求立即生效
+func (s *securityHeaders) Update(config *Config) {
+	c := config.Security
+	p := &securityPolicy{common: http.Header{}}
+	p.common.Set("X-Content-Type-Options", "nosniff")
+	for name, value := range map[string]string{
+		"Referrer-Policy":              c.ReferrerPolicy,
+		"Permissions-Policy":           c.PermissionsPolicy,
+		"Cross-Origin-Opener-Policy":   c.COOP,
+		"Cross-Origin-Embedder-Policy": c.COEP,
+	} {
+		if value != "" {
+			p.common.Set(name, value)
+		}
+	}
+	if c.HSTSMaxAge > 0 {
+		p.hsts = "max-age=" + strconv.Itoa(c.HSTSMaxAge)
+	}
+	if c.cspEnabled() {
+		p.cspHeader = "Content-Security-Policy"
+		if c.CSPReportOnly {
+			p.cspHeader = "Content-Security-Policy-Report-Only"
+		}
+		p.csp = strings.TrimRight(strings.TrimSpace(c.CSP), "; ")
+		p.report = !hasReportEndpoint(p.csp)
+	}
+	s.current.Store(p)
+}
+
+// Handler 为所有响应设置通用的安全响应头，HSTS 只在 HTTPS 请求中返回（包括可信代理终止 TLS 后转发的请求）
+func (s *securityHeaders) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := s.current.Load()
+		for name, values := range p.common {
+			w.Header()[name] = values
+		}
+		if api.Scheme(r) == "https" && p.hsts != "" {
+			w.Header().Set("Strict-Transport-Security", p.hsts)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// PageHeaders 为前端页面设置 CSP，页面内联脚本的哈希加入 script-src（实现 web.PageHeaderFunc）
+func (s *securityHeaders) PageHeaders(h http.Header, r *http.Request, scriptHashes []string) {
+	p := s.current.Load()
+	if p.cspHeader == "" {
+		return
+	}
+	policy := withScriptHashes(p.csp, scriptHashes)
+	if p.report {
+		// report-uri 供旧版浏览器使用，report-to 需要同时返回 Reporting-Endpoints
+		reportURL := api.BasePath(r) + cspReportPath
+		policy += "; report-uri " + reportURL + "; report-to " + cspReportEndpoint
+		h.Set("Reporting-Endpoints", cspReportEndpoint+`="`+reportURL+`"`)
+	}
+	h.Set(p.cspHeader, policy)
+}
+
+// hasReportEndpoint 策略中是否已指定报告地址 (report-uri 或 report-to)
+func hasReportEndpoint(policy string) bool {
+	for _, directive := range strings.Split(policy, ";") {
+		if name, _ := cspDirective(directive); name == "report-uri" || name == "report-to" {
+			return true
+		}
+	}
+	return false
+}
+
+// withScriptHashes 把内联脚本哈希加入 script-src；没有 script-src 时以 default-src 为基础新增
+// 策略允许所有内联脚本 ('unsafe-inline') 或禁止所有脚本 ('none') 时不修改，加入哈希会改变原策略的含义
+func withScriptHashes(policy string, hashes []string) string {
+	if len(hashes) == 0 {
+		return policy
+	}
+	directives := strings.Split(policy, ";")
+	target, fallback := -1, -1
+	for i, directive := range directives {
+		switch name, _ := cspDirective(directive); name {
+		case "script-src":
+			target = i
+		case "default-src":
+			fallback = i
+		}
+	}
+
+	var sources []string
+	switch {
+	case target >= 0:
+		_, sources = cspDirective(directives[target])
+	case fallback >= 0:
+		_, sources = cspDirective(directives[fallback])
+	default:
+		return policy // 没有限制脚本来源
+	}
+	for _, source := range sources {
+		if strings.EqualFold(source, "'unsafe-inline'") || strings.EqualFold(source, "'none'") {
+			return policy
+		}
+	}
+
+	directive := " script-src " + strings.Join(append(sources, hashes...), " ")
+	if target >= 0 {
+		directives[target] = directive
+	} else {
+		directives = append(directives, directive)
+	}
+	return strings.TrimSpace(strings.Join(directives, ";"))
+}
+
+// cspDirective 拆分一条指令，返回小写的指令名和来源列表
+func cspDirective(directive string) (string, []string) {
+	fields := strings.Fields(directive)
+	if len(fields) == 0 {
+		return "", nil
+	}
+	return strings.ToLower(fields[0]), fields[1:]
+}
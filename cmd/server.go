@@ -8,50 +8,125 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
+
+	"chuan/internal/handlers"
+	"chuan/internal/logging"
+
+	"github.com/quic-go/webtransport-go"
 )
 
 // Server 服务器结构
 type Server struct {
-	httpServer *http.Server
-	config     *Config
+	httpServer     *http.Server
+	redirectServer *http.Server         // 可选的 HTTP → HTTPS 重定向监听器
+	webTransport   *webtransport.Server // 可选的 HTTP/3 WebTransport 中继监听器
+	certs          *certReloader        // 启用 TLS 时的可热更新证书
+	wtCerts        *certReloader        // 通过 WT_CERT/WT_KEY 单独配置的 WebTransport 证书
+	reloader       *configReloader      // SIGHUP 时重新加载配置
+	stopWatch      chan struct{}
+	config         *Config // 启动时的配置，热更新后的配置见 reloader.current
 }
 
 // NewServer 创建新的服务器实例
-func NewServer(config *Config, handler http.Handler) *Server {
+func NewServer(config *Config, handler http.Handler, reloader *configReloader) *Server {
 	return &Server{
 		httpServer: &http.Server{
-			Addr:         fmt.Sprintf(":%d", config.Port),
+			Addr:         fmt.Sprintf(":%d", config.Server.Port),
 			Handler:      handler,
-			ReadTimeout:  30 * time.Second,
-			WriteTimeout: 30 * time.Second,
-			IdleTimeout:  120 * time.Second,
+			ReadTimeout:  config.Server.ReadTimeout,
+			WriteTimeout: config.Server.WriteTimeout,
+			IdleTimeout:  config.Server.IdleTimeout,
 		},
-		config: config,
+		reloader:  reloader,
+		stopWatch: make(chan struct{}),
+		config:    config,
+	}
+}
+
+// EnableTLS 使用可热更新的证书以 HTTPS 方式提供服务
+func (s *Server) EnableTLS(certs *certReloader) {
+	s.certs = certs
+	s.httpServer.TLSConfig = certs.TLSConfig()
+
+	if s.config.TLS.RedirectPort > 0 {
+		s.redirectServer = newRedirectServer(s.config.TLS.RedirectPort, s.config.Server.Port)
 	}
 }
 
 // Start 启动服务器
 func (s *Server) Start() error {
-	log.Printf("🚀 服务器启动在端口 :%d", s.config.Port)
-	return s.httpServer.ListenAndServe()
+	if s.wtCerts != nil {
+		go s.wtCerts.watch(s.stopWatch)
+	}
+	if s.webTransport != nil {
+		go func() {
+			log.Printf("🚀 WebTransport 中继监听在 UDP 端口 :%d", s.config.WebTransport.Port)
+			if err := s.webTransport.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logging.Errorf("❌ WebTransport 监听失败: %v", err)
+			}
+		}()
+	}
+
+	if s.certs == nil {
+		log.Printf("🚀 服务器启动在端口 :%d", s.config.Server.Port)
+		return s.httpServer.ListenAndServe()
+	}
+
+	go s.certs.watch(s.stopWatch)
+
+	if s.redirectServer != nil {
+		go func() {
+			log.Printf("↪️ HTTP 重定向监听在端口 :%d", s.config.TLS.RedirectPort)
+			if err := s.redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logging.Errorf("❌ HTTP 重定向监听失败: %v", err)
+			}
+		}()
+	}
+
+	log.Printf("🔒 HTTPS 服务器启动在端口 :%d", s.config.Server.Port)
+	return s.httpServer.ListenAndServeTLS("", "")
 }
 
 // Stop 停止服务器
 func (s *Server) Stop(ctx context.Context) error {
 	log.Println("🛑 正在关闭服务器...")
+	close(s.stopWatch)
+	if s.webTransport != nil {
+		s.webTransport.Close()
+	}
+	if s.redirectServer != nil {
+		s.redirectServer.Shutdown(ctx)
+	}
 	return s.httpServer.Shutdown(ctx)
 }
 
 // WaitForShutdown 等待关闭信号并优雅关闭
 func (s *Server) WaitForShutdown() {
-	// 等待中断信号
+	// 等待中断信号；SIGHUP 或配置文件变化时重新加载配置和证书
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	configChanged := make(chan struct{}, 1)
+	if s.config.Server.WatchConfig && s.config.source != "" {
+		go watchConfigFile(s.config.source, configChanged, s.stopWatch)
+	}
+
+wait:
+	for {
+		select {
+		case sig := <-quit:
+			if sig != syscall.SIGHUP {
+				break wait
+			}
+			log.Printf("🔄 收到 SIGHUP，重新加载配置")
+			s.reload()
+		case <-configChanged:
+			s.reloader.Reload()
+		}
+	}
 
 	// 设置关闭超时
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), s.reloader.current.Server.ShutdownTimeout)
 	defer cancel()
 
 	if err := s.Stop(ctx); err != nil {
@@ -61,9 +136,47 @@ func (s *Server) WaitForShutdown() {
 	log.Println("✅ 服务器已退出")
 }
 
+// reload 处理 SIGHUP：重新加载配置、TLS 证书和单独配置的 WebTransport 证书，不中断现有连接
+func (s *Server) reload() {
+	s.reloader.Reload()
+	reloadCerts("TLS", s.certs)
+	reloadCerts("WebTransport", s.wtCerts)
+}
+
+// reloadCerts 重新加载证书，失败时继续使用当前证书
+func reloadCerts(name string, certs *certReloader) {
+	if certs == nil {
+		return
+	}
+	if err := certs.Reload(); err != nil {
+		logging.Errorf("⚠️ 重新加载 %s 证书失败，继续使用当前证书: %v", name, err)
+		return
+	}
+	log.Printf("🔐 已重新加载 %s 证书", name)
+}
+
 // RunServer 运行服务器（包含启动和优雅关闭）
-func RunServer(config *Config, handler http.Handler) {
-	server := NewServer(config, handler)
+func RunServer(config *Config, handler http.Handler, h *handlers.Handler, reloader *configReloader) {
+	server := NewServer(config, handler, reloader)
+
+	// 可选：启用 HTTPS
+	if config.TLS.Enabled() {
+		certs, err := newCertReloader(config.TLS.Cert, config.TLS.Key)
+		if err != nil {
+			log.Fatalf("❌ TLS 初始化失败: %v", err)
+		}
+		server.EnableTLS(certs)
+	}
+
+	// 可选：启用 WebTransport 中继
+	if config.WebTransport.Port > 0 {
+		wt, wtCerts, err := newWebTransportServer(config, h, reloader.runtime, server.certs)
+		if err != nil {
+			log.Fatalf("❌ WebTransport 初始化失败: %v", err)
+		}
+		server.webTransport = wt
+		server.wtCerts = wtCerts
+	}
 
 	// 启动服务器
 	go func() {
@@ -74,4 +187,8 @@ func RunServer(config *Config, handler http.Handler) {
 
 	// 等待关闭信号
 	server.WaitForShutdown()
+
+	if err := h.Close(); err != nil {
+		logging.Errorf("❌ 关闭处理器失败: %v", err)
+	}
 }
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"chuan/internal/logging"
+)
+
+// certWatchInterval 检查证书文件变化的间隔
+const certWatchInterval = 30 * time.Second
+
+// certReloader 可热更新的 TLS 证书
+// 只影响之后的新握手，已建立的连接（包括 WebSocket 长连接）不受影响
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time // 证书和私钥文件中较新的修改时间
+}
+
+// newCertReloader 加载证书并返回可热更新的证书持有者
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reload 从磁盘重新加载证书，失败时保留当前证书
+func (c *certReloader) Reload() error {
+	modTime, err := c.filesModTime()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("加载 TLS 证书失败: %w", err)
+	}
+
+	c.mu.Lock()
+	c.cert = &cert
+	c.modTime = modTime
+	c.mu.Unlock()
+	return nil
+}
+
+// GetCertificate 供 tls.Config 使用，每次握手时返回当前证书
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// TLSConfig 返回使用当前证书的 TLS 配置
+func (c *certReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: c.GetCertificate,
+	}
+}
+
+// watch 定期检查证书文件，发生变化时自动重新加载，直到 stop 关闭
+func (c *certReloader) watch(stop <-chan struct{}) {
+	ticker := time.NewTicker(certWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			modTime, err := c.filesModTime()
+			if err != nil {
+				continue
+			}
+
+			c.mu.RLock()
+			changed := modTime.After(c.modTime)
+			c.mu.RUnlock()
+
+			if changed {
+				if err := c.Reload(); err != nil {
+					logging.Errorf("⚠️ 证书文件已变化，但重新加载失败: %v", err)
+				} else {
+					log.Printf("🔐 检测到证书文件变化，已重新加载 TLS 证书")
+				}
+			}
+		}
+	}
+}
+
+// filesModTime 返回证书和私钥文件中较新的修改时间
+func (c *certReloader) filesModTime() (time.Time, error) {
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if keyInfo.ModTime().After(certInfo.ModTime()) {
+		return keyInfo.ModTime(), nil
+	}
+	return certInfo.ModTime(), nil
+}
+
+// newRedirectServer 创建把 HTTP 请求重定向到 HTTPS 的服务器
+func newRedirectServer(redirectPort, httpsPort int) *http.Server {
+	return &http.Server{
+		Addr: fmt.Sprintf(":%d", redirectPort),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			if httpsPort != 443 {
+				host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+			}
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+		}),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"time"
+
+	"chuan/internal/handlers"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/quic-go/webtransport-go"
+)
+
+// newWebTransportServer 创建 WebTransport 中继监听器（HTTP/3 over UDP）
+// certs 非空时（已启用 HTTPS）复用同一份可热更新的证书；单独配置了 WT_CERT/WT_KEY 时返回其证书持有者，由调用方监视和重新加载
+func newWebTransportServer(config *Config, h *handlers.Handler, rt *httpRuntime, certs *certReloader) (*webtransport.Server, *certReloader, error) {
+	tlsConfig, wtCerts, err := loadWebTransportTLS(config, certs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mux := http.NewServeMux()
+	server := &webtransport.Server{
+		H3: &http3.Server{
+			Addr:      fmt.Sprintf(":%d", config.WebTransport.Port),
+			Handler:   mux,
+			TLSConfig: http3.ConfigureTLSConfig(tlsConfig),
+			// 复用 WebSocket 心跳配置：QUIC 层保活并在超时后关闭失联的会话
+			QUICConfig: &quic.Config{
+				KeepAlivePeriod: config.WebSocket.PingInterval,
+				MaxIdleTimeout:  config.WebSocket.PongTimeout,
+			},
+		},
+		CheckOrigin: h.CheckOrigin, // 与 WebSocket 中继使用同一来源策略
+	}
+	webtransport.ConfigureHTTP3Server(server.H3)
+
+	mux.Handle(config.Server.basePath()+"/api/wt/relay", rt.proxies.Handler(rt.auth.RequireJoin(h.HandleRelayWebTransport(server))))
+
+	return server, wtCerts, nil
+}
+
+// loadWebTransportTLS 加载 WebTransport 证书
+// 优先使用 WT_CERT/WT_KEY（此时返回其可热更新的证书持有者），其次复用 HTTPS 证书，都未配置时生成仅用于本地测试的自签名证书
+func loadWebTransportTLS(config *Config, certs *certReloader) (*tls.Config, *certReloader, error) {
+	if config.WebTransport.Cert != "" && config.WebTransport.Key != "" {
+		wtCerts, err := newCertReloader(config.WebTransport.Cert, config.WebTransport.Key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("加载 WebTransport 证书失败: %w", err)
+		}
+		return wtCerts.TLSConfig(), wtCerts, nil
+	}
+	if certs != nil {
+		return certs.TLSConfig(), nil, nil
+	}
+
+	cert, err := generateSelfSignedCert()
+	if err != nil {
+		return nil, nil, fmt.Errorf("生成自签名证书失败: %w", err)
+	}
+
+	// 浏览器可通过 serverCertificateHashes 信任有效期不超过 14 天的 ECDSA 自签名证书
+	hash := sha256.Sum256(cert.Leaf.Raw)
+	log.Printf("⚠️ 未配置 TLS_CERT/TLS_KEY 或 WT_CERT/WT_KEY，WebTransport 使用自签名证书（仅限本地测试）")
+	log.Printf("🔑 证书 SHA-256 (serverCertificateHashes): %s", base64.StdEncoding.EncodeToString(hash[:]))
+
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil, nil
+}
+
+// generateSelfSignedCert 生成适用于环回地址的 ECDSA 自签名证书
+func generateSelfSignedCert() (tls.Certificate, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(10 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  priv,
+		Leaf:        leaf,
+	}, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestCert 生成自签名证书并写入 PEM 文件，返回证书的 DER
+func writeTestCert(t *testing.T, certFile, keyFile string) []byte {
+	t.Helper()
+	cert, err := generateSelfSignedCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return cert.Certificate[0]
+}
+
+// TestReloadWebTransportCerts 单独配置的 WebTransport 证书在 SIGHUP 时和 HTTPS 证书一起重新加载
+func TestReloadWebTransportCerts(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "wt.crt"), filepath.Join(dir, "wt.key")
+	writeTestCert(t, certFile, keyFile)
+
+	config := defaultConfig()
+	config.WebTransport.Cert, config.WebTransport.Key = certFile, keyFile
+	tlsConfig, wtCerts, err := loadWebTransportTLS(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wtCerts == nil {
+		t.Fatal("配置了 WT_CERT/WT_KEY 时应返回证书持有者")
+	}
+
+	server := NewServer(config, nil, newConfigReloader(nil, config, nil, nil))
+	server.wtCerts = wtCerts
+
+	renewed := writeTestCert(t, certFile, keyFile)
+	server.reload()
+
+	cert, err := tlsConfig.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cert.Certificate[0], renewed) {
+		t.Fatal("SIGHUP 后 WebTransport 握手应使用新证书")
+	}
+}
+
+// TestWebTransportSharesHTTPSCerts 未单独配置证书时复用 HTTPS 证书，不返回第二个证书持有者
+func TestWebTransportSharesHTTPSCerts(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeTestCert(t, certFile, keyFile)
+	certs, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tlsConfig, wtCerts, err := loadWebTransportTLS(defaultConfig(), certs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wtCerts != nil || tlsConfig.GetCertificate == nil {
+		t.Fatal("应复用 HTTPS 证书")
+	}
+}
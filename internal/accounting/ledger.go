@@ -0,0 +1,322 @@
+// Package accounting 按创建者统计房间数和中继流量，并执行每日/每月配额
+// 用量先在内存中累加，定期写入本地 bbolt 数据库；未配置数据库时只保存在内存中，跨月后只保留当月的用量
+package accounting
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"chuan/internal/logging"
+)
+
+// Anonymous 未经身份验证的创建者，用量照常统计但不受配额限制
+const Anonymous = "anonymous"
+
+// flushInterval 内存中的用量写入数据库的间隔
+const flushInterval = 10 * time.Second
+
+var (
+	// ErrRoomQuota 创建房间数超出配额
+	ErrRoomQuota = errors.New("已超出创建房间配额")
+	// ErrRelayQuota 中继流量超出配额
+	ErrRelayQuota = errors.New("已超出中继流量配额")
+	// ErrInvalidPeriod 统计周期格式无效
+	ErrInvalidPeriod = errors.New("无效的统计周期")
+)
+
+// Usage 一个统计周期内的用量
+type Usage struct {
+	Rooms      int64 `json:"rooms"`
+	RelayBytes int64 `json:"relay_bytes"`
+}
+
+// Quota 配额，0 表示不限制；按 UTC 自然日和自然月计算
+type Quota struct {
+	DailyRooms        int64 `json:"daily_rooms"`
+	MonthlyRooms      int64 `json:"monthly_rooms"`
+	DailyRelayBytes   int64 `json:"daily_relay_bytes"`
+	MonthlyRelayBytes int64 `json:"monthly_relay_bytes"`
+}
+
+// Record 某个创建者在某个周期内的用量
+type Record struct {
+	Identity string `json:"identity"`
+	Period   string `json:"period"` // 2006-01-02 或 2006-01
+	Usage
+}
+
+// Ledger 用量账本，可并发使用
+type Ledger struct {
+	store *store // 为 nil 时只在内存中统计
+
+	mu    sync.Mutex
+	usage map[periodKey]*Usage
+	dirty map[periodKey]Usage // 尚未写入数据库的增量
+	month string              // 内存中用量所在的 UTC 月份，跨月时清理更早的周期
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type periodKey struct {
+	period   string
+	identity string
+}
+
+// Open 打开用量账本，path 为空时不持久化
+func Open(path string) (*Ledger, error) {
+	l := &Ledger{
+		usage: make(map[periodKey]*Usage),
+		dirty: make(map[periodKey]Usage),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if path == "" {
+		close(l.done)
+		return l, nil
+	}
+
+	s, err := openStore(path)
+	if err != nil {
+		return nil, err
+	}
+	l.store = s
+	go l.flushLoop()
+	return l, nil
+}
+
+// Close 写入尚未保存的用量并关闭数据库
+func (l *Ledger) Close() error {
+	select {
+	case <-l.stop:
+		return nil
+	default:
+		close(l.stop)
+	}
+	<-l.done
+	if l.store == nil {
+		return nil
+	}
+	err := l.flush()
+	return errors.Join(err, l.store.close())
+}
+
+// AddRoom 记录一次创建房间
+func (l *Ledger) AddRoom(identity string, now time.Time) {
+	l.add(identity, now, Usage{Rooms: 1})
+}
+
+// AddRelayBytes 记录中继转发的字节数
+func (l *Ledger) AddRelayBytes(identity string, n int64, now time.Time) {
+	if n > 0 {
+		l.add(identity, now, Usage{RelayBytes: n})
+	}
+}
+
+// CheckRoom 判断创建者是否还能创建房间
+func (l *Ledger) CheckRoom(identity string, quota Quota, now time.Time) error {
+	if identity == "" || identity == Anonymous {
+		return nil
+	}
+	day, month := l.Usage(identity, now)
+	if exceeded(day.Rooms, quota.DailyRooms) || exceeded(month.Rooms, quota.MonthlyRooms) {
+		return ErrRoomQuota
+	}
+	return nil
+}
+
+// CheckRelayBytes 判断创建者的中继流量是否已用完
+func (l *Ledger) CheckRelayBytes(identity string, quota Quota, now time.Time) error {
+	if identity == "" || identity == Anonymous {
+		return nil
+	}
+	day, month := l.Usage(identity, now)
+	if exceeded(day.RelayBytes, quota.DailyRelayBytes) || exceeded(month.RelayBytes, quota.MonthlyRelayBytes) {
+		return ErrRelayQuota
+	}
+	return nil
+}
+
+func exceeded(used, limit int64) bool {
+	return limit > 0 && used >= limit
+}
+
+// Usage 返回创建者当天和当月的用量
+func (l *Ledger) Usage(identity string, now time.Time) (day, month Usage) {
+	identity = normalizeIdentity(identity)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rollover(now)
+	return *l.load(periodKey{DayPeriod(now), identity}), *l.load(periodKey{MonthPeriod(now), identity})
+}
+
+// List 返回指定周期内所有创建者的用量，按中继流量从高到低排序
+func (l *Ledger) List(period string) ([]Record, error) {
+	if _, err := ParsePeriod(period); err != nil {
+		return nil, err
+	}
+
+	records := make(map[string]Usage)
+	if l.store != nil {
+		if err := l.flush(); err != nil {
+			return nil, err
+		}
+		stored, err := l.store.list(period)
+		if err != nil {
+			return nil, err
+		}
+		for identity, usage := range stored {
+			records[identity] = usage
+		}
+	} else {
+		l.mu.Lock()
+		for key, usage := range l.usage {
+			if key.period == period {
+				records[key.identity] = *usage
+			}
+		}
+		l.mu.Unlock()
+	}
+
+	list := make([]Record, 0, len(records))
+	for identity, usage := range records {
+		list = append(list, Record{Identity: identity, Period: period, Usage: usage})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].RelayBytes != list[j].RelayBytes {
+			return list[i].RelayBytes > list[j].RelayBytes
+		}
+		return list[i].Identity < list[j].Identity
+	})
+	return list, nil
+}
+
+func (l *Ledger) add(identity string, now time.Time, delta Usage) {
+	identity = normalizeIdentity(identity)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rollover(now)
+
+	for _, key := range []periodKey{{DayPeriod(now), identity}, {MonthPeriod(now), identity}} {
+		usage := l.load(key)
+		usage.Rooms += delta.Rooms
+		usage.RelayBytes += delta.RelayBytes
+		if l.store != nil {
+			pending := l.dirty[key]
+			pending.Rooms += delta.Rooms
+			pending.RelayBytes += delta.RelayBytes
+			l.dirty[key] = pending
+		}
+	}
+}
+
+// rollover 跨月时删除内存中早于当月的周期，避免长时间运行后内存持续增长，调用方需持有 l.mu
+// 尚未写入数据库的周期保留到下次 flush
+func (l *Ledger) rollover(now time.Time) {
+	month := MonthPeriod(now)
+	if month <= l.month {
+		return
+	}
+	l.month = month
+	for key := range l.usage {
+		if _, dirty := l.dirty[key]; !dirty && key.period[:len(month)] < month {
+			delete(l.usage, key)
+		}
+	}
+}
+
+// load 返回内存中的用量，首次访问时从数据库读取，调用方需持有 l.mu
+func (l *Ledger) load(key periodKey) *Usage {
+	if usage, ok := l.usage[key]; ok {
+		return usage
+	}
+	usage := &Usage{}
+	if l.store != nil {
+		stored, err := l.store.get(key.period, key.identity)
+		if err != nil {
+			logging.Errorf("❌ 读取用量失败: %s %s: %v", key.period, key.identity, err)
+		}
+		*usage = stored
+	}
+	l.usage[key] = usage
+	return usage
+}
+
+// flush 把增量写入数据库，并释放已经过去的周期占用的内存
+func (l *Ledger) flush() error {
+	l.mu.Lock()
+	pending := l.dirty
+	l.dirty = make(map[periodKey]Usage)
+	now := time.Now()
+	day, month := DayPeriod(now), MonthPeriod(now)
+	for key := range l.usage {
+		if _, dirty := pending[key]; !dirty && key.period != day && key.period != month {
+			delete(l.usage, key)
+		}
+	}
+	l.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+	if err := l.store.add(pending); err != nil {
+		// 写入失败时把增量放回，下次重试
+		l.mu.Lock()
+		for key, delta := range pending {
+			current := l.dirty[key]
+			current.Rooms += delta.Rooms
+			current.RelayBytes += delta.RelayBytes
+			l.dirty[key] = current
+		}
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (l *Ledger) flushLoop() {
+	defer close(l.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			if err := l.flush(); err != nil {
+				logging.Errorf("❌ 保存用量失败: %v", err)
+			}
+		}
+	}
+}
+
+func normalizeIdentity(identity string) string {
+	if identity == "" {
+		return Anonymous
+	}
+	return identity
+}
+
+// DayPeriod 返回时间所在的 UTC 日期，格式 2006-01-02
+func DayPeriod(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// MonthPeriod 返回时间所在的 UTC 月份，格式 2006-01
+func MonthPeriod(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// ParsePeriod 校验统计周期，返回 "day" 或 "month"
+func ParsePeriod(period string) (string, error) {
+	if _, err := time.Parse("2006-01-02", period); err == nil {
+		return "day", nil
+	}
+	if _, err := time.Parse("2006-01", period); err == nil {
+		return "month", nil
+	}
+	return "", fmt.Errorf("%w %q，应为 2006-01-02 或 2006-01", ErrInvalidPeriod, period)
+}
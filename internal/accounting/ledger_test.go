@@ -0,0 +1,182 @@
+package accounting
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestLedger(t *testing.T, path string) *Ledger {
+	t.Helper()
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func utc(year int, month time.Month, day, hour, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+}
+
+// TestRoomQuotaDayBoundary 每日配额在 UTC 零点重置
+func TestRoomQuotaDayBoundary(t *testing.T) {
+	l := openTestLedger(t, "")
+	quota := Quota{DailyRooms: 2}
+	lastMinute := utc(2026, 3, 10, 23, 59)
+
+	for i := 0; i < 2; i++ {
+		if err := l.CheckRoom("alice", quota, lastMinute); err != nil {
+			t.Fatalf("第 %d 个房间不应超出配额: %v", i+1, err)
+		}
+		l.AddRoom("alice", lastMinute)
+	}
+	if err := l.CheckRoom("alice", quota, lastMinute); !errors.Is(err, ErrRoomQuota) {
+		t.Fatalf("当天第 3 个房间应超出配额, 实际 %v", err)
+	}
+	if err := l.CheckRoom("bob", quota, lastMinute); err != nil {
+		t.Fatalf("配额按创建者计算: %v", err)
+	}
+	// 非 UTC 时区的同一时刻仍属于同一天
+	if err := l.CheckRoom("alice", quota, lastMinute.In(time.FixedZone("UTC+8", 8*3600))); !errors.Is(err, ErrRoomQuota) {
+		t.Fatalf("按 UTC 自然日计算, 实际 %v", err)
+	}
+	if err := l.CheckRoom("alice", quota, lastMinute.Add(time.Minute)); err != nil {
+		t.Fatalf("第二天零点应重置每日配额: %v", err)
+	}
+}
+
+// TestRoomQuotaMonthBoundary 每月配额跨天累计，在下月 1 日 UTC 零点重置
+func TestRoomQuotaMonthBoundary(t *testing.T) {
+	l := openTestLedger(t, "")
+	quota := Quota{DailyRooms: 2, MonthlyRooms: 3}
+
+	l.AddRoom("alice", utc(2026, 1, 1, 0, 0))
+	l.AddRoom("alice", utc(2026, 1, 15, 12, 0))
+	l.AddRoom("alice", utc(2026, 1, 31, 23, 0))
+	if err := l.CheckRoom("alice", quota, utc(2026, 1, 31, 23, 59)); !errors.Is(err, ErrRoomQuota) {
+		t.Fatalf("当月第 4 个房间应超出配额, 实际 %v", err)
+	}
+	if err := l.CheckRoom("alice", quota, utc(2026, 2, 1, 0, 0)); err != nil {
+		t.Fatalf("下月应重置每月配额: %v", err)
+	}
+}
+
+// TestRelayQuota 用量达到配额（而不是超过）时即拒绝，匿名创建者不受限制
+func TestRelayQuota(t *testing.T) {
+	l := openTestLedger(t, "")
+	quota := Quota{DailyRelayBytes: 100, MonthlyRelayBytes: 150}
+	now := utc(2026, 5, 20, 8, 0)
+
+	l.AddRelayBytes("alice", 99, now)
+	if err := l.CheckRelayBytes("alice", quota, now); err != nil {
+		t.Fatalf("未达到配额: %v", err)
+	}
+	l.AddRelayBytes("alice", 1, now)
+	if err := l.CheckRelayBytes("alice", quota, now); !errors.Is(err, ErrRelayQuota) {
+		t.Fatalf("达到每日配额应拒绝, 实际 %v", err)
+	}
+
+	tomorrow := now.Add(24 * time.Hour)
+	if err := l.CheckRelayBytes("alice", quota, tomorrow); err != nil {
+		t.Fatalf("第二天应重置每日配额: %v", err)
+	}
+	l.AddRelayBytes("alice", 50, tomorrow)
+	if err := l.CheckRelayBytes("alice", quota, tomorrow); !errors.Is(err, ErrRelayQuota) {
+		t.Fatalf("达到每月配额应拒绝, 实际 %v", err)
+	}
+
+	l.AddRelayBytes("", 1000, now)
+	if err := l.CheckRelayBytes("", quota, now); err != nil {
+		t.Fatalf("匿名创建者不受配额限制: %v", err)
+	}
+	if day, _ := l.Usage(Anonymous, now); day.RelayBytes != 1000 {
+		t.Fatalf("匿名用量仍应统计, 实际 %+v", day)
+	}
+}
+
+// TestRolloverPrunesOldPeriods 跨月后内存中只保留当月的周期
+func TestRolloverPrunesOldPeriods(t *testing.T) {
+	l := openTestLedger(t, "")
+	for day := 1; day <= 31; day++ {
+		l.AddRoom("alice", utc(2026, 1, day, 12, 0))
+		l.AddRoom("bob", utc(2026, 1, day, 12, 0))
+	}
+	if n := len(l.usage); n != 2*(31+1) {
+		t.Fatalf("1 月应有 64 个周期, 实际 %d", n)
+	}
+
+	feb := utc(2026, 2, 1, 0, 0)
+	l.AddRoom("alice", feb)
+	for key := range l.usage {
+		if key.period != DayPeriod(feb) && key.period != MonthPeriod(feb) {
+			t.Errorf("跨月后不应保留 %v", key)
+		}
+	}
+	day, month := l.Usage("alice", feb)
+	if day.Rooms != 1 || month.Rooms != 1 {
+		t.Fatalf("当月用量 = %+v / %+v", day, month)
+	}
+
+	// 时间回退（如时钟调整）不清理当月数据
+	l.AddRoom("alice", utc(2026, 1, 31, 23, 0))
+	if _, month := l.Usage("alice", feb); month.Rooms != 1 {
+		t.Fatalf("2 月用量不应受影响, 实际 %+v", month)
+	}
+}
+
+// TestPersistence 用量写入数据库，重新打开后继续累加
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.db")
+	now := time.Now()
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.AddRoom("alice", now)
+	l.AddRoom("alice", now)
+	l.AddRelayBytes("alice", 300, now)
+	l.AddRoom("bob", now)
+	l.AddRelayBytes("bob", 500, now)
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	l = openTestLedger(t, path)
+	day, month := l.Usage("alice", now)
+	want := Usage{Rooms: 2, RelayBytes: 300}
+	if day != want || month != want {
+		t.Fatalf("重新打开后 alice 的用量 = %+v / %+v, 期望 %+v", day, month, want)
+	}
+
+	l.AddRoom("alice", now)
+	records, err := l.List(MonthPeriod(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRecords := []Record{
+		{Identity: "bob", Period: MonthPeriod(now), Usage: Usage{Rooms: 1, RelayBytes: 500}},
+		{Identity: "alice", Period: MonthPeriod(now), Usage: Usage{Rooms: 3, RelayBytes: 300}},
+	}
+	if len(records) != len(wantRecords) {
+		t.Fatalf("List = %+v, 期望 %+v", records, wantRecords)
+	}
+	for i := range records {
+		if records[i] != wantRecords[i] {
+			t.Fatalf("List[%d] = %+v, 期望 %+v（按中继流量从高到低排序）", i, records[i], wantRecords[i])
+		}
+	}
+}
+
+// TestListInvalidPeriod 统计周期格式错误时返回错误
+func TestListInvalidPeriod(t *testing.T) {
+	l := openTestLedger(t, "")
+	for _, period := range []string{"", "2026", "2026-13", "2026-01-32", "2026/01"} {
+		if _, err := l.List(period); !errors.Is(err, ErrInvalidPeriod) {
+			t.Errorf("List(%q) 应返回错误", period)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package accounting
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// usageBucket 用量数据所在的 bucket，键为 "<周期>|<创建者>"，值为 Usage 的 JSON
+var usageBucket = []byte("usage")
+
+// store 基于 bbolt 的用量存储
+type store struct {
+	db *bolt.DB
+}
+
+func openStore(path string) (*store, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("打开用量数据库失败: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(usageBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化用量数据库失败: %w", err)
+	}
+	return &store{db: db}, nil
+}
+
+func (s *store) close() error {
+	return s.db.Close()
+}
+
+func storeKey(period, identity string) []byte {
+	return []byte(period + "|" + identity)
+}
+
+func (s *store) get(period, identity string) (Usage, error) {
+	var usage Usage
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(usageBucket).Get(storeKey(period, identity))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &usage)
+	})
+	return usage, err
+}
+
+// add 在一个事务中把增量累加到已保存的用量上
+func (s *store) add(deltas map[periodKey]Usage) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(usageBucket)
+		for key, delta := range deltas {
+			k := storeKey(key.period, key.identity)
+			var usage Usage
+			if data := bucket.Get(k); data != nil {
+				if err := json.Unmarshal(data, &usage); err != nil {
+					return err
+				}
+			}
+			usage.Rooms += delta.Rooms
+			usage.RelayBytes += delta.RelayBytes
+			data, err := json.Marshal(usage)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(k, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// list 返回指定周期内所有创建者的用量
+func (s *store) list(period string) (map[string]Usage, error) {
+	records := make(map[string]Usage)
+	prefix := []byte(period + "|")
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(usageBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && strings.HasPrefix(string(k), string(prefix)); k, v = c.Next() {
+			var usage Usage
+			if err := json.Unmarshal(v, &usage); err != nil {
+				return err
+			}
+			records[strings.TrimPrefix(string(k), string(prefix))] = usage
+		}
+		return nil
+	})
+	return records, err
+}
@@ -0,0 +1,183 @@
+// Package api 定义 /api/v1 接口的请求、响应结构和统一的错误格式
+// 成功时直接返回资源本身，失败时返回 ErrorResponse，HTTP 状态码与错误码一一对应
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"chuan/internal/i18n"
+)
+
+// Prefix v1 接口的路径前缀
+const Prefix = "/api/v1"
+
+// 错误码，客户端应按错误码而不是提示信息判断错误类型
+const (
+	CodeInvalidRequest     = "invalid_request"     // 400 请求体无法解析
+	CodeInvalidRoomCode    = "invalid_room_code"   // 400 指定的房间码长度或字符不合法
+	CodeInvalidTTL         = "invalid_ttl"         // 400 有效期超出允许范围
+	CodeUnauthorized       = "unauthorized"        // 401 未携带凭据
+	CodeInvalidCredentials = "invalid_credentials" // 401 凭据无效
+	CodeRoomCodeReserved   = "room_code_reserved"  // 403 房间码保留给其他身份
+	CodeRoomNotFound       = "room_not_found"      // 404 房间不存在或已过期
+	CodeNotFound           = "not_found"           // 404 接口不存在
+	CodeMethodNotAllowed   = "method_not_allowed"  // 405
+	CodeRoomCodeTaken      = "room_code_taken"     // 409 房间码正在使用
+	CodeQuotaExceeded      = "quota_exceeded"      // 429 超出创建房间配额
+	CodeInternalError      = "internal_error"      // 500
+)
+
+// WebSocket error 消息和旧版接口使用的错误码，room_not_found、quota_exceeded 等与上面共用
+const (
+	CodeInvalidParams      = "invalid_params"       // 连接缺少 code 或 role 不是 sender/receiver
+	CodeRoomExpired        = "room_expired"         // 房间已过期，尚未被清理
+	CodeRoomFull           = "room_full"            // 发送方和接收方都已加入
+	CodeRelayQuotaExceeded = "relay_quota_exceeded" // 房间创建者的中继流量超出配额
+	CodeRelayDisabled      = "relay_disabled"       // 服务器未启用数据中继
+	CodeRelayTransport     = "relay_transport"      // 对方使用了不同的中继传输方式（WebSocket/WebTransport）
+	CodeMissingRoomCode    = "missing_room_code"    // 旧版接口缺少房间码参数
+	CodeInvalidSignalToken = "invalid_signal_token" // SSE 信令提交时客户端未连接或令牌无效
+	CodeInvalidMessage     = "invalid_message"      // SSE 信令提交的消息格式无效
+)
+
+// 管理接口使用的错误码，unauthorized、room_not_found 等与上面共用
+const (
+	CodeAdminDisabled    = "admin_disabled"     // 404 未配置 admin.token，管理接口未启用
+	CodeInvalidDuration  = "invalid_duration"   // 400 延长有效期时既没有有效的 duration 也没有 expires_at
+	CodeInvalidExpiresAt = "invalid_expires_at" // 400 expires_at 不晚于当前时间
+	CodeRoomPersistent   = "room_persistent"    // 400 持久房间不会过期，不能修改有效期
+	CodeRoomMaxLifetime  = "room_max_lifetime"  // 400 房间已达到最长使用时间，不能再延长
+	CodeInvalidPeriod    = "invalid_period"     // 400 用量统计周期不是 2006-01 或 2006-01-02
+)
+
+// Error 错误详情，message 按请求的语言本地化，details 为可选的结构化补充信息
+type Error struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// ErrorResponse 所有 v1 接口失败时的响应体
+type ErrorResponse struct {
+	Error Error `json:"error"`
+}
+
+// CreateRoomRequest POST /api/v1/rooms 的请求体，所有字段可选
+type CreateRoomRequest struct {
+	Code string `json:"code,omitempty"` // 指定房间码，为空时随机生成
+	TTL  int64  `json:"ttl,omitempty"`  // 有效期（秒），为 0 时使用默认有效期
+}
+
+// Room 房间的公开状态
+type Room struct {
+	Code           string    `json:"code"`
+	SenderOnline   bool      `json:"sender_online"`
+	ReceiverOnline bool      `json:"receiver_online"`
+	Full           bool      `json:"full"`       // 发送方和接收方都已加入，无法再加入
+	Persistent     bool      `json:"persistent"` // 持久房间不会过期
+	CreatedAt      time.Time `json:"created_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	LastSeen       time.Time `json:"last_seen"` // 最近一次收到房间内客户端数据的时间
+}
+
+type contextKey struct{}
+
+// Versioned 标记请求属于 v1 接口，之后的中间件（如身份验证）按 v1 格式返回错误
+func Versioned(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, true)))
+	})
+}
+
+// IsVersioned 判断请求是否属于 v1 接口
+func IsVersioned(ctx context.Context) bool {
+	v, _ := ctx.Value(contextKey{}).(bool)
+	return v
+}
+
+type basePathKey struct{}
+
+// WithBasePath 记录请求对外的路径前缀（子路径部署时为 X-Forwarded-Prefix 加 server.base_path），生成链接和重定向地址时使用
+func WithBasePath(r *http.Request, prefix string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), basePathKey{}, prefix))
+}
+
+// BasePath 返回请求对外的路径前缀，不以 / 结尾；部署在根路径时为空字符串
+func BasePath(r *http.Request) string {
+	prefix, _ := r.Context().Value(basePathKey{}).(string)
+	return prefix
+}
+
+type clientKey struct{}
+
+// client 经可信代理解析后的客户端地址和协议
+type client struct {
+	ip     string
+	scheme string
+}
+
+// WithClient 记录请求的真实客户端 IP 和协议（http 或 https），经过可信反向代理时来自 X-Forwarded-For 等请求头
+func WithClient(r *http.Request, ip, scheme string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), clientKey{}, client{ip: ip, scheme: scheme}))
+}
+
+// ClientIP 返回请求的真实客户端 IP，未经 WithClient 记录时为连接的对端地址
+func ClientIP(r *http.Request) string {
+	if c, ok := r.Context().Value(clientKey{}).(client); ok {
+		return c.ip
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// Scheme 返回客户端访问使用的协议，未经 WithClient 记录时按连接是否使用 TLS 判断
+func Scheme(r *http.Request) string {
+	if c, ok := r.Context().Value(clientKey{}).(client); ok {
+		return c.scheme
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// WriteJSON 以指定状态码输出 JSON 响应
+func WriteJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// Language 按请求的 lang 参数或 Accept-Language 选择响应语言，并设置 Content-Language 和 Vary 响应头
+func Language(w http.ResponseWriter, r *http.Request) string {
+	lang := i18n.Negotiate(r)
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept-Language")
+	return lang
+}
+
+// WriteError 输出错误响应，提示信息按请求的语言本地化
+func WriteError(w http.ResponseWriter, r *http.Request, status int, code string, details map[string]interface{}) {
+	lang := Language(w, r)
+	WriteJSON(w, status, ErrorResponse{Error: Error{
+		Code:    code,
+		Message: i18n.T(lang, "error."+code),
+		Details: details,
+	}})
+}
+
+// WriteLegacyError 以旧版接口的格式 {success: false, code, message} 输出错误，提示信息按请求的语言本地化
+func WriteLegacyError(w http.ResponseWriter, r *http.Request, status int, code string) {
+	lang := Language(w, r)
+	WriteJSON(w, status, map[string]interface{}{
+		"success": false,
+		"code":    code,
+		"message": i18n.T(lang, "error."+code),
+	})
+}
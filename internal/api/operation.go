@@ -0,0 +1,34 @@
+package api
+
+import "net/http"
+
+// 接口的身份验证要求，由路由层映射为对应的中间件
+const (
+	AuthNone   = ""       // 不需要身份验证
+	AuthCreate = "create" // 按 auth.create_room 配置要求身份验证
+)
+
+// Operation 一个 v1 接口：路由和处理函数，以及生成 OpenAPI 文档所需的说明
+// 路由注册和接口文档使用同一份定义，文档不会与实际路由脱节
+type Operation struct {
+	ID          string // operationId
+	Method      string
+	Path        string // 相对于 Prefix 的路径，参数写作 {code}
+	Summary     string
+	Description string
+	Tags        []string
+	Auth        string
+	Params      []Param
+	Request     interface{}         // 请求体类型的零值，nil 表示没有请求体
+	Responses   map[int]interface{} // 状态码 → 响应体类型的零值；错误状态码使用 ErrorResponse
+	EventStream interface{}         // 非 nil 时 200 响应为 text/event-stream，值为每条事件 data 的类型
+	Handler     http.HandlerFunc
+}
+
+// Param 路径、查询参数或请求头
+type Param struct {
+	Name        string
+	In          string // path | query | header
+	Description string
+	Required    bool
+}
@@ -0,0 +1,317 @@
+// Package audit 把房间事件追加写入按大小轮转的 JSONL 审计日志
+// 启用哈希链时每条记录带有 prev_hash 和 hash，任何记录被修改、删除或插入都能通过 Verify 发现
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"chuan/internal/events"
+)
+
+// Options 审计日志配置
+type Options struct {
+	Path      string // 当前日志文件，轮转后的文件与其位于同一目录
+	MaxSize   int64  // 单个文件的最大字节数，超过后轮转，0 表示不轮转
+	MaxFiles  int    // 保留的轮转文件数，0 表示全部保留
+	HashChain bool   // 是否启用哈希链
+}
+
+// record 审计日志中的一行
+type record struct {
+	events.Event
+	PrevHash string `json:"prev_hash,omitempty"`
+}
+
+// Writer 审计日志写入器，可并发使用
+type Writer struct {
+	opts Options
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	prevHash string
+}
+
+// Open 打开（或创建）审计日志，启用哈希链时从最后一条记录继续
+func Open(opts Options) (*Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(opts.Path), 0o750); err != nil {
+		return nil, fmt.Errorf("创建审计日志目录失败: %w", err)
+	}
+	w := &Writer{opts: opts}
+	if opts.HashChain {
+		hash, err := lastHash(opts.Path)
+		if err != nil {
+			return nil, fmt.Errorf("读取审计日志哈希链失败: %w", err)
+		}
+		w.prevHash = hash
+	}
+	if err := w.openFile(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) openFile() error {
+	file, err := os.OpenFile(w.opts.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("打开审计日志失败: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("打开审计日志失败: %w", err)
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// Write 追加一条事件
+func (w *Writer) Write(e events.Event) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return errors.New("审计日志已关闭")
+	}
+
+	rec := record{Event: e}
+	if w.opts.HashChain {
+		rec.PrevHash = w.prevHash
+	}
+	body, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	line := body
+	var hash string
+	if w.opts.HashChain {
+		line, hash = appendHash(body)
+	}
+	line = append(line, '\n')
+
+	if w.opts.MaxSize > 0 && w.size > 0 && w.size+int64(len(line)) > w.opts.MaxSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	// 整行写入成功后才推进哈希链，写入失败的记录不会成为下一条记录的 prev_hash
+	n, err := w.file.Write(line)
+	if err != nil {
+		// 截掉写了一半的行，避免后续记录与半行拼在一起
+		if n > 0 {
+			w.file.Truncate(w.size)
+		}
+		return fmt.Errorf("写入审计日志失败: %w", err)
+	}
+	w.size += int64(n)
+	if w.opts.HashChain {
+		w.prevHash = hash
+	}
+	return nil
+}
+
+// Close 关闭审计日志
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// rotate 把当前文件重命名为带时间戳的文件并打开新文件，哈希链跨文件延续，调用方需持有 w.mu
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	ext := filepath.Ext(w.opts.Path)
+	base := strings.TrimSuffix(w.opts.Path, ext)
+	rotated := fmt.Sprintf("%s-%s%s", base, time.Now().UTC().Format("20060102T150405.000000000"), ext)
+	if err := os.Rename(w.opts.Path, rotated); err != nil {
+		// 重新打开当前文件，轮转失败时继续写入原文件
+		if openErr := w.openFile(); openErr != nil {
+			w.file = nil
+			return errors.Join(fmt.Errorf("轮转审计日志失败: %w", err), openErr)
+		}
+		return fmt.Errorf("轮转审计日志失败: %w", err)
+	}
+	if err := w.openFile(); err != nil {
+		return err
+	}
+
+	if w.opts.MaxFiles > 0 {
+		files, err := rotatedFiles(w.opts.Path)
+		if err != nil {
+			return err
+		}
+		for len(files) > w.opts.MaxFiles {
+			os.Remove(files[0])
+			files = files[1:]
+		}
+	}
+	return nil
+}
+
+// rotatedFiles 返回已轮转的文件，按时间从旧到新排序
+func rotatedFiles(path string) ([]string, error) {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	files, err := filepath.Glob(base + "-*" + ext)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Files 返回所有审计日志文件（已轮转的文件在前，当前文件在最后），用于按顺序校验
+func Files(path string) ([]string, error) {
+	files, err := rotatedFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// lastHash 返回最后一条记录的 hash，当前文件为空时查找最近轮转的文件
+// 最后一条记录没有 hash（之前未启用哈希链）时从新的哈希链开始
+func lastHash(path string) (string, error) {
+	files, err := Files(path)
+	if err != nil {
+		return "", err
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		last, err := lastLine(files[i])
+		if err != nil {
+			return "", err
+		}
+		if last == nil {
+			continue
+		}
+		if _, hash, err := splitHash(last); err == nil {
+			return hash, nil
+		}
+		return "", nil
+	}
+	return "", nil
+}
+
+// lastLine 读取文件的最后一行，文件为空时返回 nil
+func lastLine(name string) ([]byte, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	// 单条记录远小于 1MB，只需读取文件末尾
+	const tail = 1 << 20
+	offset := max(info.Size()-tail, 0)
+	data := make([]byte, info.Size()-offset)
+	if _, err := file.ReadAt(data, offset); err != nil {
+		return nil, err
+	}
+	data = bytes.TrimRight(data, "\n")
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return data[bytes.LastIndexByte(data, '\n')+1:], nil
+}
+
+// hashSuffix 哈希链记录以 ,"hash":"<sha256>"} 结尾，哈希覆盖该后缀之前的全部字节
+const hashSuffix = `,"hash":"`
+
+// appendHash 计算记录的哈希并追加到 JSON 末尾
+func appendHash(body []byte) ([]byte, string) {
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	line := make([]byte, 0, len(body)+len(hashSuffix)+len(hash)+2)
+	line = append(line, body[:len(body)-1]...)
+	line = append(line, hashSuffix...)
+	line = append(line, hash...)
+	line = append(line, '"', '}')
+	return line, hash
+}
+
+// splitHash 拆分出记录本体和其中的 hash
+func splitHash(line []byte) ([]byte, string, error) {
+	i := bytes.LastIndex(line, []byte(hashSuffix))
+	if i < 0 || !bytes.HasSuffix(line, []byte(`"}`)) {
+		return nil, "", errors.New("缺少 hash 字段")
+	}
+	hash := string(line[i+len(hashSuffix) : len(line)-2])
+	body := append(append([]byte{}, line[:i]...), '}')
+	return body, hash, nil
+}
+
+// Verify 按顺序校验审计日志文件的哈希链，返回校验通过的记录数
+// 第一条记录的 prev_hash 无法校验（之前的文件可能已被清理），之后每条记录都必须与前一条衔接
+func Verify(files ...string) (int, error) {
+	count := 0
+	prev := ""
+	for _, name := range files {
+		file, err := os.Open(name)
+		if err != nil {
+			return count, err
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for lineNo := 1; scanner.Scan(); lineNo++ {
+			line := scanner.Bytes()
+			if len(line) == 0 {
+				continue
+			}
+			body, hash, err := splitHash(line)
+			if err != nil {
+				file.Close()
+				return count, fmt.Errorf("%s:%d: %w", name, lineNo, err)
+			}
+			sum := sha256.Sum256(body)
+			if hex.EncodeToString(sum[:]) != hash {
+				file.Close()
+				return count, fmt.Errorf("%s:%d: 哈希不匹配，记录已被修改", name, lineNo)
+			}
+			var rec record
+			if err := json.Unmarshal(body, &rec); err != nil {
+				file.Close()
+				return count, fmt.Errorf("%s:%d: %w", name, lineNo, err)
+			}
+			if count > 0 && rec.PrevHash != prev {
+				file.Close()
+				return count, fmt.Errorf("%s:%d: prev_hash 与上一条记录不衔接，记录可能被删除或插入", name, lineNo)
+			}
+			prev = hash
+			count++
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return count, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,216 @@
+package audit
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"chuan/internal/events"
+)
+
+func testEvent(i int) events.Event {
+	return events.Event{
+		Time: time.Date(2026, 1, 1, 0, 0, i, 0, time.UTC),
+		Type: events.RoomCreated,
+		Room: fmt.Sprintf("ROOM%02d", i),
+	}
+}
+
+func openTestWriter(t *testing.T, opts Options) *Writer {
+	t.Helper()
+	w, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Close() })
+	return w
+}
+
+func writeEvents(t *testing.T, w *Writer, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		if err := w.Write(testEvent(i)); err != nil {
+			t.Fatalf("写入第 %d 条记录失败: %v", i, err)
+		}
+	}
+}
+
+func verifyAll(t *testing.T, path string) (int, error) {
+	t.Helper()
+	files, err := Files(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Verify(files...)
+}
+
+// TestRotation 超过 MaxSize 时轮转，哈希链跨文件延续
+func TestRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	w := openTestWriter(t, Options{Path: path, MaxSize: 400, HashChain: true})
+	writeEvents(t, w, 0, 20)
+
+	files, err := Files(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) < 3 {
+		t.Fatalf("应产生多个轮转文件, 实际 %v", files)
+	}
+	for _, name := range files {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > 400 {
+			t.Errorf("%s 大小 %d 超过 MaxSize", name, info.Size())
+		}
+	}
+	if n, err := Verify(files...); err != nil || n != 20 {
+		t.Fatalf("Verify = %d, %v, 期望 20 条记录通过", n, err)
+	}
+}
+
+// TestRotationMaxFiles 超出 MaxFiles 的旧文件被删除，剩余文件仍能校验
+func TestRotationMaxFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	w := openTestWriter(t, Options{Path: path, MaxSize: 400, MaxFiles: 2, HashChain: true})
+	writeEvents(t, w, 0, 20)
+
+	rotated, err := rotatedFiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 2 {
+		t.Fatalf("应保留 2 个轮转文件, 实际 %v", rotated)
+	}
+	// 第一条记录的 prev_hash 指向已删除的文件，不参与校验
+	if n, err := verifyAll(t, path); err != nil || n == 0 || n >= 20 {
+		t.Fatalf("Verify = %d, %v", n, err)
+	}
+}
+
+// TestReopenContinuesChain 重新打开后从最后一条记录的 hash 继续
+func TestReopenContinuesChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	w, err := Open(Options{Path: path, HashChain: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeEvents(t, w, 0, 3)
+	w.Close()
+
+	w = openTestWriter(t, Options{Path: path, HashChain: true})
+	writeEvents(t, w, 3, 6)
+	if n, err := verifyAll(t, path); err != nil || n != 6 {
+		t.Fatalf("Verify = %d, %v, 期望 6 条记录通过", n, err)
+	}
+}
+
+// TestVerifyDetectsTampering 修改、删除或插入记录都会被发现
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(lines [][]byte) [][]byte
+		want   string
+	}{
+		{"修改记录", func(lines [][]byte) [][]byte {
+			lines[2] = bytes.Replace(lines[2], []byte("ROOM02"), []byte("ROOM99"), 1)
+			return lines
+		}, "哈希不匹配"},
+		{"删除记录", func(lines [][]byte) [][]byte {
+			return append(lines[:2], lines[3:]...)
+		}, "不衔接"},
+		{"调换顺序", func(lines [][]byte) [][]byte {
+			lines[1], lines[2] = lines[2], lines[1]
+			return lines
+		}, "不衔接"},
+		{"插入记录", func(lines [][]byte) [][]byte {
+			forged := append([]byte{}, lines[1]...)
+			return append(lines[:2], append([][]byte{forged}, lines[2:]...)...)
+		}, "不衔接"},
+		{"删除 hash", func(lines [][]byte) [][]byte {
+			i := bytes.LastIndex(lines[3], []byte(hashSuffix))
+			lines[3] = append(lines[3][:i:i], '}')
+			return lines
+		}, "缺少 hash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.jsonl")
+			w := openTestWriter(t, Options{Path: path, HashChain: true})
+			writeEvents(t, w, 0, 5)
+			w.Close()
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := tt.tamper(bytes.Split(bytes.TrimRight(data, "\n"), []byte("\n")))
+			if err := os.WriteFile(path, append(bytes.Join(lines, []byte("\n")), '\n'), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = Verify(path)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Verify 错误 = %v, 期望包含 %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestFailedWriteKeepsChain 写入失败的记录不进入哈希链，之后的记录仍能通过校验
+func TestFailedWriteKeepsChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	w := openTestWriter(t, Options{Path: path, HashChain: true})
+	writeEvents(t, w, 0, 2)
+
+	prev := w.prevHash
+	w.file.Close() // 模拟磁盘错误
+	if err := w.Write(testEvent(2)); err == nil {
+		t.Fatal("文件已关闭时写入应失败")
+	}
+	if w.prevHash != prev {
+		t.Fatal("写入失败后不应推进哈希链")
+	}
+
+	if err := w.openFile(); err != nil {
+		t.Fatal(err)
+	}
+	writeEvents(t, w, 3, 5)
+	if n, err := verifyAll(t, path); err != nil || n != 4 {
+		t.Fatalf("Verify = %d, %v, 期望 4 条记录通过", n, err)
+	}
+}
+
+// TestFailedRotationKeepsChain 轮转失败的记录不进入哈希链，之后的记录接在最后一条成功的记录之后
+func TestFailedRotationKeepsChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	w := openTestWriter(t, Options{Path: path, MaxSize: 400, HashChain: true})
+	writeEvents(t, w, 0, 2)
+
+	// 当前文件被删除后无法重命名，轮转失败
+	prev := w.prevHash
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(testEvent(2)); err == nil {
+		t.Fatal("轮转失败时写入应返回错误")
+	}
+	if w.prevHash != prev {
+		t.Fatal("轮转失败后不应推进哈希链")
+	}
+
+	writeEvents(t, w, 3, 4)
+	last, err := lastLine(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(last, []byte(`"prev_hash":"`+prev+`"`)) {
+		t.Fatalf("轮转失败后的记录应接在最后一条成功的记录之后: %s", last)
+	}
+}
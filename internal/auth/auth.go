@@ -0,0 +1,175 @@
+// Package auth 提供创建房间和加入房间的身份验证
+// 支持静态 API Key、基于 bcrypt htpasswd 文件的 HTTP Basic 认证和 OIDC Bearer 令牌（通过 JWKS 校验签名）
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrNoCredentials 请求未携带任何凭据
+	ErrNoCredentials = errors.New("未提供身份凭据")
+	// ErrInvalidCredentials 凭据无效或已过期
+	ErrInvalidCredentials = errors.New("身份凭据无效")
+)
+
+// 认证方式
+const (
+	MethodAPIKey = "api_key"
+	MethodBasic  = "basic"
+	MethodOIDC   = "oidc"
+)
+
+// Principal 通过身份验证的调用方
+type Principal struct {
+	Subject string `json:"subject"` // API Key 名称、htpasswd 用户名或 OIDC sub
+	Method  string `json:"method"`  // api_key | basic | oidc
+}
+
+// Identity 返回用于用量统计的身份标识，形如 basic:alice，避免不同认证方式的同名用户混淆
+func (p *Principal) Identity() string {
+	return p.Method + ":" + p.Subject
+}
+
+// Config 认证后端配置，未配置的后端不启用
+type Config struct {
+	// APIKeys 静态 API Key，格式为 "名称:密钥" 或仅 "密钥"
+	APIKeys []string
+	// HtpasswdFile htpasswd 文件路径，只支持 bcrypt 哈希（htpasswd -B）
+	HtpasswdFile string
+	OIDC         OIDCConfig
+}
+
+// Authenticator 按请求携带的凭据类型依次尝试已启用的认证后端
+type Authenticator struct {
+	apiKeys  []apiKey
+	htpasswd map[string][]byte
+	oidc     *oidcVerifier
+}
+
+type apiKey struct {
+	name string
+	key  []byte
+}
+
+// New 根据配置创建认证器，htpasswd 文件在此时读取
+func New(cfg Config) (*Authenticator, error) {
+	a := &Authenticator{}
+	for _, entry := range cfg.APIKeys {
+		name, key, ok := strings.Cut(entry, ":")
+		if !ok {
+			// 未命名的密钥以哈希前缀标识，避免在日志中泄露
+			key = entry
+			sum := sha256.Sum256([]byte(entry))
+			name = "key-" + hex.EncodeToString(sum[:4])
+		}
+		if key == "" {
+			return nil, errors.New("API Key 不能为空")
+		}
+		a.apiKeys = append(a.apiKeys, apiKey{name: name, key: []byte(key)})
+	}
+
+	if cfg.HtpasswdFile != "" {
+		users, err := LoadHtpasswd(cfg.HtpasswdFile)
+		if err != nil {
+			return nil, err
+		}
+		a.htpasswd = users
+	}
+
+	if cfg.OIDC.Enabled() {
+		a.oidc = newOIDCVerifier(cfg.OIDC)
+	}
+	return a, nil
+}
+
+// Enabled 是否至少启用了一种认证后端
+func (a *Authenticator) Enabled() bool {
+	return len(a.apiKeys) > 0 || a.htpasswd != nil || a.oidc != nil
+}
+
+// Methods 返回已启用的认证方式
+func (a *Authenticator) Methods() []string {
+	var methods []string
+	if len(a.apiKeys) > 0 {
+		methods = append(methods, MethodAPIKey)
+	}
+	if a.htpasswd != nil {
+		methods = append(methods, MethodBasic)
+	}
+	if a.oidc != nil {
+		methods = append(methods, MethodOIDC)
+	}
+	return methods
+}
+
+// Challenge 返回 401 响应的 WWW-Authenticate 头
+func (a *Authenticator) Challenge() string {
+	if a.htpasswd != nil {
+		return `Basic realm="chuan", charset="UTF-8"`
+	}
+	return `Bearer realm="chuan"`
+}
+
+// Authenticate 校验请求携带的凭据
+// 支持 X-API-Key 头、Authorization: Basic、Authorization: Bearer，
+// 以及供 WebSocket/EventSource 使用的 access_token 查询参数（浏览器无法为这两者设置请求头）
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return a.checkAPIKey(key)
+	}
+
+	if user, password, ok := r.BasicAuth(); ok {
+		if a.htpasswd == nil {
+			return nil, ErrInvalidCredentials
+		}
+		if !checkHtpasswd(a.htpasswd, user, password) {
+			return nil, ErrInvalidCredentials
+		}
+		return &Principal{Subject: user, Method: MethodBasic}, nil
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.URL.Query().Get("access_token")
+	}
+	if token == "" {
+		return nil, ErrNoCredentials
+	}
+
+	if p, err := a.checkAPIKey(token); err == nil {
+		return p, nil
+	}
+	if a.oidc != nil && strings.Count(token, ".") == 2 {
+		return a.oidc.Verify(r.Context(), token)
+	}
+	return nil, ErrInvalidCredentials
+}
+
+func (a *Authenticator) checkAPIKey(key string) (*Principal, error) {
+	for _, k := range a.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(key), k.key) == 1 {
+			return &Principal{Subject: k.name, Method: MethodAPIKey}, nil
+		}
+	}
+	return nil, ErrInvalidCredentials
+}
+
+type principalKey struct{}
+
+// WithPrincipal 把通过验证的调用方存入请求上下文
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom 读取请求上下文中的调用方，未经过身份验证时返回 nil
+func PrincipalFrom(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newRequest(header, value string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/rooms", nil)
+	if header != "" {
+		r.Header.Set(header, value)
+	}
+	return r
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	a, err := New(Config{APIKeys: []string{"ci:ci-secret", "anonymous-secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		r        *http.Request
+		identity string
+		err      error
+	}{
+		{"X-API-Key 命名密钥", newRequest("X-API-Key", "ci-secret"), "api_key:ci", nil},
+		{"Bearer 携带 API Key", newRequest("Authorization", "Bearer ci-secret"), "api_key:ci", nil},
+		{"未命名密钥", newRequest("X-API-Key", "anonymous-secret"), "api_key:key-", nil},
+		{"错误的密钥", newRequest("X-API-Key", "wrong"), "", ErrInvalidCredentials},
+		{"密钥前缀", newRequest("X-API-Key", "ci-secre"), "", ErrInvalidCredentials},
+		{"未启用 OIDC 时的 JWT", newRequest("Authorization", "Bearer a.b.c"), "", ErrInvalidCredentials},
+		{"没有凭据", newRequest("", ""), "", ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := a.Authenticate(tt.r)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("期望 %v, 实际 principal=%v err=%v", tt.err, p, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(p.Identity(), tt.identity) {
+				t.Fatalf("identity = %s, 期望 %s", p.Identity(), tt.identity)
+			}
+		})
+	}
+
+	if _, err := New(Config{APIKeys: []string{"empty:"}}); err == nil {
+		t.Error("空密钥应返回错误")
+	}
+}
+
+// writeHtpasswd 写入临时 htpasswd 文件，密码使用最低成本的 bcrypt 哈希
+func writeHtpasswd(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func bcryptLine(t *testing.T, user, password string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user + ":" + string(hash)
+}
+
+func TestAuthenticateHtpasswd(t *testing.T) {
+	path := writeHtpasswd(t, "# 注释", "", bcryptLine(t, "alice", "s3cret"), bcryptLine(t, "bob", "hunter2"))
+	a, err := New(Config{HtpasswdFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	basic := func(user, password string) *http.Request {
+		r := newRequest("", "")
+		r.SetBasicAuth(user, password)
+		return r
+	}
+	if p, err := a.Authenticate(basic("alice", "s3cret")); err != nil || p.Identity() != "basic:alice" {
+		t.Fatalf("正确的密码校验失败: %v %v", p, err)
+	}
+	for _, r := range []*http.Request{basic("alice", "hunter2"), basic("mallory", "s3cret"), basic("", "")} {
+		if _, err := a.Authenticate(r); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("错误的用户名或密码应返回 ErrInvalidCredentials: %v", err)
+		}
+	}
+	if got := a.Challenge(); !strings.HasPrefix(got, "Basic ") {
+		t.Errorf("启用 htpasswd 时应返回 Basic 质询: %s", got)
+	}
+
+	// 未启用 htpasswd 时 Basic 认证一律无效
+	noBasic, _ := New(Config{APIKeys: []string{"k"}})
+	if _, err := noBasic.Authenticate(basic("alice", "s3cret")); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("未启用 htpasswd 时应返回 ErrInvalidCredentials: %v", err)
+	}
+}
+
+func TestLoadHtpasswdRejectsInvalid(t *testing.T) {
+	for name, line := range map[string]string{
+		"非 bcrypt 哈希": "alice:$apr1$abc$def",
+		"SHA 哈希":      "alice:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=",
+		"缺少冒号":        "alice",
+		"空用户名":        ":$2y$05$abcdefghijklmnopqrstuv",
+	} {
+		if _, err := LoadHtpasswd(writeHtpasswd(t, line)); err == nil {
+			t.Errorf("%s: 应返回错误", name)
+		}
+	}
+	if _, err := LoadHtpasswd(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("文件不存在时应返回错误")
+	}
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummyHash 用户不存在时参与比较的哈希，使响应时间与密码错误时一致
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("chuan"), bcrypt.DefaultCost)
+	return hash
+})
+
+// LoadHtpasswd 读取 htpasswd 文件（每行 用户名:bcrypt哈希），只支持 htpasswd -B 生成的 bcrypt 哈希
+func LoadHtpasswd(path string) (map[string][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 htpasswd 文件失败: %w", err)
+	}
+	defer file.Close()
+
+	users := make(map[string][]byte)
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		user, hash, ok := strings.Cut(line, ":")
+		if !ok || user == "" {
+			return nil, fmt.Errorf("htpasswd 文件 %s 第 %d 行格式无效", path, lineNo)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("htpasswd 文件 %s 第 %d 行 (%s) 不是 bcrypt 哈希，请使用 htpasswd -B 生成", path, lineNo, user)
+		}
+		users[user] = []byte(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取 htpasswd 文件失败: %w", err)
+	}
+	return users, nil
+}
+
+// checkHtpasswd 校验用户名和密码
+func checkHtpasswd(users map[string][]byte, user, password string) bool {
+	hash, ok := users[user]
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
@@ -0,0 +1,392 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // 注册 crypto.SHA256
+	_ "crypto/sha512" // 注册 crypto.SHA384/SHA512
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksCacheTTL JWKS 缓存时间，过期后下次校验时重新获取
+	jwksCacheTTL = time.Hour
+	// jwksMinRefresh 两次获取 JWKS 的最小间隔（无论成功与否），避免伪造的 kid 或不可用的签发者触发大量请求
+	jwksMinRefresh = time.Minute
+	// jwksFetchTimeout 获取 JWKS（包括发现配置）的超时
+	jwksFetchTimeout = 10 * time.Second
+	// clockSkew 校验 exp/nbf 时容忍的时钟偏差
+	clockSkew = time.Minute
+)
+
+// OIDCConfig OIDC 令牌校验配置
+type OIDCConfig struct {
+	Issuer   string // 令牌签发者，设置后校验 iss；未设置 JWKSURL 时通过 <Issuer>/.well-known/openid-configuration 发现
+	JWKSURL  string // JWKS 地址
+	Audience string // 设置后校验 aud 包含该值
+}
+
+// Enabled 是否启用 OIDC
+func (c OIDCConfig) Enabled() bool {
+	return c.Issuer != "" || c.JWKSURL != ""
+}
+
+// oidcVerifier 校验 OIDC 签发的 JWT，公钥从 JWKS 获取并缓存
+// 获取 JWKS 时不持有锁，同时需要刷新的请求合并为一次获取；获取失败的结果同样缓存 jwksMinRefresh
+type oidcVerifier struct {
+	cfg    OIDCConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey // 最近一次成功获取的公钥，刷新失败时继续使用
+	fetchedAt time.Time                   // 最近一次成功获取的时间
+	attempted time.Time                   // 最近一次开始获取的时间
+	fetchErr  error                       // 最近一次获取失败的原因，成功后清空
+	inflight  *jwksFetch                  // 正在进行的获取
+}
+
+// jwksFetch 一次进行中的 JWKS 获取，done 关闭后 err 可读
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+func newOIDCVerifier(cfg OIDCConfig) *oidcVerifier {
+	return &oidcVerifier{
+		cfg:    cfg,
+		client: &http.Client{Timeout: jwksFetchTimeout},
+	}
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *int64   `json:"exp"`
+	NotBefore *int64   `json:"nbf"`
+}
+
+// audience aud 声明可以是字符串或字符串数组
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// Verify 校验令牌签名和 iss/aud/exp/nbf 声明
+func (v *oidcVerifier) Verify(ctx context.Context, token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidCredentials
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	now := time.Now()
+	switch {
+	case claims.ExpiresAt == nil || now.After(time.Unix(*claims.ExpiresAt, 0).Add(clockSkew)):
+		return nil, ErrInvalidCredentials
+	case claims.NotBefore != nil && now.Add(clockSkew).Before(time.Unix(*claims.NotBefore, 0)):
+		return nil, ErrInvalidCredentials
+	case v.cfg.Issuer != "" && strings.TrimSuffix(claims.Issuer, "/") != strings.TrimSuffix(v.cfg.Issuer, "/"):
+		return nil, ErrInvalidCredentials
+	case v.cfg.Audience != "" && !containsString(claims.Audience, v.cfg.Audience):
+		return nil, ErrInvalidCredentials
+	case claims.Subject == "":
+		return nil, ErrInvalidCredentials
+	}
+	return &Principal{Subject: claims.Subject, Method: MethodOIDC}, nil
+}
+
+// key 返回 kid 对应的公钥，缓存过期或遇到未知 kid 时重新获取 JWKS
+// 距上次获取不足 jwksMinRefresh 时不再请求签发者，直接按已缓存的结果判断
+func (v *oidcVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	var fetch *jwksFetch
+	started := false // 本次调用发起了获取，负责返回获取失败的原因
+	for {
+		v.mu.Lock()
+		fresh := v.keys != nil && time.Since(v.fetchedAt) <= jwksCacheTTL
+		if key := lookupKey(v.keys, kid); key != nil && fresh {
+			v.mu.Unlock()
+			return key, nil
+		}
+
+		if fetch == nil && v.inflight == nil && time.Since(v.attempted) >= jwksMinRefresh {
+			v.inflight = &jwksFetch{done: make(chan struct{})}
+			v.attempted = time.Now()
+			started = true
+			go v.refresh(v.inflight)
+		}
+		if fetch == nil && v.inflight != nil {
+			// 等待进行中的获取（可能由其他请求发起）完成后重新查找
+			fetch = v.inflight
+			v.mu.Unlock()
+			select {
+			case <-fetch.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			continue
+		}
+
+		// 本次调用已等待过一次获取，或刚获取过：使用缓存的结果，过期的公钥在刷新失败时继续使用
+		key, keys, fetchErr := lookupKey(v.keys, kid), v.keys, v.fetchErr
+		v.mu.Unlock()
+		switch {
+		case key != nil:
+			return key, nil
+		case keys == nil && started && fetch.err != nil:
+			return nil, fetch.err
+		case keys == nil && fetchErr != nil:
+			// 失败已由发起获取的请求返回，这里不再作为服务器错误重复上报
+			return nil, fmt.Errorf("%w: JWKS 暂不可用", ErrInvalidCredentials)
+		}
+		return nil, ErrInvalidCredentials
+	}
+}
+
+// refresh 在后台获取 JWKS 并更新缓存，不受发起请求的取消影响
+func (v *oidcVerifier) refresh(fetch *jwksFetch) {
+	ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+	defer cancel()
+	keys, err := v.fetchKeys(ctx)
+
+	v.mu.Lock()
+	if err == nil {
+		v.keys, v.fetchedAt, v.fetchErr = keys, time.Now(), nil
+	} else {
+		v.fetchErr = err
+	}
+	v.inflight = nil
+	v.mu.Unlock()
+
+	fetch.err = err
+	close(fetch.done)
+}
+
+// lookupKey 按 kid 查找公钥；令牌未指定 kid 且 JWKS 只有一个公钥时使用该公钥
+func lookupKey(keys map[string]crypto.PublicKey, kid string) crypto.PublicKey {
+	if key, ok := keys[kid]; ok {
+		return key
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key
+		}
+	}
+	return nil
+}
+
+// fetchKeys 获取 JWKS 中的签名公钥
+func (v *oidcVerifier) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	jwksURL := v.cfg.JWKSURL
+	if jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := v.getJSON(ctx, strings.TrimSuffix(v.cfg.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+			return nil, fmt.Errorf("获取 OIDC 配置失败: %w", err)
+		}
+		if discovery.JWKSURI == "" {
+			return nil, errors.New("OIDC 配置中缺少 jwks_uri")
+		}
+		jwksURL = discovery.JWKSURI
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := v.getJSON(ctx, jwksURL, &set); err != nil {
+		return nil, fmt.Errorf("获取 JWKS 失败: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// 不支持的密钥类型直接跳过，不影响其余公钥
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (v *oidcVerifier) getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s 返回 %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// jsonWebKey JWKS 中的一个公钥（RFC 7517），支持 RSA 和 EC
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("不支持的曲线: %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("不支持的密钥类型: %s", k.Kty)
+	}
+}
+
+// verifySignature 按 alg 校验 JWS 签名，只接受非对称算法
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("不支持的签名算法: %s", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			return rsa.VerifyPKCS1v15(key, hash, digest, signature)
+		case "PS":
+			return rsa.VerifyPSS(key, hash, digest, signature, nil)
+		}
+	case *ecdsa.PublicKey:
+		// ES256/ES384/ES512 分别对应 P-256/P-384/P-521 曲线
+		bits := key.Curve.Params().BitSize
+		size := (bits + 7) / 8
+		if alg[:2] != "ES" || alg[2:] != map[int]string{256: "256", 384: "384", 521: "512"}[bits] || len(signature) != 2*size {
+			break
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if ecdsa.Verify(key, digest, r, s) {
+			return nil
+		}
+		return errors.New("签名无效")
+	}
+	return fmt.Errorf("签名算法 %s 与公钥类型不匹配", alg)
+}
+
+func decodeSegment(segment string, out interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("无效的 base64url 整数")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,335 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mockIssuer 本地 OIDC 签发者：提供发现文档和 JWKS，并用 RSA 私钥签发令牌
+type mockIssuer struct {
+	t         *testing.T
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	kid       string
+	jwksHits  atomic.Int32
+	fail      atomic.Bool   // JWKS 返回 500
+	blockJWKS chan struct{} // 非 nil 时 JWKS 请求阻塞到该通道关闭
+	mu        sync.Mutex
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockIssuer{t: t, key: key, kid: "test-key"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": m.server.URL, "jwks_uri": m.server.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		m.jwksHits.Add(1)
+		m.mu.Lock()
+		block := m.blockJWKS
+		m.mu.Unlock()
+		if block != nil {
+			<-block
+		}
+		if m.fail.Load() {
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": m.kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// block 使之后的 JWKS 请求阻塞，返回解除阻塞的函数
+func (m *mockIssuer) block() (release func()) {
+	ch := make(chan struct{})
+	m.mu.Lock()
+	m.blockJWKS = ch
+	m.mu.Unlock()
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			m.mu.Lock()
+			m.blockJWKS = nil
+			m.mu.Unlock()
+			close(ch)
+		})
+	}
+	m.t.Cleanup(release)
+	return release
+}
+
+// claims 返回有效的声明，可以在测试中修改
+func (m *mockIssuer) claims() map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss": m.server.URL,
+		"sub": "alice",
+		"aud": []string{"chuan", "other"},
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+}
+
+// sign 使用 RS256 签发令牌
+func (m *mockIssuer) sign(kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, digest[:])
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (m *mockIssuer) verifier() *oidcVerifier {
+	return newOIDCVerifier(OIDCConfig{Issuer: m.server.URL, Audience: "chuan"})
+}
+
+func TestOIDCVerify(t *testing.T) {
+	m := newMockIssuer(t)
+	v := m.verifier()
+
+	with := func(change func(c map[string]interface{})) map[string]interface{} {
+		c := m.claims()
+		change(c)
+		return c
+	}
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"有效令牌", m.sign(m.kid, m.claims()), true},
+		{"aud 为字符串", m.sign(m.kid, with(func(c map[string]interface{}) { c["aud"] = "chuan" })), true},
+		{"已过期", m.sign(m.kid, with(func(c map[string]interface{}) { c["exp"] = time.Now().Add(-2 * clockSkew).Unix() })), false},
+		{"缺少 exp", m.sign(m.kid, with(func(c map[string]interface{}) { delete(c, "exp") })), false},
+		{"尚未生效", m.sign(m.kid, with(func(c map[string]interface{}) { c["nbf"] = time.Now().Add(2 * clockSkew).Unix() })), false},
+		{"受众不符", m.sign(m.kid, with(func(c map[string]interface{}) { c["aud"] = "someone-else" })), false},
+		{"签发者不符", m.sign(m.kid, with(func(c map[string]interface{}) { c["iss"] = "https://evil.example" })), false},
+		{"缺少 sub", m.sign(m.kid, with(func(c map[string]interface{}) { delete(c, "sub") })), false},
+		{"未知 kid", m.sign("unknown", m.claims()), false},
+		{"声明被篡改", tamperClaims(m.sign(m.kid, m.claims())), false},
+		{"alg none", unsignedToken(m.claims()), false},
+		{"格式无效", "not.a-token", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := v.Verify(context.Background(), tt.token)
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidCredentials) {
+					t.Fatalf("期望 ErrInvalidCredentials, 实际 principal=%v err=%v", p, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("校验失败: %v", err)
+			}
+			if p.Subject != "alice" || p.Method != MethodOIDC || p.Identity() != "oidc:alice" {
+				t.Fatalf("principal = %+v", p)
+			}
+		})
+	}
+	// 有效的公钥已缓存，未知 kid 在最小刷新间隔内不会再次请求 JWKS
+	if hits := m.jwksHits.Load(); hits != 1 {
+		t.Errorf("JWKS 请求了 %d 次, 期望 1 次", hits)
+	}
+}
+
+// tamperClaims 把令牌中的 sub 改为 mallory，保留原签名
+func tamperClaims(token string) string {
+	parts := strings.Split(token, ".")
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	payload = []byte(strings.Replace(string(payload), `"alice"`, `"mallory"`, 1))
+	return parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+}
+
+func unsignedToken(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "none"})
+	payload, _ := json.Marshal(claims)
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+}
+
+// TestOIDCUnknownKidFlood 大量带未知 kid 的伪造令牌不会触发对签发者的请求
+func TestOIDCUnknownKidFlood(t *testing.T) {
+	m := newMockIssuer(t)
+	v := m.verifier()
+	if _, err := v.Verify(context.Background(), m.sign(m.kid, m.claims())); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := v.Verify(context.Background(), m.sign("junk-"+strings.Repeat("x", i), m.claims())); !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("未知 kid 应返回 ErrInvalidCredentials: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if hits := m.jwksHits.Load(); hits != 1 {
+		t.Errorf("JWKS 请求了 %d 次, 期望 1 次", hits)
+	}
+}
+
+// TestOIDCConcurrentRefreshMerged 同时到达的请求共用一次 JWKS 获取
+func TestOIDCConcurrentRefreshMerged(t *testing.T) {
+	m := newMockIssuer(t)
+	v := m.verifier()
+	release := m.block()
+	token := m.sign(m.kid, m.claims())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := v.Verify(context.Background(), token)
+			errs <- err
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	release()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("校验失败: %v", err)
+		}
+	}
+	if hits := m.jwksHits.Load(); hits != 1 {
+		t.Errorf("JWKS 请求了 %d 次, 期望 1 次", hits)
+	}
+}
+
+// TestOIDCSlowIssuerDoesNotBlock 签发者响应缓慢时，使用已缓存公钥的请求不受影响，等待中的请求可以被取消
+func TestOIDCSlowIssuerDoesNotBlock(t *testing.T) {
+	m := newMockIssuer(t)
+	v := m.verifier()
+	token := m.sign(m.kid, m.claims())
+	if _, err := v.Verify(context.Background(), token); err != nil {
+		t.Fatal(err)
+	}
+
+	// 允许立即刷新，未知 kid 触发的获取被阻塞
+	v.mu.Lock()
+	v.attempted = time.Time{}
+	v.mu.Unlock()
+	release := m.block()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	waiting := make(chan error, 1)
+	go func() {
+		_, err := v.Verify(ctx, m.sign("rotated", m.claims()))
+		waiting <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := v.Verify(context.Background(), token)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("已缓存的公钥校验失败: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("获取 JWKS 时阻塞了使用缓存公钥的请求")
+	}
+
+	select {
+	case err := <-waiting:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("等待获取的请求应随 ctx 超时返回: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("等待获取的请求没有随 ctx 取消返回")
+	}
+	release()
+}
+
+// TestOIDCIssuerDownCached 签发者不可用时，失败结果在最小刷新间隔内缓存，不会每个请求都重新获取
+func TestOIDCIssuerDownCached(t *testing.T) {
+	m := newMockIssuer(t)
+	m.fail.Store(true)
+	v := m.verifier()
+	token := m.sign(m.kid, m.claims())
+
+	_, err := v.Verify(context.Background(), token)
+	if err == nil || errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("首次获取失败应返回服务器错误: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := v.Verify(context.Background(), token); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("失败结果缓存期间应返回 ErrInvalidCredentials: %v", err)
+		}
+	}
+	if hits := m.jwksHits.Load(); hits != 1 {
+		t.Errorf("JWKS 请求了 %d 次, 期望 1 次", hits)
+	}
+
+	// 最小刷新间隔过后签发者恢复
+	m.fail.Store(false)
+	v.mu.Lock()
+	v.attempted = time.Time{}
+	v.mu.Unlock()
+	if _, err := v.Verify(context.Background(), token); err != nil {
+		t.Fatalf("签发者恢复后校验失败: %v", err)
+	}
+}
+
+func TestAuthenticateOIDCBearer(t *testing.T) {
+	m := newMockIssuer(t)
+	a, err := New(Config{OIDC: OIDCConfig{Issuer: m.server.URL, Audience: "chuan"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := m.sign(m.kid, m.claims())
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/rooms", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	if p, err := a.Authenticate(r); err != nil || p.Identity() != "oidc:alice" {
+		t.Fatalf("Authorization: Bearer 校验失败: %v %v", p, err)
+	}
+
+	// WebSocket/EventSource 通过 access_token 查询参数携带令牌
+	r = httptest.NewRequest(http.MethodGet, "/api/ws/webrtc?access_token="+token, nil)
+	if p, err := a.Authenticate(r); err != nil || p.Identity() != "oidc:alice" {
+		t.Fatalf("access_token 校验失败: %v %v", p, err)
+	}
+}
@@ -0,0 +1,81 @@
+// Package events 定义房间生命周期和传输事件，并在进程内分发给审计日志等订阅者
+package events
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// 事件类型
+const (
+	RoomCreated         = "room_created"
+	RoomExpired         = "room_expired"
+	ParticipantJoined   = "participant_joined"
+	ParticipantLeft     = "participant_left"
+	RelaySessionStarted = "relay_session_started"
+	RelaySessionEnded   = "relay_session_ended"
+	FileShared          = "file_shared"
+	AdminRoomClosed     = "admin_room_closed"
+	AdminRoomExtended   = "admin_room_extended"
+)
+
+// Types 所有事件类型，用于校验配置中的事件名
+var Types = []string{
+	RoomCreated, RoomExpired,
+	ParticipantJoined, ParticipantLeft,
+	RelaySessionStarted, RelaySessionEnded,
+	FileShared,
+	AdminRoomClosed, AdminRoomExtended,
+}
+
+// Known 判断事件类型是否存在
+func Known(eventType string) bool {
+	return slices.Contains(Types, eventType)
+}
+
+// Event 一条房间事件
+type Event struct {
+	Time       time.Time              `json:"time"`
+	Type       string                 `json:"type"`
+	Room       string                 `json:"room,omitempty"`
+	Identity   string                 `json:"identity,omitempty"` // 房间创建者或执行操作的身份
+	Role       string                 `json:"role,omitempty"`
+	ClientID   string                 `json:"client_id,omitempty"`
+	RemoteAddr string                 `json:"remote_addr,omitempty"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+}
+
+// Bus 进程内事件总线，订阅者在发布者的 goroutine 中同步调用，不应阻塞
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []func(Event)
+}
+
+// NewBus 创建事件总线
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe 注册订阅者
+func (b *Bus) Subscribe(fn func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, fn)
+}
+
+// Publish 发布事件，未设置时间时使用当前时间；b 为 nil 时忽略
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.RLock()
+	subscribers := b.subscribers
+	b.mu.RUnlock()
+	for _, fn := range subscribers {
+		fn(e)
+	}
+}
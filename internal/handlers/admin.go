@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"chuan/internal/accounting"
+	"chuan/internal/api"
+	"chuan/internal/i18n"
+	"chuan/internal/logging"
+	"chuan/internal/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminListRoomsHandler 列出所有活跃房间
+func (h *Handler) AdminListRoomsHandler(w http.ResponseWriter, r *http.Request) {
+	rooms := h.adminService.ListRooms()
+	api.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"count":   len(rooms),
+		"rooms":   rooms,
+	})
+}
+
+// AdminGetRoomHandler 获取单个房间详情
+func (h *Handler) AdminGetRoomHandler(w http.ResponseWriter, r *http.Request) {
+	room, ok := h.adminService.GetRoom(chi.URLParam(r, "code"))
+	if !ok {
+		api.WriteError(w, r, http.StatusNotFound, api.CodeRoomNotFound, nil)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"room":    room,
+	})
+}
+
+// AdminCloseRoomHandler 强制关闭房间，通知所有客户端后断开连接
+func (h *Handler) AdminCloseRoomHandler(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+	if !h.adminService.CloseRoom(code, api.ClientIP(r)) {
+		api.WriteError(w, r, http.StatusNotFound, api.CodeRoomNotFound, nil)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": i18n.T(api.Language(w, r), "admin.room_closed"),
+	})
+}
+
+// AdminExtendRoomHandler 延长房间有效期
+// 请求体: {"duration": "30m"} 在当前过期时间基础上延长，或 {"expires_at": "2024-01-01T12:00:00Z"} 直接指定过期时间
+func (h *Handler) AdminExtendRoomHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Duration  string    `json:"duration"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		api.WriteError(w, r, http.StatusBadRequest, api.CodeInvalidRequest, nil)
+		return
+	}
+
+	var duration time.Duration
+	if req.ExpiresAt.IsZero() {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			api.WriteError(w, r, http.StatusBadRequest, api.CodeInvalidDuration, nil)
+			return
+		}
+		duration = d
+	} else if !req.ExpiresAt.After(time.Now()) {
+		api.WriteError(w, r, http.StatusBadRequest, api.CodeInvalidExpiresAt, nil)
+		return
+	}
+
+	expiresAt, clamped, err := h.adminService.ExtendRoom(chi.URLParam(r, "code"), api.ClientIP(r), duration, req.ExpiresAt)
+	switch {
+	case errors.Is(err, services.ErrRoomNotFound):
+		api.WriteError(w, r, http.StatusNotFound, api.CodeRoomNotFound, nil)
+		return
+	case errors.Is(err, services.ErrRoomPersistent):
+		api.WriteError(w, r, http.StatusBadRequest, api.CodeRoomPersistent, nil)
+		return
+	case errors.Is(err, services.ErrRoomMaxLifetime):
+		api.WriteError(w, r, http.StatusBadRequest, api.CodeRoomMaxLifetime, nil)
+		return
+	}
+	// expires_at 为实际生效的过期时间，超过最长使用时间时被截断，clamped 为 true
+	api.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"success":    true,
+		"expires_at": expiresAt,
+		"clamped":    clamped,
+	})
+}
+
+// usagePeriod 读取 period 查询参数，默认为当月
+func usagePeriod(r *http.Request) string {
+	if period := r.URL.Query().Get("period"); period != "" {
+		return period
+	}
+	return accounting.MonthPeriod(time.Now())
+}
+
+// writeUsageError 统计周期格式错误时返回 400，读取用量数据库失败时返回 500
+func writeUsageError(w http.ResponseWriter, r *http.Request, period string, err error) {
+	if errors.Is(err, accounting.ErrInvalidPeriod) {
+		api.WriteError(w, r, http.StatusBadRequest, api.CodeInvalidPeriod, map[string]interface{}{"period": period})
+		return
+	}
+	logging.Errorf("读取用量失败: %v", err)
+	api.WriteError(w, r, http.StatusInternalServerError, api.CodeInternalError, nil)
+}
+
+// AdminListUsageHandler 列出指定周期内各身份的用量，?period=2006-01 或 2006-01-02，默认当月
+func (h *Handler) AdminListUsageHandler(w http.ResponseWriter, r *http.Request) {
+	period := usagePeriod(r)
+	records, err := h.adminService.ListUsage(period)
+	if err != nil {
+		writeUsageError(w, r, period, err)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"period":  period,
+		"quota":   h.adminService.Quota(),
+		"usage":   records,
+	})
+}
+
+// AdminExportUsageHandler 以 CSV 导出指定周期内各身份的用量
+func (h *Handler) AdminExportUsageHandler(w http.ResponseWriter, r *http.Request) {
+	period := usagePeriod(r)
+	records, err := h.adminService.ListUsage(period)
+	if err != nil {
+		writeUsageError(w, r, period, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="usage-%s.csv"`, period))
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"period", "identity", "rooms", "relay_bytes"})
+	for _, record := range records {
+		writer.Write([]string{
+			record.Period,
+			csvText(record.Identity),
+			strconv.FormatInt(record.Rooms, 10),
+			strconv.FormatInt(record.RelayBytes, 10),
+		})
+	}
+	writer.Flush()
+}
+
+// csvText 身份来自 API Key 名称、OIDC 声明等外部输入，以 = + - @ 开头（或以制表符、回车开头）时
+// 电子表格会把单元格当作公式执行，前面加 ' 使其按文本显示
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// AdminGetUsageHandler 返回单个身份当天和当月的用量及配额
+func (h *Handler) AdminGetUsageHandler(w http.ResponseWriter, r *http.Request) {
+	api.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"usage":   h.adminService.GetUsage(chi.URLParam(r, "identity")),
+	})
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"chuan/internal/api"
+	"chuan/internal/logging"
+)
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+var adminTemplates = template.Must(template.New("").Funcs(template.FuncMap{
+	"bytes":    formatBytes,
+	"duration": formatUptime,
+	"clock": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04:05")
+	},
+}).ParseFS(templateFiles, "templates/*.html"))
+
+// statsStreamInterval 管理面板 SSE 推送间隔
+const statsStreamInterval = 2 * time.Second
+
+// statsWriteTimeout 单次推送的写超时
+const statsWriteTimeout = 10 * time.Second
+
+// AdminDashboardHandler 渲染管理面板页面，后续数据由 AdminStatsStreamHandler 推送
+func (h *Handler) AdminDashboardHandler(w http.ResponseWriter, r *http.Request) {
+	renderAdminTemplate(w, http.StatusOK, "admin_dashboard.html", h.adminService.Stats())
+}
+
+// AdminLoginPageHandler 渲染管理面板登录页
+func (h *Handler) AdminLoginPageHandler(w http.ResponseWriter, r *http.Request) {
+	renderAdminTemplate(w, http.StatusOK, "admin_login.html", map[string]interface{}{
+		"Failed": r.URL.Query().Get("error") != "",
+	})
+}
+
+// AdminStatsHandler 返回服务运行概况快照
+func (h *Handler) AdminStatsHandler(w http.ResponseWriter, r *http.Request) {
+	api.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"stats":   h.adminService.Stats(),
+	})
+}
+
+// AdminStatsStreamHandler 以 SSE 定期推送服务运行概况，中继吞吐量由相邻两次快照的字节差计算
+func (h *Handler) AdminStatsStreamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(statsStreamInterval)
+	defer ticker.Stop()
+
+	previous := make(map[string]int64)
+	previousAt := time.Now()
+	for {
+		stats := h.adminService.Stats()
+		now := time.Now()
+		elapsed := now.Sub(previousAt).Seconds()
+		current := make(map[string]int64, len(stats.RelaySessions))
+		for i := range stats.RelaySessions {
+			session := &stats.RelaySessions[i]
+			current[session.Code] = session.Bytes
+			if last, ok := previous[session.Code]; ok && elapsed > 0 && session.Bytes >= last {
+				session.BytesPerSecond = float64(session.Bytes-last) / elapsed
+			}
+		}
+		previous, previousAt = current, now
+
+		data, err := json.Marshal(stats)
+		if err != nil {
+			logging.Errorf("编码管理面板数据失败: %v", err)
+			return
+		}
+		rc.SetWriteDeadline(time.Now().Add(statsWriteTimeout))
+		if _, err := fmt.Fprintf(w, "event: stats\ndata: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func renderAdminTemplate(w http.ResponseWriter, status int, name string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := adminTemplates.ExecuteTemplate(w, name, data); err != nil {
+		logging.Errorf("渲染管理面板失败: %v", err)
+	}
+}
+
+// formatBytes 将字节数格式化为易读的单位
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatUptime 将秒数格式化为 "1天 2小时 3分" 形式
+func formatUptime(seconds int64) string {
+	d, h, m := seconds/86400, seconds%86400/3600, seconds%3600/60
+	switch {
+	case d > 0:
+		return fmt.Sprintf("%d天 %d小时 %d分", d, h, m)
+	case h > 0:
+		return fmt.Sprintf("%d小时 %d分", h, m)
+	case m > 0:
+		return fmt.Sprintf("%d分 %d秒", m, seconds%60)
+	default:
+		return fmt.Sprintf("%d秒", seconds)
+	}
+}
@@ -0,0 +1,134 @@
+package handlers_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"chuan/internal/accounting"
+	"chuan/internal/api"
+	"chuan/internal/events"
+	"chuan/internal/handlers"
+	"chuan/internal/services"
+)
+
+// TestAdminExportUsageCSV 用量按周期导出为 CSV，与 /admin/api/usage 的排序一致
+func TestAdminExportUsageCSV(t *testing.T) {
+	ledger, err := accounting.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handlers.NewHandler(services.DefaultOptions(), ledger, events.NewBus())
+	defer h.Close()
+
+	now := time.Now()
+	ledger.AddRoom("alice", now)
+	ledger.AddRelayBytes("alice", 2048, now)
+	ledger.AddRoom(`bob,"the builder"`, now)
+	ledger.AddRoom(`bob,"the builder"`, now)
+	ledger.AddRoom("carol", now.AddDate(0, -1, 0)) // 上个月的用量不出现在当月
+	// 以公式字符开头的身份按文本导出
+	for _, identity := range []string{"=HYPERLINK(\"http://evil\")", "+1", "-1+2", "@SUM(A1)", "\tcmd"} {
+		ledger.AddRoom(identity, now)
+	}
+
+	rec := httptest.NewRecorder()
+	h.AdminExportUsageHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/api/usage.csv", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d: %s", rec.Code, rec.Body)
+	}
+	month := accounting.MonthPeriod(now)
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="usage-`+month+`.csv"` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("解析 CSV 失败: %v", err)
+	}
+	want := [][]string{
+		{"period", "identity", "rooms", "relay_bytes"},
+		{month, "alice", "1", "2048"},
+		{month, "'\tcmd", "1", "0"},
+		{month, "'+1", "1", "0"},
+		{month, "'-1+2", "1", "0"},
+		{month, "'=HYPERLINK(\"http://evil\")", "1", "0"},
+		{month, "'@SUM(A1)", "1", "0"},
+		{month, `bob,"the builder"`, "2", "0"},
+	}
+	if !slices.EqualFunc(rows, want, slices.Equal) {
+		t.Fatalf("CSV = %q, 期望 %q", rows, want)
+	}
+
+	// 按天导出
+	rec = httptest.NewRecorder()
+	day := accounting.DayPeriod(now)
+	h.AdminExportUsageHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/api/usage.csv?period="+day, nil))
+	rows, err = csv.NewReader(rec.Body).ReadAll()
+	if err != nil || len(rows) != len(want) || rows[1][0] != day {
+		t.Fatalf("按天导出 = %q, %v", rows, err)
+	}
+
+	// 周期格式错误
+	rec = httptest.NewRecorder()
+	h.AdminExportUsageHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/api/usage.csv?period=2026-13", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("无效周期应返回 400, 实际 %d", rec.Code)
+	}
+}
+
+// TestAdminErrors 管理接口的错误使用统一的错误格式，提示信息按请求的语言本地化
+func TestAdminErrors(t *testing.T) {
+	ledger, err := accounting.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handlers.NewHandler(services.DefaultOptions(), ledger, events.NewBus())
+	defer h.Close()
+
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		target  string
+		body    string
+		status  int
+		code    string
+	}{
+		{"房间不存在", h.AdminGetRoomHandler, http.MethodGet, "/admin/api/rooms/NONE01", "", http.StatusNotFound, api.CodeRoomNotFound},
+		{"关闭不存在的房间", h.AdminCloseRoomHandler, http.MethodDelete, "/admin/api/rooms/NONE01", "", http.StatusNotFound, api.CodeRoomNotFound},
+		{"请求体无效", h.AdminExtendRoomHandler, http.MethodPost, "/admin/api/rooms/NONE01/extend", "{", http.StatusBadRequest, api.CodeInvalidRequest},
+		{"duration 无效", h.AdminExtendRoomHandler, http.MethodPost, "/admin/api/rooms/NONE01/extend", `{"duration":"-5m"}`, http.StatusBadRequest, api.CodeInvalidDuration},
+		{"expires_at 已过去", h.AdminExtendRoomHandler, http.MethodPost, "/admin/api/rooms/NONE01/extend", `{"expires_at":"` + past + `"}`, http.StatusBadRequest, api.CodeInvalidExpiresAt},
+		{"延长不存在的房间", h.AdminExtendRoomHandler, http.MethodPost, "/admin/api/rooms/NONE01/extend", `{"duration":"5m"}`, http.StatusNotFound, api.CodeRoomNotFound},
+		{"统计周期无效", h.AdminListUsageHandler, http.MethodGet, "/admin/api/usage?period=2026-13", "", http.StatusBadRequest, api.CodeInvalidPeriod},
+		{"导出统计周期无效", h.AdminExportUsageHandler, http.MethodGet, "/admin/api/usage.csv?period=2026-13", "", http.StatusBadRequest, api.CodeInvalidPeriod},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, lang := range []string{"zh-CN", "en"} {
+				req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+				req.Header.Set("Accept-Language", lang)
+				rec := httptest.NewRecorder()
+				tt.handler(rec, req)
+
+				var body api.ErrorResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+					t.Fatalf("响应不是统一的错误格式: %s", rec.Body)
+				}
+				if rec.Code != tt.status || body.Error.Code != tt.code || body.Error.Message == "" {
+					t.Fatalf("%s: 状态码 %d, 错误 %+v, 期望 %d %s", lang, rec.Code, body.Error, tt.status, tt.code)
+				}
+				if got := rec.Header().Get("Content-Language"); got != lang {
+					t.Errorf("Content-Language = %q, 期望 %q", got, lang)
+				}
+			}
+		})
+	}
+}
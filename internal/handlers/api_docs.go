@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"chuan/internal/api"
+	"chuan/internal/openapi"
+)
+
+// apiDocs 接口文档只依赖代码中的定义，首次请求时生成并缓存
+type apiDocs struct {
+	once     sync.Once
+	openAPI  []byte
+	asyncAPI []byte
+}
+
+func (h *Handler) loadDocs() *apiDocs {
+	h.docs.once.Do(func() {
+		h.docs.openAPI, _ = json.MarshalIndent(openapi.Generate(h.Operations()), "", "  ")
+		h.docs.asyncAPI, _ = json.MarshalIndent(openapi.GenerateAsync(), "", "  ")
+	})
+	return &h.docs
+}
+
+// OpenAPIHandler GET /api/openapi.json 返回 v1 REST 接口的 OpenAPI 3 文档
+// 部署在子路径下时文档中的服务器地址带有路径前缀，按请求生成
+func (h *Handler) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	prefix := api.BasePath(r)
+	if prefix == "" {
+		writeDoc(w, h.loadDocs().openAPI)
+		return
+	}
+	doc := openapi.Generate(h.Operations())
+	doc["servers"] = []interface{}{map[string]interface{}{"url": prefix + api.Prefix}}
+	data, _ := json.MarshalIndent(doc, "", "  ")
+	writeDoc(w, data)
+}
+
+// AsyncAPIHandler GET /api/asyncapi.json 返回 WebSocket 消息格式的 AsyncAPI 文档
+func (h *Handler) AsyncAPIHandler(w http.ResponseWriter, r *http.Request) {
+	writeDoc(w, h.loadDocs().asyncAPI)
+}
+
+func writeDoc(w http.ResponseWriter, doc []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*") // 供在线文档工具直接加载
+	w.Write(doc)
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"chuan/internal/accounting"
+	"chuan/internal/api"
+	"chuan/internal/auth"
+	"chuan/internal/logging"
+	"chuan/internal/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxCreateRoomBody 创建房间请求体的最大尺寸
+const maxCreateRoomBody = 4 << 10
+
+// Operations 返回所有 v1 接口，路由注册和 OpenAPI 文档都由此生成
+func (h *Handler) Operations() []api.Operation {
+	codeParam := api.Param{Name: "code", In: "path", Description: "房间码", Required: true}
+	langParam := api.Param{Name: "lang", In: "query", Description: "错误信息的语言（zh-CN 或 en），优先于 Accept-Language"}
+	return []api.Operation{
+		{
+			ID:          "createRoom",
+			Method:      http.MethodPost,
+			Path:        "/rooms",
+			Summary:     "创建房间",
+			Description: "请求体可以为空；指定 code 时使用该房间码，指定 ttl（秒）时使用该有效期。是否需要身份验证由 auth.create_room 配置决定。",
+			Tags:        []string{"rooms"},
+			Auth:        api.AuthCreate,
+			Params:      []api.Param{langParam},
+			Request:     api.CreateRoomRequest{},
+			Responses: map[int]interface{}{
+				http.StatusCreated:         api.Room{},
+				http.StatusBadRequest:      api.ErrorResponse{},
+				http.StatusUnauthorized:    api.ErrorResponse{},
+				http.StatusForbidden:       api.ErrorResponse{},
+				http.StatusConflict:        api.ErrorResponse{},
+				http.StatusTooManyRequests: api.ErrorResponse{},
+			},
+			Handler: h.CreateRoomV1Handler,
+		},
+		{
+			ID:      "getRoom",
+			Method:  http.MethodGet,
+			Path:    "/rooms/{code}",
+			Summary: "查询房间状态",
+			Tags:    []string{"rooms"},
+			Params:  []api.Param{codeParam, langParam},
+			Responses: map[int]interface{}{
+				http.StatusOK:       api.Room{},
+				http.StatusNotFound: api.ErrorResponse{},
+			},
+			Handler: h.GetRoomV1Handler,
+		},
+		{
+			ID:          "streamRoomEvents",
+			Method:      http.MethodGet,
+			Path:        "/rooms/{code}/events",
+			Summary:     "订阅房间状态变化",
+			Description: "以 SSE 推送房间状态变化，事件名为 type，id 单调递增。携带 Last-Event-ID 请求头或 last_event_id 参数时只补发之后的事件。请求 WebSocket 升级并携带 " + services.RoomEventsSubprotocol + " 子协议时改用 WebSocket（见 AsyncAPI 文档）。房间已结束且没有需要补发的事件时返回 204。",
+			Tags:        []string{"rooms"},
+			Params: []api.Param{
+				codeParam,
+				{Name: "Last-Event-ID", In: "header", Description: "最后收到的事件 ID"},
+				{Name: "last_event_id", In: "query", Description: "同 Last-Event-ID，供无法设置请求头的客户端使用"},
+				langParam,
+			},
+			Responses: map[int]interface{}{
+				http.StatusNoContent:  nil,
+				http.StatusBadRequest: api.ErrorResponse{},
+				http.StatusNotFound:   api.ErrorResponse{},
+			},
+			EventStream: services.RoomEvent{},
+			Handler:     h.HandleRoomEvents,
+		},
+	}
+}
+
+// CreateRoomV1Handler POST /api/v1/rooms 创建房间，成功时返回 201 和房间状态
+func (h *Handler) CreateRoomV1Handler(w http.ResponseWriter, r *http.Request) {
+	// 请求体可以为空；不为空时必须是合法的 JSON
+	var req api.CreateRoomRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCreateRoomBody)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		api.WriteError(w, r, http.StatusBadRequest, api.CodeInvalidRequest, nil)
+		return
+	}
+
+	var owner string
+	if principal := auth.PrincipalFrom(r.Context()); principal != nil {
+		owner = principal.Identity()
+	}
+	code, err := h.webrtcService.CreateNewRoom(owner, api.ClientIP(r), req.Code, time.Duration(req.TTL)*time.Second)
+	if err != nil {
+		logging.Warnf("创建房间被拒绝: %s: %v", owner, err)
+		h.writeCreateRoomError(w, r, err)
+		return
+	}
+	logging.Infof("创建房间成功: %s (创建者: %s)", code, owner)
+
+	status, ok := h.webrtcService.RoomStatus(code)
+	if !ok {
+		api.WriteError(w, r, http.StatusInternalServerError, api.CodeInternalError, nil)
+		return
+	}
+	w.Header().Set("Location", api.BasePath(r)+api.Prefix+"/rooms/"+code)
+	api.WriteJSON(w, http.StatusCreated, roomResponse(status))
+}
+
+// writeCreateRoomError 把创建房间的错误转换为对应的状态码和错误码
+func (h *Handler) writeCreateRoomError(w http.ResponseWriter, r *http.Request, err error) {
+	status, code := createRoomError(err)
+	var details map[string]interface{}
+	if code == api.CodeInvalidTTL {
+		minTTL, maxTTL := h.webrtcService.TTLRange()
+		details = map[string]interface{}{
+			"min_ttl": int64(minTTL.Seconds()),
+			"max_ttl": int64(maxTTL.Seconds()),
+		}
+	}
+	api.WriteError(w, r, status, code, details)
+}
+
+// createRoomError 返回创建房间失败时的状态码和错误码，v1 接口和旧版接口共用
+func createRoomError(err error) (int, string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidCode):
+		return http.StatusBadRequest, api.CodeInvalidRoomCode
+	case errors.Is(err, services.ErrInvalidTTL):
+		return http.StatusBadRequest, api.CodeInvalidTTL
+	case errors.Is(err, services.ErrCodeReserved):
+		return http.StatusForbidden, api.CodeRoomCodeReserved
+	case errors.Is(err, services.ErrCodeTaken):
+		return http.StatusConflict, api.CodeRoomCodeTaken
+	case errors.Is(err, accounting.ErrRoomQuota):
+		return http.StatusTooManyRequests, api.CodeQuotaExceeded
+	}
+	return http.StatusInternalServerError, api.CodeInternalError
+}
+
+// GetRoomV1Handler GET /api/v1/rooms/{code} 查询房间状态，房间不存在或已过期时返回 404
+func (h *Handler) GetRoomV1Handler(w http.ResponseWriter, r *http.Request) {
+	code := services.NormalizePickupCode(chi.URLParam(r, "code"))
+	status, ok := h.webrtcService.RoomStatus(code)
+	if !ok {
+		api.WriteError(w, r, http.StatusNotFound, api.CodeRoomNotFound, nil)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, roomResponse(status))
+}
+
+// APINotFoundHandler v1 接口中不存在的路径
+func (h *Handler) APINotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	api.WriteError(w, r, http.StatusNotFound, api.CodeNotFound, nil)
+}
+
+// APIMethodNotAllowedHandler v1 接口中不支持的请求方法，Allow 头列出该路径支持的方法
+func (h *Handler) APIMethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.Routes != nil {
+		for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+			if rctx.Routes.Match(chi.NewRouteContext(), method, r.URL.Path) {
+				w.Header().Add("Allow", method)
+			}
+		}
+	}
+	api.WriteError(w, r, http.StatusMethodNotAllowed, api.CodeMethodNotAllowed, nil)
+}
+
+func roomResponse(status services.RoomStatus) api.Room {
+	return api.Room{
+		Code:           status.Code,
+		SenderOnline:   status.SenderOnline,
+		ReceiverOnline: status.ReceiverOnline,
+		Full:           status.Full(),
+		Persistent:     status.Persistent,
+		CreatedAt:      status.CreatedAt,
+		ExpiresAt:      status.ExpiresAt,
+		LastSeen:       status.LastSeen,
+	}
+}
@@ -0,0 +1,485 @@
+package handlers_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"chuan/internal/accounting"
+	"chuan/internal/api"
+	"chuan/internal/auth"
+	"chuan/internal/events"
+	"chuan/internal/handlers"
+	"chuan/internal/openapi"
+	"chuan/internal/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// 契约测试：向真实的 handlers.Handler 发送请求，按 /api/openapi.json 中声明的 schema 校验响应，
+// 保证接口文档与实现一致
+
+// contract 测试服务器和从中读取的 OpenAPI 文档
+type contract struct {
+	t      *testing.T
+	server *httptest.Server
+	doc    map[string]interface{}
+	seen   map[string][]int // operationId → 已验证的状态码
+}
+
+func newContract(t *testing.T) *contract {
+	t.Helper()
+
+	opts := services.DefaultOptions()
+	opts.ReservedCodes = []services.ReservedCode{{Code: "QA2K24", Owner: "api_key:qa"}}
+	opts.Quota = accounting.Quota{DailyRooms: 1}
+
+	ledger, err := accounting.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handlers.NewHandler(opts, ledger, events.NewBus())
+	t.Cleanup(func() { h.Close() })
+
+	// 与 cmd/router.go 相同的方式注册 v1 接口；身份验证由测试请求头模拟
+	r := chi.NewRouter()
+	r.Route(api.Prefix, func(r chi.Router) {
+		r.Use(api.Versioned)
+		r.NotFound(h.APINotFoundHandler)
+		r.MethodNotAllowed(h.APIMethodNotAllowedHandler)
+		for _, op := range h.Operations() {
+			route := r
+			if op.Auth == api.AuthCreate {
+				route = r.With(testIdentity)
+			}
+			route.Method(op.Method, op.Path, op.Handler)
+		}
+	})
+	r.Get("/api/openapi.json", h.OpenAPIHandler)
+	r.Get("/api/asyncapi.json", h.AsyncAPIHandler)
+	r.Delete("/admin/api/rooms/{code}", h.AdminCloseRoomHandler)
+
+	c := &contract{t: t, server: httptest.NewServer(r), seen: make(map[string][]int)}
+	t.Cleanup(c.server.Close)
+
+	resp := c.do(http.MethodGet, "/api/openapi.json", nil, nil)
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&c.doc); err != nil {
+		t.Fatalf("解析 OpenAPI 文档失败: %v", err)
+	}
+	return c
+}
+
+// testIdentity 把 X-Test-Identity 请求头作为已通过验证的 API Key 身份
+func testIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if name := r.Header.Get("X-Test-Identity"); name != "" {
+			principal := &auth.Principal{Subject: name, Method: "api_key"}
+			r = r.WithContext(auth.WithPrincipal(r.Context(), principal))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *contract) do(method, path string, body io.Reader, header http.Header) *http.Response {
+	c.t.Helper()
+	req, err := http.NewRequest(method, c.server.URL+path, body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return resp
+}
+
+// operation 返回文档中路径模板和方法对应的接口定义
+func (c *contract) operation(method, template string) map[string]interface{} {
+	c.t.Helper()
+	paths, _ := c.doc["paths"].(map[string]interface{})
+	item, _ := paths[template].(map[string]interface{})
+	op, _ := item[strings.ToLower(method)].(map[string]interface{})
+	if op == nil {
+		c.t.Fatalf("OpenAPI 文档缺少 %s %s", method, template)
+	}
+	return op
+}
+
+// call 发送请求并按文档校验状态码和响应体，返回解码后的响应体
+func (c *contract) call(method, template, path, body string, header http.Header, want int) interface{} {
+	c.t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	resp := c.do(method, api.Prefix+path, reader, header)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != want {
+		data, _ := io.ReadAll(resp.Body)
+		c.t.Fatalf("%s %s: 状态码 %d，期望 %d: %s", method, path, resp.StatusCode, want, data)
+	}
+
+	op := c.operation(method, template)
+	c.seen[op["operationId"].(string)] = append(c.seen[op["operationId"].(string)], want)
+	responses, _ := op["responses"].(map[string]interface{})
+	declared, _ := responses[strconv.Itoa(want)].(map[string]interface{})
+	if declared == nil {
+		c.t.Fatalf("%s %s: 状态码 %d 未在文档中声明", method, template, want)
+	}
+
+	content, _ := declared["content"].(map[string]interface{})
+	if content == nil {
+		if data, _ := io.ReadAll(resp.Body); len(data) > 0 {
+			c.t.Fatalf("%s %s: 文档声明 %d 没有响应体，实际返回 %s", method, template, want, data)
+		}
+		return nil
+	}
+	media, _ := content["application/json"].(map[string]interface{})
+	if media == nil {
+		c.t.Fatalf("%s %s: 文档中 %d 不是 JSON 响应", method, template, want)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		c.t.Fatalf("%s %s: Content-Type 为 %q", method, path, ct)
+	}
+
+	var value interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&value); err != nil {
+		c.t.Fatalf("%s %s: 响应不是合法的 JSON: %v", method, path, err)
+	}
+	if err := validate(c.doc, media["schema"].(map[string]interface{}), value, "$"); err != nil {
+		c.t.Fatalf("%s %s: 响应与文档不符: %v", method, path, err)
+	}
+	return value
+}
+
+func TestContractRooms(t *testing.T) {
+	c := newContract(t)
+	const create, get = "/rooms", "/rooms/{code}"
+
+	room := c.call(http.MethodPost, create, "/rooms", "", nil, http.StatusCreated).(map[string]interface{})
+	code := room["code"].(string)
+	c.call(http.MethodPost, create, "/rooms", `{"code":"ABC234","ttl":600}`, nil, http.StatusCreated)
+	c.call(http.MethodPost, create, "/rooms", `{"code":"ABC234"}`, nil, http.StatusConflict)
+	c.call(http.MethodPost, create, "/rooms", `{"ttl":1}`, nil, http.StatusBadRequest)
+	c.call(http.MethodPost, create, "/rooms", `{"code":"0O"}`, nil, http.StatusBadRequest)
+	c.call(http.MethodPost, create, "/rooms", `{bad`, nil, http.StatusBadRequest)
+	c.call(http.MethodPost, create, "/rooms", `{"code":"QA2K24"}`, nil, http.StatusForbidden)
+
+	// 每日配额为 1：同一身份第二次创建返回 429
+	identity := http.Header{"X-Test-Identity": {"ci"}}
+	c.call(http.MethodPost, create, "/rooms", "", identity, http.StatusCreated)
+	c.call(http.MethodPost, create, "/rooms", "", identity, http.StatusTooManyRequests)
+
+	got := c.call(http.MethodGet, get, "/rooms/"+code, "", nil, http.StatusOK).(map[string]interface{})
+	if got["code"] != code {
+		t.Fatalf("查询到的房间码为 %v，期望 %s", got["code"], code)
+	}
+	c.call(http.MethodGet, get, "/rooms/"+strings.ToLower(code), "", nil, http.StatusOK)
+	c.call(http.MethodGet, get, "/rooms/ZZZZZZ", "", nil, http.StatusNotFound)
+
+	// 错误信息按 Accept-Language 本地化，错误码不变
+	en := c.call(http.MethodGet, get, "/rooms/ZZZZZZ", "", http.Header{"Accept-Language": {"en-US,en;q=0.9"}}, http.StatusNotFound)
+	if e := en.(map[string]interface{})["error"].(map[string]interface{}); e["code"] != api.CodeRoomNotFound || e["message"] != "The room does not exist or has expired" {
+		t.Fatalf("英文错误响应不符: %v", e)
+	}
+	// lang 参数优先于 Accept-Language
+	zh := c.call(http.MethodGet, get, "/rooms/ZZZZZZ?lang=zh-CN", "", http.Header{"Accept-Language": {"en"}}, http.StatusNotFound)
+	if e := zh.(map[string]interface{})["error"].(map[string]interface{}); e["message"] != "房间不存在或已过期" {
+		t.Fatalf("lang=zh-CN 时的错误响应不符: %v", e)
+	}
+
+	c.requireCovered("createRoom", http.StatusUnauthorized)
+	c.requireCovered("getRoom")
+}
+
+func TestContractRoomEvents(t *testing.T) {
+	c := newContract(t)
+	const events = "/rooms/{code}/events"
+
+	room := c.call(http.MethodPost, "/rooms", "/rooms", "", nil, http.StatusCreated).(map[string]interface{})
+	code := room["code"].(string)
+
+	c.call(http.MethodGet, events, "/rooms/ZZZZZZ/events", "", nil, http.StatusNotFound)
+	upgrade := http.Header{
+		"Connection":            {"Upgrade"},
+		"Upgrade":               {"websocket"},
+		"Sec-Websocket-Version": {"13"},
+		"Sec-Websocket-Key":     {"dGhlIHNhbXBsZSBub25jZQ=="},
+	}
+	c.call(http.MethodGet, events, "/rooms/"+code+"/events", "", upgrade, http.StatusBadRequest)
+
+	// 200：按文档中 text/event-stream 的 schema 校验每条事件的 data
+	first := c.readEvents(code, "", 1)
+	c.seen["streamRoomEvents"] = append(c.seen["streamRoomEvents"], http.StatusOK)
+	if first[0]["type"] != services.RoomEventCreated {
+		t.Fatalf("第一条事件为 %v，期望 created", first[0]["type"])
+	}
+
+	// 房间被关闭后推送 expired；带上最后一条事件 ID 重连时返回 204
+	resp := c.do(http.MethodDelete, "/admin/api/rooms/"+code, nil, nil)
+	resp.Body.Close()
+	all := c.readEvents(code, "", 2)
+	last := all[len(all)-1]
+	if last["type"] != services.RoomEventExpired {
+		t.Fatalf("最后一条事件为 %v，期望 expired", last["type"])
+	}
+	lastID := strconv.FormatFloat(last["id"].(float64), 'f', -1, 64)
+	c.call(http.MethodGet, events, "/rooms/"+code+"/events", "", http.Header{"Last-Event-ID": {lastID}}, http.StatusNoContent)
+
+	c.requireCovered("streamRoomEvents")
+}
+
+// readEvents 读取 n 条 SSE 事件并按文档校验
+func (c *contract) readEvents(code, lastID string, n int) []map[string]interface{} {
+	c.t.Helper()
+	header := http.Header{}
+	if lastID != "" {
+		header.Set("Last-Event-ID", lastID)
+	}
+	resp := c.do(http.MethodGet, api.Prefix+"/rooms/"+code+"/events", nil, header)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		c.t.Fatalf("事件流响应为 %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	op := c.operation(http.MethodGet, "/rooms/{code}/events")
+	media := op["responses"].(map[string]interface{})["200"].(map[string]interface{})["content"].(map[string]interface{})["text/event-stream"].(map[string]interface{})
+	schema := media["schema"].(map[string]interface{})
+
+	var result []map[string]interface{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(resp.Body)
+		var id, name string
+		for scanner.Scan() && len(result) < n {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				var value map[string]interface{}
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &value); err != nil {
+					c.t.Errorf("事件不是合法的 JSON: %v", err)
+					return
+				}
+				if err := validate(c.doc, schema, value, "$"); err != nil {
+					c.t.Errorf("事件与文档不符: %v", err)
+				}
+				if value["type"] != name || strconv.FormatFloat(value["id"].(float64), 'f', -1, 64) != id {
+					c.t.Errorf("SSE 的 id/event 字段与 data 不一致: id=%s event=%s data=%v", id, name, value)
+				}
+				result = append(result, value)
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("等待 %d 条房间事件超时，已收到 %d 条", n, len(result))
+	}
+	if len(result) < n {
+		c.t.Fatalf("只收到 %d 条房间事件，期望 %d 条", len(result), n)
+	}
+	return result
+}
+
+// requireCovered 检查文档中声明的每个状态码都经过了验证，skip 为测试中无法构造的状态码
+func (c *contract) requireCovered(operationID string, skip ...int) {
+	c.t.Helper()
+	paths := c.doc["paths"].(map[string]interface{})
+	for _, item := range paths {
+		for _, raw := range item.(map[string]interface{}) {
+			op := raw.(map[string]interface{})
+			if op["operationId"] != operationID {
+				continue
+			}
+			for status := range op["responses"].(map[string]interface{}) {
+				code, _ := strconv.Atoi(status)
+				if !slices.Contains(c.seen[operationID], code) && !slices.Contains(skip, code) {
+					c.t.Errorf("%s 的 %s 响应没有被契约测试覆盖", operationID, status)
+				}
+			}
+			return
+		}
+	}
+	c.t.Fatalf("OpenAPI 文档缺少 %s", operationID)
+}
+
+func TestContractDocsCoverAllOperations(t *testing.T) {
+	c := newContract(t)
+
+	ledger, _ := accounting.Open("")
+	h := handlers.NewHandler(services.DefaultOptions(), ledger, nil)
+	defer h.Close()
+	for _, op := range h.Operations() {
+		c.operation(op.Method, op.Path)
+	}
+
+	resp := c.do(http.MethodGet, "/api/asyncapi.json", nil, nil)
+	defer resp.Body.Close()
+	var async map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&async); err != nil {
+		t.Fatalf("解析 AsyncAPI 文档失败: %v", err)
+	}
+	for _, channel := range []string{"/api/ws/webrtc", "/api/ws/relay"} {
+		if async["channels"].(map[string]interface{})[channel] == nil {
+			t.Errorf("AsyncAPI 文档缺少 %s", channel)
+		}
+	}
+
+	// WebSocket 控制消息的实际编码结果符合 AsyncAPI 中的 schema
+	messages := async["components"].(map[string]interface{})["messages"].(map[string]interface{})
+	samples := map[string]interface{}{
+		"relayReady":    services.RelayReady{Type: "relay-ready", Role: "sender", PeerConnected: true},
+		"relayError":    services.RelayError{Type: "error", Code: api.CodeRelayQuotaExceeded, Error: "已超出中继流量配额", Reason: "quota_exceeded"},
+		"signalError":   services.SignalError{Type: "error", Code: api.CodeRoomNotFound, Message: "房间不存在或已过期"},
+		"peerJoined":    services.WebRTCMessage{Type: "peer-joined", From: "c1", Payload: services.PeerJoinedPayload{Role: "receiver"}},
+		"disconnection": services.WebRTCMessage{Type: "disconnection", Payload: services.DisconnectionPayload{Role: "sender", Reason: "closed", Message: "对方已停止传输"}},
+	}
+	for name, sample := range samples {
+		message, _ := messages[name].(map[string]interface{})
+		if message == nil {
+			t.Errorf("AsyncAPI 文档缺少消息 %s", name)
+			continue
+		}
+		data, _ := json.Marshal(sample)
+		var value interface{}
+		json.Unmarshal(data, &value)
+		if err := validate(async, message["payload"].(map[string]interface{}), value, "$"); err != nil {
+			t.Errorf("消息 %s 与文档不符: %v", name, err)
+		}
+	}
+
+	// 文档中的 schema 引用都能解析
+	var walk func(node interface{})
+	walk = func(node interface{}) {
+		switch v := node.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok && openapi.Resolve(c.doc, v) == nil && openapi.Resolve(async, v) == nil {
+				t.Errorf("无法解析的引用 %s", ref)
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(c.doc)
+	walk(async)
+}
+
+// validate 按 OpenAPI 3.0 schema 的子集（type、format、enum、nullable、properties、required、
+// additionalProperties、items、allOf、$ref）校验 JSON 值
+func validate(doc map[string]interface{}, schema map[string]interface{}, value interface{}, path string) error {
+	if _, ok := schema["$ref"]; ok {
+		resolved := openapi.Resolve(doc, schema)
+		if resolved == nil {
+			return fmt.Errorf("%s: 无法解析引用 %v", path, schema["$ref"])
+		}
+		return validate(doc, resolved, value, path)
+	}
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			if err := validate(doc, sub.(map[string]interface{}), value, path); err != nil {
+				return err
+			}
+		}
+	}
+	if value == nil {
+		if nullable, _ := schema["nullable"].(bool); nullable || schema["type"] == nil {
+			return nil
+		}
+		return fmt.Errorf("%s: 不能为 null", path)
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && !slices.Contains(enum, value) {
+		return fmt.Errorf("%s: %v 不在 %v 中", path, value, enum)
+	}
+
+	switch schema["type"] {
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: 期望字符串，实际为 %T", path, value)
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+				return fmt.Errorf("%s: 不是 RFC 3339 时间: %q", path, s)
+			}
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s: 期望整数，实际为 %v", path, value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: 期望数字，实际为 %T", path, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: 期望布尔值，实际为 %T", path, value)
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: 期望数组，实际为 %T", path, value)
+		}
+		if itemSchema, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range items {
+				if err := validate(doc, itemSchema, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: 期望对象，实际为 %T", path, value)
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				return fmt.Errorf("%s: 缺少必填字段 %s", path, name)
+			}
+		}
+		for name, field := range obj {
+			if propSchema, ok := properties[name].(map[string]interface{}); ok {
+				if err := validate(doc, propSchema, field, path+"."+name); err != nil {
+					return err
+				}
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					return fmt.Errorf("%s: 文档中没有字段 %s", path, name)
+				}
+			case map[string]interface{}:
+				if err := validate(doc, extra, field, path+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
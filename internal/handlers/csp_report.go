@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+
+	"chuan/internal/api"
+	"chuan/internal/logging"
+)
+
+const (
+	cspReportMaxBytes = 64 << 10 // 单个报告请求体上限
+	cspReportLogLimit = 60       // 每分钟最多记录的违规数，防止大量报告刷屏
+	cspReportMaxField = 256      // 日志中单个字段的最大长度
+)
+
+// cspViolation 一条 CSP 违规，由两种报告格式统一转换而来
+type cspViolation struct {
+	Document    string
+	Directive   string
+	Blocked     string
+	Source      string
+	Line        int
+	Disposition string // enforce | report
+	Sample      string
+}
+
+// cspReportLog 按分钟限制违规日志的条数，超出的只计数
+type cspReportLog struct {
+	mu      sync.Mutex
+	window  time.Time
+	logged  int
+	dropped int
+}
+
+// CSPReportHandler 接收浏览器上报的 CSP 违规报告并写入日志
+// 支持 report-uri 的 application/csp-report 格式和 Reporting API (report-to) 的 application/reports+json 格式
+func (h *Handler) CSPReportHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, cspReportMaxBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			api.WriteLegacyError(w, r, http.StatusRequestEntityTooLarge, api.CodeInvalidParams)
+			return
+		}
+		api.WriteLegacyError(w, r, http.StatusBadRequest, api.CodeInvalidParams)
+		return
+	}
+
+	violations, err := parseCSPReport(r.Header.Get("Content-Type"), body)
+	if err != nil {
+		api.WriteLegacyError(w, r, http.StatusBadRequest, api.CodeInvalidParams)
+		return
+	}
+	for _, v := range violations {
+		h.cspReports.record(v)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseCSPReport 按 Content-Type 解析报告，Reporting API 的报告中只保留 csp-violation 类型
+func parseCSPReport(contentType string, body []byte) ([]cspViolation, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/reports+json" {
+		var reports []struct {
+			Type string `json:"type"`
+			Body struct {
+				DocumentURL        string `json:"documentURL"`
+				EffectiveDirective string `json:"effectiveDirective"`
+				BlockedURL         string `json:"blockedURL"`
+				SourceFile         string `json:"sourceFile"`
+				LineNumber         int    `json:"lineNumber"`
+				Disposition        string `json:"disposition"`
+				Sample             string `json:"sample"`
+			} `json:"body"`
+		}
+		if err := json.Unmarshal(body, &reports); err != nil {
+			return nil, err
+		}
+		var violations []cspViolation
+		for _, report := range reports {
+			if report.Type != "csp-violation" {
+				continue
+			}
+			b := report.Body
+			violations = append(violations, cspViolation{
+				Document:    b.DocumentURL,
+				Directive:   b.EffectiveDirective,
+				Blocked:     b.BlockedURL,
+				Source:      b.SourceFile,
+				Line:        b.LineNumber,
+				Disposition: b.Disposition,
+				Sample:      b.Sample,
+			})
+		}
+		return violations, nil
+	}
+
+	// application/csp-report，部分浏览器使用 application/json
+	var report struct {
+		Report *struct {
+			DocumentURI        string `json:"document-uri"`
+			ViolatedDirective  string `json:"violated-directive"`
+			EffectiveDirective string `json:"effective-directive"`
+			BlockedURI         string `json:"blocked-uri"`
+			SourceFile         string `json:"source-file"`
+			LineNumber         int    `json:"line-number"`
+			Disposition        string `json:"disposition"`
+			ScriptSample       string `json:"script-sample"`
+		} `json:"csp-report"`
+	}
+	if err := json.Unmarshal(body, &report); err != nil {
+		return nil, err
+	}
+	if report.Report == nil {
+		return nil, errors.New("missing csp-report")
+	}
+	b := report.Report
+	directive := b.EffectiveDirective
+	if directive == "" {
+		directive = b.ViolatedDirective
+	}
+	return []cspViolation{{
+		Document:    b.DocumentURI,
+		Directive:   directive,
+		Blocked:     b.BlockedURI,
+		Source:      b.SourceFile,
+		Line:        b.LineNumber,
+		Disposition: b.Disposition,
+		Sample:      b.ScriptSample,
+	}}, nil
+}
+
+// record 写入一条违规日志，本分钟已达上限时只计数，下一分钟开始时输出省略的条数
+func (l *cspReportLog) record(v cspViolation) {
+	l.mu.Lock()
+	now := time.Now()
+	if now.Sub(l.window) >= time.Minute {
+		if l.dropped > 0 {
+			logging.Warnf("🚨 CSP 违规报告过多，上一分钟省略了 %d 条", l.dropped)
+		}
+		l.window, l.logged, l.dropped = now, 0, 0
+	}
+	if l.logged >= cspReportLogLimit {
+		l.dropped++
+		l.mu.Unlock()
+		return
+	}
+	l.logged++
+	l.mu.Unlock()
+
+	logging.Warnf("🚨 CSP 违规 (%s): %s 阻止了 %s, 页面=%s, 位置=%s:%d, 片段=%q",
+		reportField(v.Disposition), reportField(v.Directive), reportField(v.Blocked), reportField(v.Document),
+		reportField(v.Source), v.Line, reportField(v.Sample))
+}
+
+// reportField 截断报告中的字段，报告内容来自浏览器，不可信
+func reportField(s string) string {
+	if len(s) > cspReportMaxField {
+		return s[:cspReportMaxField] + "..."
+	}
+	return s
+}
@@ -2,57 +2,144 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"io"
 	"net/http"
-
+	"time"
+
+	"chuan/internal/accounting"
+	"chuan/internal/api"
+	"chuan/internal/auth"
+	"chuan/internal/events"
+	"chuan/internal/i18n"
+	"chuan/internal/logging"
 	"chuan/internal/services"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/quic-go/webtransport-go"
 )
 
 type Handler struct {
 	webrtcService *services.WebRTCService
 	relayService  *services.RelayService
+	adminService  *services.AdminService
+	ledger        *accounting.Ledger
+	closers       []io.Closer // 关闭时一并关闭的资源，如审计日志
+	docs          apiDocs     // OpenAPI/AsyncAPI 文档缓存
+	cspReports    cspReportLog
 }
 
-func NewHandler() *Handler {
-	webrtcService := services.NewWebRTCService()
+// NewHandler 创建处理器，房间事件发布到 bus
+func NewHandler(opts services.Options, ledger *accounting.Ledger, bus *events.Bus) *Handler {
+	webrtcService := services.NewWebRTCService(opts, ledger, bus)
+	relayService := services.NewRelayService(webrtcService, opts)
 	return &Handler{
 		webrtcService: webrtcService,
-		relayService:  services.NewRelayService(webrtcService),
+		relayService:  relayService,
+		adminService:  services.NewAdminService(webrtcService, relayService),
+		ledger:        ledger,
 	}
 }
 
+// CloseWith 注册在 Close 时一并关闭的资源
+func (h *Handler) CloseWith(c io.Closer) {
+	h.closers = append(h.closers, c)
+}
+
+// Close 保存尚未写入的用量统计，并关闭通过 CloseWith 注册的资源
+func (h *Handler) Close() error {
+	err := h.ledger.Close()
+	for _, c := range h.closers {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
+
+// UpdateOptions 在运行时替换服务参数（配置热重载），已建立的连接不受影响
+func (h *Handler) UpdateOptions(opts services.Options) {
+	h.webrtcService.UpdateOptions(opts)
+	h.relayService.UpdateOptions(opts)
+}
+
+// CheckOrigin 按当前来源策略校验升级请求（供 WebTransport 监听器使用）
+func (h *Handler) CheckOrigin(r *http.Request) bool {
+	return h.webrtcService.CheckWebTransportOrigin(r)
+}
+
 // HandleRelayWebSocket 处理数据中继WebSocket连接（P2P失败时的降级方案）
 func (h *Handler) HandleRelayWebSocket(w http.ResponseWriter, r *http.Request) {
 	h.relayService.HandleRelayWebSocket(w, r)
 }
 
+// HandleRelayWebTransport 返回处理 WebTransport 中继会话的处理器（挂载在 HTTP/3 监听器上）
+func (h *Handler) HandleRelayWebTransport(server *webtransport.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.relayService.HandleWebTransport(server, w, r)
+	}
+}
+
 // HandleWebRTCWebSocket 处理WebRTC信令WebSocket连接
 func (h *Handler) HandleWebRTCWebSocket(w http.ResponseWriter, r *http.Request) {
 	h.webrtcService.HandleWebSocket(w, r)
 }
 
+// HandleSignalEvents 处理SSE信令事件流（WebSocket不可用时的降级方案）
+func (h *Handler) HandleSignalEvents(w http.ResponseWriter, r *http.Request) {
+	h.webrtcService.HandleSSE(w, r, chi.URLParam(r, "code"))
+}
+
+// HandleSignalPost 处理SSE客户端提交的信令消息
+func (h *Handler) HandleSignalPost(w http.ResponseWriter, r *http.Request) {
+	h.webrtcService.HandleSignalPost(w, r, chi.URLParam(r, "code"))
+}
+
+// HandleRoomEvents 推送房间状态变化（SSE 或 WebSocket 子协议），代替轮询房间状态
+func (h *Handler) HandleRoomEvents(w http.ResponseWriter, r *http.Request) {
+	h.webrtcService.HandleRoomEvents(w, r, chi.URLParam(r, "code"))
+}
+
 // CreateRoomHandler 创建房间API - 简化版本，不处理无用参数
 func (h *Handler) CreateRoomHandler(w http.ResponseWriter, r *http.Request) {
 	// 设置响应为JSON格式
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodPost {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"message": "方法不允许",
-		})
+		api.WriteLegacyError(w, r, http.StatusMethodNotAllowed, api.CodeMethodNotAllowed)
 		return
 	}
 
-	// 创建新房间（忽略请求体中的无用参数）
-	code := h.webrtcService.CreateNewRoom()
-	log.Printf("创建房间成功: %s", code)
+	// 请求体中可选的 code 指定房间码、ttl 指定有效期（秒），其他无用参数忽略（解析失败时按未指定处理）
+	var req struct {
+		Code string `json:"code"`
+		TTL  int64  `json:"ttl"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	// 创建新房间，房间和中继流量计入创建者的用量
+	var owner string
+	if principal := auth.PrincipalFrom(r.Context()); principal != nil {
+		owner = principal.Identity()
+	}
+	code, err := h.webrtcService.CreateNewRoom(owner, api.ClientIP(r), req.Code, time.Duration(req.TTL)*time.Second)
+	if err != nil {
+		logging.Warnf("创建房间被拒绝: %s: %v", owner, err)
+		status, errCode := createRoomError(err)
+		api.WriteLegacyError(w, r, status, errCode)
+		return
+	}
+	if owner != "" {
+		logging.Infof("创建房间成功: %s (创建者: %s)", code, owner)
+	} else {
+		logging.Infof("创建房间成功: %s", code)
+	}
 
 	// 构建响应
+	status, _ := h.webrtcService.RoomStatus(code)
 	response := map[string]interface{}{
-		"success": true,
-		"code":    code,
-		"message": "房间创建成功",
+		"success":    true,
+		"code":       code,
+		"expires_at": status.ExpiresAt,
+		"message":    i18n.T(api.Language(w, r), "room.created"),
 	}
 
 	json.NewEncoder(w).Encode(response)
@@ -64,52 +151,19 @@ func (h *Handler) WebRTCRoomStatusHandler(w http.ResponseWriter, r *http.Request
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodGet {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"message": "方法不允许",
-		})
+		api.WriteLegacyError(w, r, http.StatusMethodNotAllowed, api.CodeMethodNotAllowed)
 		return
 	}
 
-	// 从查询参数获取房间代码
+	// 从查询参数获取房间代码，缺少时与房间不存在一样返回 200 和 success: false
 	code := r.URL.Query().Get("code")
 	if code == "" {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"message": "缺少房间代码",
-		})
+		api.WriteLegacyError(w, r, http.StatusOK, api.CodeMissingRoomCode)
 		return
 	}
 
 	// 获取房间状态
-	status := h.webrtcService.GetRoomStatus(code)
-
-	json.NewEncoder(w).Encode(status)
-}
-
-// GetRoomStatusHandler 获取房间状态API
-func (h *Handler) GetRoomStatusHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+	status := h.webrtcService.GetRoomStatus(code, api.Language(w, r))
 
-	if r.Method != http.MethodGet {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"message": "方法不允许",
-		})
-		return
-	}
-
-	// 获取房间码
-	code := r.URL.Query().Get("code")
-	if code == "" {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"message": "房间码不能为空",
-		})
-		return
-	}
-
-	// 获取房间状态
-	status := h.webrtcService.GetRoomStatus(code)
 	json.NewEncoder(w).Encode(status)
 }
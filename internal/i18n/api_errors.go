@@ -0,0 +1,115 @@
+package i18n
+
+// 错误信息，键为 "error." 加 api 包中的错误码，REST 接口和 WebSocket error 消息共用
+func init() {
+	register(map[string]map[string]string{
+		"error.invalid_request": {
+			ZhCN: "请求格式无效",
+			En:   "The request body is malformed",
+		},
+		"error.invalid_room_code": {
+			ZhCN: "房间码无效",
+			En:   "The room code is invalid",
+		},
+		"error.invalid_ttl": {
+			ZhCN: "房间有效期超出允许范围",
+			En:   "The room TTL is outside the allowed range",
+		},
+		"error.room_code_reserved": {
+			ZhCN: "房间码已被保留",
+			En:   "The room code is reserved for another identity",
+		},
+		"error.room_code_taken": {
+			ZhCN: "房间码已被占用",
+			En:   "The room code is already in use",
+		},
+		"error.room_not_found": {
+			ZhCN: "房间不存在或已过期",
+			En:   "The room does not exist or has expired",
+		},
+		"error.quota_exceeded": {
+			ZhCN: "已超出配额",
+			En:   "Quota exceeded",
+		},
+		"error.unauthorized": {
+			ZhCN: "需要身份验证",
+			En:   "Authentication is required",
+		},
+		"error.invalid_credentials": {
+			ZhCN: "身份验证失败",
+			En:   "Authentication failed",
+		},
+		"error.not_found": {
+			ZhCN: "接口不存在",
+			En:   "The requested endpoint does not exist",
+		},
+		"error.method_not_allowed": {
+			ZhCN: "方法不允许",
+			En:   "Method not allowed",
+		},
+		"error.internal_error": {
+			ZhCN: "服务器内部错误",
+			En:   "Internal server error",
+		},
+		"error.invalid_params": {
+			ZhCN: "连接参数无效",
+			En:   "Invalid connection parameters",
+		},
+		"error.room_expired": {
+			ZhCN: "房间已过期",
+			En:   "The room has expired",
+		},
+		"error.room_full": {
+			ZhCN: "当前房间人数已满，正在传输中无法加入",
+			En:   "The room is full; a transfer is already in progress",
+		},
+		"error.relay_quota_exceeded": {
+			ZhCN: "已超出中继流量配额",
+			En:   "The relay traffic quota has been exceeded",
+		},
+		"error.relay_disabled": {
+			ZhCN: "服务器未启用数据中继",
+			En:   "Data relay is disabled on this server",
+		},
+		"error.relay_transport": {
+			ZhCN: "对方使用了不同的中继方式 (WebSocket/WebTransport)，双方需要使用相同的中继方式",
+			En:   "The peer uses a different relay transport (WebSocket/WebTransport); both sides must use the same one",
+		},
+		"error.missing_room_code": {
+			ZhCN: "缺少房间代码",
+			En:   "The room code is missing",
+		},
+		"error.invalid_signal_token": {
+			ZhCN: "客户端未连接或令牌无效",
+			En:   "The client is not connected or the token is invalid",
+		},
+		"error.invalid_message": {
+			ZhCN: "消息格式无效",
+			En:   "The message is malformed",
+		},
+		"error.admin_disabled": {
+			ZhCN: "管理接口未启用，请配置 admin.token",
+			En:   "The admin API is disabled; configure admin.token to enable it",
+		},
+		"error.invalid_duration": {
+			ZhCN: "需要提供有效的 duration（如 30m）或 expires_at",
+			En:   "A valid duration (such as 30m) or expires_at is required",
+		},
+		"error.invalid_expires_at": {
+			ZhCN: "expires_at 必须晚于当前时间",
+			En:   "expires_at must be in the future",
+		},
+		"error.invalid_period": {
+			ZhCN: "无效的统计周期，应为 2006-01-02 或 2006-01",
+			En:   "The period is invalid; use 2006-01-02 or 2006-01",
+		},
+		"error.room_persistent": {
+			ZhCN: "持久房间不会过期，不能修改有效期",
+			En:   "Persistent rooms never expire; their expiry cannot be changed",
+		},
+		"error.room_max_lifetime": {
+			ZhCN: "房间已达到最长使用时间，不能再延长",
+			En:   "The room has reached its maximum lifetime and cannot be extended",
+		},
+	})
+}
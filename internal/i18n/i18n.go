@@ -0,0 +1,94 @@
+// Package i18n 按客户端语言选择返回给用户的提示信息，目前支持简体中文和英文
+package i18n
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 支持的语言
+const (
+	ZhCN = "zh-CN"
+	En   = "en"
+)
+
+// Default 无法协商出支持的语言时使用的默认语言
+const Default = ZhCN
+
+// catalog 按消息键索引的各语言文本，缺少某个语言时回退到默认语言
+var catalog = map[string]map[string]string{}
+
+// register 注册一组消息，供各个消息文件在初始化时调用
+func register(messages map[string]map[string]string) {
+	for key, texts := range messages {
+		catalog[key] = texts
+	}
+}
+
+// T 返回指定语言的消息，args 非空时按 fmt 格式化；未注册的键原样返回
+func T(lang, key string, args ...interface{}) string {
+	texts, ok := catalog[key]
+	if !ok {
+		return key
+	}
+	text, ok := texts[lang]
+	if !ok {
+		text = texts[Default]
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+// Negotiate 选择请求使用的语言：优先使用 lang 查询参数（如 ?lang=en），其次按 Accept-Language 请求头协商
+// WebSocket 和 EventSource 无法自定义请求头，可通过 lang 参数指定语言
+func Negotiate(r *http.Request) string {
+	if lang := Match(r.URL.Query().Get("lang")); lang != "" {
+		return lang
+	}
+	return parseAcceptLanguage(r.Header.Get("Accept-Language"))
+}
+
+// Match 把语言标签（zh、zh-Hans-CN、en-US 等）归一为支持的语言，不支持时返回空字符串
+func Match(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	switch {
+	case tag == "zh" || strings.HasPrefix(tag, "zh-"):
+		return ZhCN
+	case tag == "en" || strings.HasPrefix(tag, "en-"):
+		return En
+	}
+	return ""
+}
+
+// parseAcceptLanguage 按权重选择第一个支持的语言，如 "en-US,en;q=0.9,zh-CN;q=0.8" 选择 en
+func parseAcceptLanguage(header string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if lang := Match(tag); lang != "" && q > 0 {
+			candidates = append(candidates, candidate{lang, q})
+		}
+	}
+	if len(candidates) == 0 {
+		return Default
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].lang
+}
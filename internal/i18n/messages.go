@@ -0,0 +1,33 @@
+package i18n
+
+// 发给客户端的其他提示信息：断开通知、旧版接口的成功提示等
+func init() {
+	register(map[string]map[string]string{
+		// disconnection 消息，键为 "disconnect." 加断开原因
+		"disconnect.peer_left": {
+			ZhCN: "对方已停止传输",
+			En:   "The other side has stopped the transfer",
+		},
+		"disconnect.closed_by_admin": {
+			ZhCN: "房间已被管理员关闭",
+			En:   "The room was closed by an administrator",
+		},
+		"disconnect.max_lifetime": {
+			ZhCN: "房间已达到最长使用时间",
+			En:   "The room has reached its maximum lifetime",
+		},
+
+		"room.created": {
+			ZhCN: "房间创建成功",
+			En:   "Room created",
+		},
+		"admin.room_closed": {
+			ZhCN: "房间已关闭",
+			En:   "Room closed",
+		},
+		"room_events.subprotocol_required": {
+			ZhCN: "WebSocket 订阅需要使用 %s 子协议",
+			En:   "WebSocket subscriptions must use the %s subprotocol",
+		},
+	})
+}
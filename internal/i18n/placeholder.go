@@ -0,0 +1,87 @@
+package i18n
+
+// 未构建前端时显示的占位页面
+func init() {
+	register(map[string]map[string]string{
+		"page.title": {
+			ZhCN: "文件传输服务",
+			En:   "File Transfer Service",
+		},
+		"page.not_built": {
+			ZhCN: "前端界面未构建，当前显示的是后端 API 服务。",
+			En:   "The web UI has not been built; this is the backend API service.",
+		},
+		"page.env_heading": {
+			ZhCN: "环境变量配置",
+			En:   "Environment variables",
+		},
+		"page.env_frontend_dir": {
+			ZhCN: "指定外部前端文件目录",
+			En:   "Serve the web UI from an external directory",
+		},
+		"page.env_port": {
+			ZhCN: "自定义服务端口 (默认: 8080)",
+			En:   "Server port (default: 8080)",
+		},
+		"page.example": {
+			ZhCN: "示例:",
+			En:   "Example:",
+		},
+		"page.api_heading": {
+			ZhCN: "可用的 API 接口",
+			En:   "Available API endpoints",
+		},
+		"page.api_create_room": {
+			ZhCN: "创建WebRTC房间",
+			En:   "Create a WebRTC room",
+		},
+		"page.api_room_info": {
+			ZhCN: "获取房间信息",
+			En:   "Get room information",
+		},
+		"page.api_room_events": {
+			ZhCN: "房间状态变化事件流",
+			En:   "Room status event stream",
+		},
+		"page.api_openapi": {
+			ZhCN: "OpenAPI 接口文档",
+			En:   "OpenAPI document",
+		},
+		"page.api_signal": {
+			ZhCN: "WebRTC 信令连接",
+			En:   "WebRTC signaling connection",
+		},
+		"page.build_heading": {
+			ZhCN: "构建前端",
+			En:   "Build the web UI",
+		},
+		"page.build_enter_dir": {
+			ZhCN: "进入前端目录",
+			En:   "Enter the frontend directory",
+		},
+		"page.build_install": {
+			ZhCN: "安装依赖",
+			En:   "Install dependencies",
+		},
+		"page.build_static": {
+			ZhCN: "构建静态文件",
+			En:   "Build the static files",
+		},
+		"page.build_embed": {
+			ZhCN: "方法1: 重新构建 Go 项目以嵌入前端文件",
+			En:   "Option 1: rebuild the Go binary to embed the web UI",
+		},
+		"page.build_external": {
+			ZhCN: "方法2: 使用外部前端目录",
+			En:   "Option 2: serve the web UI from an external directory",
+		},
+		"page.hint_label": {
+			ZhCN: "提示:",
+			En:   "Note:",
+		},
+		"page.hint": {
+			ZhCN: "构建完成后刷新页面即可看到完整的前端界面。",
+			En:   "Reload this page after the build to see the full web UI.",
+		},
+	})
+}
@@ -0,0 +1,123 @@
+// Package logging 提供可在运行时调整的日志级别和最近错误记录
+// 日志仍然通过标准库 log 输出；运行期间的日志按级别使用 Debugf/Infof/Warnf/Errorf，
+// 启动、关闭和配置重载等一次性的日志直接使用 log，不受级别影响
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Level 日志级别
+type Level int32
+
+const (
+	LevelDebug Level = iota // 输出逐条信令、逐个中继消息等详细日志
+	LevelInfo               // 默认级别：连接、房间等事件日志和 HTTP 访问日志
+	LevelWarn               // 只输出警告（连接被拒绝、心跳超时、队列已满、投递重试等）和错误
+	LevelError              // 只输出错误
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("Level(%d)", l)
+}
+
+// ParseLevel 解析日志级别名称
+func ParseLevel(name string) (Level, error) {
+	for level, n := range levelNames {
+		if strings.EqualFold(name, n) {
+			return level, nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("未知的日志级别: %q (可选 debug/info/warn/error)", name)
+}
+
+var current atomic.Int32
+
+func init() {
+	current.Store(int32(LevelInfo))
+}
+
+// SetLevel 设置当前日志级别，可在运行时调用
+func SetLevel(level Level) {
+	current.Store(int32(level))
+}
+
+// Enabled 判断指定级别的日志是否输出
+func Enabled(level Level) bool {
+	return level >= Level(current.Load())
+}
+
+// Debugf 输出调试日志
+func Debugf(format string, args ...interface{}) {
+	if Enabled(LevelDebug) {
+		log.Printf(format, args...)
+	}
+}
+
+// Infof 输出一般事件日志
+func Infof(format string, args ...interface{}) {
+	if Enabled(LevelInfo) {
+		log.Printf(format, args...)
+	}
+}
+
+// Warnf 输出警告日志
+func Warnf(format string, args ...interface{}) {
+	if Enabled(LevelWarn) {
+		log.Printf(format, args...)
+	}
+}
+
+// maxRecentErrors 保留的最近错误条数
+const maxRecentErrors = 50
+
+// ErrorEntry 一条错误日志
+type ErrorEntry struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+var (
+	recentMu     sync.Mutex
+	recentErrors []ErrorEntry
+)
+
+// Errorf 输出错误日志并记录到最近错误列表，供管理面板展示；错误日志在任何级别下都会输出
+func Errorf(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	log.Print(message)
+
+	recentMu.Lock()
+	defer recentMu.Unlock()
+	if len(recentErrors) >= maxRecentErrors {
+		recentErrors = recentErrors[1:]
+	}
+	recentErrors = append(recentErrors, ErrorEntry{Time: time.Now(), Message: message})
+}
+
+// RecentErrors 返回最近的错误日志，最新的在前
+func RecentErrors() []ErrorEntry {
+	recentMu.Lock()
+	defer recentMu.Unlock()
+
+	entries := make([]ErrorEntry, len(recentErrors))
+	for i, e := range recentErrors {
+		entries[len(recentErrors)-1-i] = e
+	}
+	return entries
+}
@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+// TestLevels 每个级别只输出不低于该级别的日志，错误日志始终输出
+func TestLevels(t *testing.T) {
+	var buf bytes.Buffer
+	output, flags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(output)
+		log.SetFlags(flags)
+		SetLevel(LevelInfo)
+	}()
+
+	tests := []struct {
+		level Level
+		want  string
+	}{
+		{LevelDebug, "debug,info,warn,error"},
+		{LevelInfo, "info,warn,error"},
+		{LevelWarn, "warn,error"},
+		{LevelError, "error"},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		SetLevel(tt.level)
+		Debugf("debug")
+		Infof("info")
+		Warnf("warn")
+		Errorf("error")
+		if got := strings.Join(strings.Fields(buf.String()), ","); got != tt.want {
+			t.Errorf("级别 %s 输出 %q, 期望 %q", tt.level, got, tt.want)
+		}
+	}
+}
+
+// TestParseLevel 级别名称不区分大小写，未知名称返回错误
+func TestParseLevel(t *testing.T) {
+	for _, name := range []string{"debug", "INFO", "Warn", "error"} {
+		level, err := ParseLevel(name)
+		if err != nil || !strings.EqualFold(level.String(), name) {
+			t.Errorf("ParseLevel(%q) = %v, %v", name, level, err)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("未知级别应返回错误")
+	}
+}
@@ -0,0 +1,162 @@
+package openapi
+
+import (
+	"reflect"
+	"slices"
+
+	"chuan/internal/services"
+)
+
+// wsMessage 一种 WebSocket 消息：type 字段的取值和消息结构体
+type wsMessage struct {
+	name    string
+	summary string
+	kind    string      // type 字段的取值，为空表示二进制帧
+	body    interface{} // 消息结构体的零值
+	payload interface{} // body 为 WebRTCMessage 时 payload 字段的类型，nil 表示由浏览器生成、服务端原样转发
+}
+
+// wsChannel 一个 WebSocket 端点
+type wsChannel struct {
+	path        string
+	description string
+	query       []string
+	publish     []wsMessage // 客户端 → 服务端
+	subscribe   []wsMessage // 服务端 → 客户端
+}
+
+func asyncChannels() []wsChannel {
+	signal := services.WebRTCMessage{}
+	relay := services.RelayMessage{}
+	binary := wsMessage{name: "binaryFrame", summary: "文件数据块，原样转发给对方"}
+	forwarded := []wsMessage{
+		{name: "offer", summary: "SDP offer，由发送方发出，转发给接收方", kind: "offer", body: signal},
+		{name: "answer", summary: "SDP answer，由接收方发出，转发给发送方", kind: "answer", body: signal},
+		{name: "iceCandidate", summary: "ICE 候选，双向转发", kind: "ice-candidate", body: signal},
+	}
+
+	return []wsChannel{
+		{
+			path:        "/api/ws/webrtc",
+			description: "WebRTC 信令。WebSocket 不可用时可改用 SSE（GET /api/signal/{code}/events）接收同样的消息，并通过 POST /api/signal/{code} 提交信令。",
+			query:       []string{"code", "role", "lang"},
+			publish: slices.Concat(forwarded, []wsMessage{
+				{name: "ping", summary: "应用层心跳", kind: "ping", body: signal},
+			}),
+			subscribe: slices.Concat(forwarded, []wsMessage{
+				{name: "signalError", summary: "无法加入房间，随后断开连接", kind: "error", body: services.SignalError{}},
+				{name: "connected", summary: "仅 SSE：连接建立，提交信令时需要携带 client_id 和 token", kind: "connected", body: signal, payload: services.ConnectedPayload{}},
+				{name: "peerJoined", summary: "对方已加入房间", kind: "peer-joined", body: signal, payload: services.PeerJoinedPayload{}},
+				{name: "disconnection", summary: "对方离开或房间被关闭", kind: "disconnection", body: signal, payload: services.DisconnectionPayload{}},
+				{name: "pong", summary: "ping 的响应", kind: "pong", body: signal},
+			}),
+		},
+		{
+			path:        "/api/ws/relay",
+			description: "P2P 连接失败时的数据中继。文本帧为 JSON 控制消息或转发给对方的消息，二进制帧为文件数据。",
+			query:       []string{"code", "role", "lang"},
+			publish: []wsMessage{
+				{name: "relayData", summary: "转发给对方的 JSON 消息", kind: "relay-data", body: relay},
+				{name: "relayPing", summary: "应用层心跳", kind: "relay-ping", body: relay},
+				binary,
+			},
+			subscribe: []wsMessage{
+				{name: "relayReady", summary: "已加入中继房间", kind: "relay-ready", body: services.RelayReady{}},
+				{name: "relayPeerJoined", summary: "对方已加入中继房间", kind: "relay-peer-joined", body: services.RelayPeerJoined{}},
+				{name: "relayPeerLeft", summary: "对方已离开中继房间", kind: "relay-peer-left", body: services.RelayPeerLeft{}},
+				{name: "relayDisconnection", summary: "中继房间被关闭，随后断开连接", kind: "disconnection", body: services.RelayDisconnection{}},
+				{name: "relayError", summary: "无法建立中继或流量超出配额，随后断开连接", kind: "error", body: services.RelayError{}},
+				{name: "relayPong", summary: "relay-ping 的响应", kind: "relay-pong", body: relay},
+				{name: "relayForwarded", summary: "对方发送的 relay-data 消息", kind: "relay-data", body: relay},
+				binary,
+			},
+		},
+		{
+			path:        "/api/v1/rooms/{code}/events",
+			description: "房间状态变化，需使用 " + services.RoomEventsSubprotocol + " 子协议；last_event_id 参数用于断线续传。房间结束后以 1000 关闭，推送过慢被断开时以 1013 关闭，应带 last_event_id 重连。",
+			query:       []string{"last_event_id"},
+			subscribe: []wsMessage{
+				{name: "roomEvent", summary: "房间状态变化", body: services.RoomEvent{}},
+			},
+		},
+	}
+}
+
+// GenerateAsync 生成描述 WebSocket 消息格式的 AsyncAPI 2.6 文档
+func GenerateAsync() map[string]interface{} {
+	s := newSchemas("#/components/schemas/")
+	messages := map[string]interface{}{}
+	channels := map[string]interface{}{}
+
+	for _, ch := range asyncChannels() {
+		channel := map[string]interface{}{"description": ch.description}
+		if len(ch.query) > 0 {
+			properties := Schema{}
+			for _, name := range ch.query {
+				properties[name] = Schema{"type": "string"}
+			}
+			channel["bindings"] = map[string]interface{}{
+				"ws": map[string]interface{}{
+					"method": "GET",
+					"query":  Schema{"type": "object", "properties": properties},
+				},
+			}
+		}
+		if len(ch.publish) > 0 {
+			channel["publish"] = map[string]interface{}{"message": oneOf(s, messages, ch.publish)}
+		}
+		if len(ch.subscribe) > 0 {
+			channel["subscribe"] = map[string]interface{}{"message": oneOf(s, messages, ch.subscribe)}
+		}
+		channels[ch.path] = channel
+	}
+
+	return map[string]interface{}{
+		"asyncapi": "2.6.0",
+		"info": map[string]interface{}{
+			"title":       "Chuan WebSocket API",
+			"version":     Version,
+			"description": "文件快传 WebSocket 信令、数据中继和房间事件的消息格式。publish 为客户端发送的消息，subscribe 为服务端发送的消息。error 和 disconnection 消息中的提示信息按 lang 参数或握手请求的 Accept-Language 返回中文或英文，客户端应按 code 或 reason 判断类型。",
+		},
+		"defaultContentType": "application/json",
+		"channels":           channels,
+		"components": map[string]interface{}{
+			"schemas":  s.defined,
+			"messages": messages,
+		},
+	}
+}
+
+// oneOf 注册消息并返回引用列表
+func oneOf(s *schemas, messages map[string]interface{}, list []wsMessage) map[string]interface{} {
+	var refs []interface{}
+	for _, m := range list {
+		if _, ok := messages[m.name]; !ok {
+			messages[m.name] = asyncMessage(s, m)
+		}
+		refs = append(refs, map[string]interface{}{"$ref": "#/components/messages/" + m.name})
+	}
+	return map[string]interface{}{"oneOf": refs}
+}
+
+func asyncMessage(s *schemas, m wsMessage) map[string]interface{} {
+	out := map[string]interface{}{"name": m.name, "summary": m.summary}
+	if m.body == nil {
+		out["contentType"] = "application/octet-stream"
+		out["payload"] = Schema{"type": "string", "format": "binary"}
+		return out
+	}
+
+	payload := s.of(reflect.TypeOf(m.body))
+	if m.kind == "" {
+		out["payload"] = payload
+		return out
+	}
+	// 在通用结构的基础上固定 type 的取值，并细化 payload 字段
+	properties := Schema{"type": Schema{"type": "string", "enum": []string{m.kind}}}
+	if m.payload != nil {
+		properties["payload"] = s.of(reflect.TypeOf(m.payload))
+	}
+	out["payload"] = Schema{"allOf": []Schema{payload, {"type": "object", "properties": properties}}}
+	return out
+}
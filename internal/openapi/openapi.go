@@ -0,0 +1,121 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"chuan/internal/api"
+)
+
+// Version v1 接口文档的版本号，接口发生不兼容变化时才会进入 /api/v2
+const Version = "1.0.0"
+
+// Generate 生成 OpenAPI 3.0 文档
+func Generate(ops []api.Operation) map[string]interface{} {
+	s := newSchemas("#/components/schemas/")
+	paths := map[string]interface{}{}
+
+	for _, op := range ops {
+		item, _ := paths[op.Path].(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{}
+			paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = operation(s, op)
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Chuan API",
+			"version":     Version,
+			"description": "文件快传 v1 接口。失败时返回 ErrorResponse，error.code 为稳定的错误码，error.message 按 lang 参数或 Accept-Language 返回中文或英文。WebSocket 信令和中继的消息格式见 /api/asyncapi.json。",
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"url": api.Prefix},
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": s.defined,
+			"securitySchemes": map[string]interface{}{
+				"apiKey": map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"basic":  map[string]interface{}{"type": "http", "scheme": "basic"},
+				"bearer": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+func operation(s *schemas, op api.Operation) map[string]interface{} {
+	out := map[string]interface{}{
+		"operationId": op.ID,
+		"summary":     op.Summary,
+	}
+	if op.Description != "" {
+		out["description"] = op.Description
+	}
+	if len(op.Tags) > 0 {
+		out["tags"] = op.Tags
+	}
+	if op.Auth == api.AuthCreate {
+		// 是否要求身份验证取决于服务端配置，空对象表示可以匿名访问
+		out["security"] = []interface{}{
+			map[string]interface{}{"apiKey": []string{}},
+			map[string]interface{}{"basic": []string{}},
+			map[string]interface{}{"bearer": []string{}},
+			map[string]interface{}{},
+		}
+	}
+
+	if len(op.Params) > 0 {
+		var params []interface{}
+		for _, p := range op.Params {
+			params = append(params, map[string]interface{}{
+				"name":        p.Name,
+				"in":          p.In,
+				"description": p.Description,
+				"required":    p.Required,
+				"schema":      Schema{"type": "string"},
+			})
+		}
+		out["parameters"] = params
+	}
+
+	if op.Request != nil {
+		out["requestBody"] = map[string]interface{}{
+			"required": false,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": s.of(reflect.TypeOf(op.Request))},
+			},
+		}
+	}
+
+	responses := map[string]interface{}{}
+	if op.EventStream != nil {
+		responses["200"] = map[string]interface{}{
+			"description": "事件流，每条事件的 data 为一个 JSON 对象",
+			"content": map[string]interface{}{
+				"text/event-stream": map[string]interface{}{"schema": s.of(reflect.TypeOf(op.EventStream))},
+			},
+		}
+	}
+	statuses := make([]int, 0, len(op.Responses))
+	for status := range op.Responses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		response := map[string]interface{}{"description": http.StatusText(status)}
+		if body := op.Responses[status]; body != nil {
+			response["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": s.of(reflect.TypeOf(body))},
+			}
+		}
+		responses[strconv.Itoa(status)] = response
+	}
+	out["responses"] = responses
+	return out
+}
@@ -0,0 +1,135 @@
+// Package openapi 根据 api.Operation 和请求、响应结构体生成 OpenAPI 3 文档，
+// 并为 WebSocket 消息格式生成 AsyncAPI 文档
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema JSON Schema（OpenAPI 3.0 子集）
+type Schema = map[string]interface{}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemas 收集具名结构体的 schema，放入 components.schemas，字段通过 $ref 引用
+type schemas struct {
+	prefix  string // $ref 前缀，OpenAPI 为 #/components/schemas/
+	defined map[string]Schema
+}
+
+func newSchemas(prefix string) *schemas {
+	return &schemas{prefix: prefix, defined: make(map[string]Schema)}
+}
+
+// of 返回类型对应的 schema，具名结构体返回 $ref
+func (s *schemas) of(t reflect.Type) Schema {
+	switch t {
+	case timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := s.of(t.Elem())
+		if _, ok := schema["$ref"]; ok {
+			// 3.0 中 $ref 的兄弟字段会被忽略，可空引用需要包一层 allOf
+			return Schema{"allOf": []Schema{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return Schema{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return Schema{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "format": "byte"}
+		}
+		return Schema{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Interface:
+		return Schema{}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		if _, ok := s.defined[t.Name()]; !ok {
+			s.defined[t.Name()] = nil // 先占位，防止递归类型无限展开
+			s.defined[t.Name()] = s.object(t)
+		}
+		return Schema{"$ref": s.prefix + t.Name()}
+	}
+	return Schema{}
+}
+
+// object 按 json 标签展开结构体字段，没有 omitempty 的字段为必填，匿名嵌入的结构体字段提升到外层
+func (s *schemas) object(t reflect.Type) Schema {
+	properties := Schema{}
+	var required []string
+	s.fields(t, properties, &required)
+
+	schema := Schema{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (s *schemas) fields(t reflect.Type, properties Schema, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			s.fields(field.Type, properties, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.of(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// Resolve 返回 $ref 指向的 schema，不是引用时原样返回
+func Resolve(doc map[string]interface{}, schema Schema) Schema {
+	ref, ok := schema["$ref"].(string)
+	if !ok {
+		return schema
+	}
+	var node interface{} = doc
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		node = m[part]
+	}
+	resolved, _ := node.(Schema)
+	return resolved
+}
@@ -0,0 +1,360 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"chuan/internal/accounting"
+	"chuan/internal/events"
+	"chuan/internal/logging"
+)
+
+// 修改房间有效期失败的原因
+var (
+	ErrRoomNotFound    = errors.New("房间不存在或已过期")
+	ErrRoomPersistent  = errors.New("持久房间不会过期，不能修改有效期")
+	ErrRoomMaxLifetime = errors.New("房间已达到最长使用时间，不能再延长")
+)
+
+// AdminService 管理接口：查看、关闭房间和延长房间有效期
+type AdminService struct {
+	webrtcService *WebRTCService
+	relayService  *RelayService
+	startedAt     time.Time
+}
+
+// RoomInfo 房间快照
+type RoomInfo struct {
+	Code       string            `json:"code"`
+	Owner      string            `json:"owner,omitempty"`      // 创建者身份，匿名创建时为空
+	Persistent bool              `json:"persistent,omitempty"` // 持久房间
+	CreatedAt  time.Time         `json:"created_at"`
+	ExpiresAt  time.Time         `json:"expires_at"`
+	LastSeen   time.Time         `json:"last_seen"`
+	Clients    []ParticipantInfo `json:"clients"`         // 信令连接
+	Relay      *RelayInfo        `json:"relay,omitempty"` // 中继连接，未使用中继时为空
+}
+
+// ParticipantInfo 房间内的一个连接
+type ParticipantInfo struct {
+	ID          string    `json:"id"`
+	Role        string    `json:"role"`
+	Transport   string    `json:"transport"` // websocket | sse | webtransport
+	ConnectedAt time.Time `json:"connected_at"`
+}
+
+// RelayInfo 中继房间快照
+type RelayInfo struct {
+	CreatedAt     time.Time         `json:"created_at"`
+	Clients       []ParticipantInfo `json:"clients"`
+	SenderBytes   int64             `json:"sender_bytes"`   // 发送方 → 接收方已转发的字节数
+	ReceiverBytes int64             `json:"receiver_bytes"` // 接收方 → 发送方已转发的字节数
+	Messages      int64             `json:"messages"`       // 已转发的消息和数据流数量
+}
+
+// 管理员关闭房间时通知客户端的断开原因
+const ReasonClosedByAdmin = "closed_by_admin"
+
+// adminIdentity 审计事件中管理员操作的身份，管理令牌不区分具体管理员
+const adminIdentity = "admin"
+
+func NewAdminService(webrtcService *WebRTCService, relayService *RelayService) *AdminService {
+	return &AdminService{
+		webrtcService: webrtcService,
+		relayService:  relayService,
+		startedAt:     time.Now(),
+	}
+}
+
+// ServerStats 管理面板使用的服务运行概况
+type ServerStats struct {
+	StartedAt       time.Time            `json:"started_at"`
+	UptimeSeconds   int64                `json:"uptime_seconds"`
+	Rooms           int                  `json:"rooms"`             // 信令房间数
+	SignalClients   int                  `json:"signal_clients"`    // 在线信令连接数
+	RelaySessions   []RelaySessionStats  `json:"relay_sessions"`    // 活跃的中继会话
+	RelayTotalBytes int64                `json:"relay_total_bytes"` // 启动以来累计中继字节数
+	RecentErrors    []logging.ErrorEntry `json:"recent_errors"`
+}
+
+// RelaySessionStats 单个中继会话的流量统计
+type RelaySessionStats struct {
+	Code           string    `json:"code"`
+	CreatedAt      time.Time `json:"created_at"`
+	Clients        int       `json:"clients"`
+	Bytes          int64     `json:"bytes"` // 双向合计
+	Messages       int64     `json:"messages"`
+	BytesPerSecond float64   `json:"bytes_per_second"` // 由调用方根据相邻两次快照计算
+}
+
+// Stats 返回当前的服务运行概况
+func (as *AdminService) Stats() ServerStats {
+	stats := ServerStats{
+		StartedAt:       as.startedAt,
+		UptimeSeconds:   int64(time.Since(as.startedAt).Seconds()),
+		RelaySessions:   []RelaySessionStats{},
+		RelayTotalBytes: as.relayService.totalBytes.Load(),
+		RecentErrors:    logging.RecentErrors(),
+	}
+
+	as.webrtcService.roomsMux.RLock()
+	stats.Rooms = len(as.webrtcService.rooms)
+	for _, room := range as.webrtcService.rooms {
+		for _, c := range []*WebRTCClient{room.Sender, room.Receiver} {
+			if c != nil {
+				stats.SignalClients++
+			}
+		}
+	}
+	as.webrtcService.roomsMux.RUnlock()
+
+	as.relayService.roomsMux.RLock()
+	relayRooms := make([]*RelayRoom, 0, len(as.relayService.rooms))
+	for _, room := range as.relayService.rooms {
+		relayRooms = append(relayRooms, room)
+	}
+	as.relayService.roomsMux.RUnlock()
+
+	for _, room := range relayRooms {
+		session := RelaySessionStats{
+			Code:      room.Code,
+			CreatedAt: room.CreatedAt,
+			Bytes:     room.senderBytes.Load() + room.receiverBytes.Load(),
+			Messages:  room.messages.Load(),
+		}
+		room.mu.Lock()
+		for _, c := range []*RelayClient{room.Sender, room.Receiver} {
+			if c != nil {
+				session.Clients++
+			}
+		}
+		room.mu.Unlock()
+		stats.RelaySessions = append(stats.RelaySessions, session)
+	}
+	sort.Slice(stats.RelaySessions, func(i, j int) bool {
+		return stats.RelaySessions[i].CreatedAt.Before(stats.RelaySessions[j].CreatedAt)
+	})
+	return stats
+}
+
+// ListRooms 返回所有活跃房间，按创建时间排序
+func (as *AdminService) ListRooms() []RoomInfo {
+	codes := make(map[string]bool)
+	as.webrtcService.roomsMux.RLock()
+	for code := range as.webrtcService.rooms {
+		codes[code] = true
+	}
+	as.webrtcService.roomsMux.RUnlock()
+
+	as.relayService.roomsMux.RLock()
+	for code := range as.relayService.rooms {
+		codes[code] = true
+	}
+	as.relayService.roomsMux.RUnlock()
+
+	rooms := make([]RoomInfo, 0, len(codes))
+	for code := range codes {
+		if info, ok := as.GetRoom(code); ok {
+			rooms = append(rooms, info)
+		}
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].CreatedAt.Before(rooms[j].CreatedAt)
+	})
+	return rooms
+}
+
+// GetRoom 返回单个房间的详情
+func (as *AdminService) GetRoom(code string) (RoomInfo, bool) {
+	info := RoomInfo{Code: code, Clients: []ParticipantInfo{}}
+	found := false
+
+	as.webrtcService.roomsMux.RLock()
+	if room := as.webrtcService.rooms[code]; room != nil {
+		found = true
+		info.Owner = room.Owner
+		info.Persistent = room.Persistent
+		info.CreatedAt = room.CreatedAt
+		info.ExpiresAt = room.ExpiresAt
+		info.LastSeen = room.LastSeen()
+		for _, c := range []*WebRTCClient{room.Sender, room.Receiver} {
+			if c != nil {
+				info.Clients = append(info.Clients, ParticipantInfo{
+					ID:          c.ID,
+					Role:        c.Role,
+					Transport:   c.transport(),
+					ConnectedAt: c.ConnectedAt,
+				})
+			}
+		}
+	}
+	as.webrtcService.roomsMux.RUnlock()
+
+	as.relayService.roomsMux.RLock()
+	relayRoom := as.relayService.rooms[code]
+	as.relayService.roomsMux.RUnlock()
+
+	if relayRoom != nil {
+		found = true
+		relay := &RelayInfo{
+			CreatedAt:     relayRoom.CreatedAt,
+			Clients:       []ParticipantInfo{},
+			SenderBytes:   relayRoom.senderBytes.Load(),
+			ReceiverBytes: relayRoom.receiverBytes.Load(),
+			Messages:      relayRoom.messages.Load(),
+		}
+		relayRoom.mu.Lock()
+		for _, c := range []*RelayClient{relayRoom.Sender, relayRoom.Receiver} {
+			if c != nil {
+				relay.Clients = append(relay.Clients, ParticipantInfo{
+					ID:          c.ID,
+					Role:        c.Role,
+					Transport:   c.transport(),
+					ConnectedAt: c.ConnectedAt,
+				})
+			}
+		}
+		relayRoom.mu.Unlock()
+		info.Relay = relay
+		if info.CreatedAt.IsZero() {
+			info.CreatedAt = relayRoom.CreatedAt
+		}
+	}
+
+	return info, found
+}
+
+// CloseRoom 强制关闭房间：通知所有信令和中继客户端后断开连接，房间码随即失效
+// 持久房间只断开连接，客户端离开后房间被重置
+// remoteAddr 为管理员请求的来源地址，记入审计事件
+func (as *AdminService) CloseRoom(code, remoteAddr string) bool {
+	ws := as.webrtcService
+	ws.roomsMux.Lock()
+	room := ws.rooms[code]
+	var clients []*WebRTCClient
+	if room != nil {
+		if !room.Persistent {
+			delete(ws.rooms, code)
+		}
+		for _, c := range []*WebRTCClient{room.Sender, room.Receiver} {
+			if c != nil {
+				clients = append(clients, c)
+			}
+		}
+	}
+	ws.roomsMux.Unlock()
+
+	disconnectClients(clients, ReasonClosedByAdmin)
+	relayRoom, relayClosed := as.relayService.closeRoom(code, ReasonClosedByAdmin)
+
+	if room == nil && relayRoom == nil {
+		return false
+	}
+	logging.Infof("🛑 管理员关闭房间: %s (信令连接=%d, 中继连接=%d)", code, len(clients), relayClosed)
+	var owner string
+	if room != nil {
+		owner = room.Owner
+	} else {
+		owner = relayRoom.Owner
+	}
+	ws.bus.Publish(events.Event{
+		Type:       events.AdminRoomClosed,
+		Room:       code,
+		Identity:   adminIdentity,
+		RemoteAddr: remoteAddr,
+		Data: map[string]interface{}{
+			"owner":         owner,
+			"persistent":    room != nil && room.Persistent,
+			"signal_closed": len(clients),
+			"relay_closed":  relayClosed,
+		},
+	})
+	return true
+}
+
+// ExtendRoom 修改房间过期时间并返回实际生效的过期时间
+// expiresAt 非零时直接设置为该时间，否则在当前过期时间（已过期则为现在）的基础上延长 duration
+// 房间超过最长使用时间（MaxLifetime）后无论如何都会被关闭，因此过期时间最多设置到 CreatedAt+MaxLifetime，此时 clamped 为 true
+func (as *AdminService) ExtendRoom(code, remoteAddr string, duration time.Duration, expiresAt time.Time) (effective time.Time, clamped bool, err error) {
+	ws := as.webrtcService
+	opts := ws.opts.Load()
+	now := time.Now()
+
+	ws.roomsMux.Lock()
+	room := ws.rooms[code]
+	switch {
+	case room == nil:
+		ws.roomsMux.Unlock()
+		return time.Time{}, false, ErrRoomNotFound
+	case room.Persistent:
+		ws.roomsMux.Unlock()
+		return time.Time{}, false, ErrRoomPersistent
+	}
+
+	if expiresAt.IsZero() {
+		base := room.ExpiresAt
+		if base.Before(now) {
+			base = now
+		}
+		expiresAt = base.Add(duration)
+	}
+	if opts.MaxLifetime > 0 {
+		limit := room.CreatedAt.Add(opts.MaxLifetime)
+		if !limit.After(now) {
+			ws.roomsMux.Unlock()
+			return time.Time{}, false, ErrRoomMaxLifetime
+		}
+		if expiresAt.After(limit) {
+			expiresAt, clamped = limit, true
+		}
+	}
+	previous := room.ExpiresAt
+	room.ExpiresAt = expiresAt
+	owner := room.Owner
+	ws.roomsMux.Unlock()
+
+	logging.Infof("⏰ 管理员修改房间过期时间: %s → %s", code, expiresAt.Format(time.RFC3339))
+	ws.bus.Publish(events.Event{
+		Type:       events.AdminRoomExtended,
+		Room:       code,
+		Identity:   adminIdentity,
+		RemoteAddr: remoteAddr,
+		Data: map[string]interface{}{
+			"owner":               owner,
+			"previous_expires_at": previous,
+			"expires_at":          expiresAt,
+			"clamped":             clamped,
+		},
+	})
+	return expiresAt, clamped, nil
+}
+
+// IdentityUsage 单个身份当天和当月的用量
+type IdentityUsage struct {
+	Identity string           `json:"identity"`
+	Day      accounting.Usage `json:"day"`
+	Month    accounting.Usage `json:"month"`
+	Quota    accounting.Quota `json:"quota"`
+}
+
+// Quota 返回当前生效的配额
+func (as *AdminService) Quota() accounting.Quota {
+	return as.webrtcService.opts.Load().Quota
+}
+
+// ListUsage 返回指定周期（2006-01-02 或 2006-01）内所有身份的用量
+func (as *AdminService) ListUsage(period string) ([]accounting.Record, error) {
+	return as.webrtcService.ledger.List(period)
+}
+
+// GetUsage 返回单个身份当天和当月的用量
+func (as *AdminService) GetUsage(identity string) IdentityUsage {
+	day, month := as.webrtcService.ledger.Usage(identity, time.Now())
+	return IdentityUsage{
+		Identity: identity,
+		Day:      day,
+		Month:    month,
+		Quota:    as.Quota(),
+	}
+}
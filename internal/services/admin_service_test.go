@@ -0,0 +1,70 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"chuan/internal/events"
+)
+
+// TestExtendRoom 延长有效期时不超过最长使用时间，持久房间和已达到最长使用时间的房间被拒绝
+func TestExtendRoom(t *testing.T) {
+	opts := DefaultOptions()
+	opts.MaxLifetime = 2 * time.Hour
+	ws := NewWebRTCService(opts, nil, events.NewBus())
+	as := NewAdminService(ws, NewRelayService(ws, opts))
+
+	now := time.Now()
+	ws.rooms["FRESH1"] = &WebRTCRoom{Code: "FRESH1", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	ws.rooms["AGED01"] = &WebRTCRoom{Code: "AGED01", CreatedAt: now.Add(-3 * time.Hour), ExpiresAt: now.Add(time.Minute)}
+	ws.rooms["KEEP01"] = &WebRTCRoom{Code: "KEEP01", Persistent: true, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	limit := now.Add(opts.MaxLifetime)
+
+	tests := []struct {
+		name        string
+		code        string
+		duration    time.Duration
+		expiresAt   time.Time
+		want        time.Time
+		wantClamped bool
+		wantErr     error
+	}{
+		{"延长", "FRESH1", 30 * time.Minute, time.Time{}, now.Add(90 * time.Minute), false, nil},
+		{"延长超过最长使用时间", "FRESH1", 5 * time.Hour, time.Time{}, limit, true, nil},
+		{"指定时间超过最长使用时间", "FRESH1", 0, now.Add(24 * time.Hour), limit, true, nil},
+		{"指定时间", "FRESH1", 0, now.Add(time.Hour), now.Add(time.Hour), false, nil},
+		{"已达到最长使用时间", "AGED01", time.Hour, time.Time{}, time.Time{}, false, ErrRoomMaxLifetime},
+		{"持久房间", "KEEP01", time.Hour, time.Time{}, time.Time{}, false, ErrRoomPersistent},
+		{"房间不存在", "NONE01", time.Hour, time.Time{}, time.Time{}, false, ErrRoomNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, clamped, err := as.ExtendRoom(tt.code, "", tt.duration, tt.expiresAt)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, 期望 %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			// 延长时以调用时的当前时间为基准，允许少量误差
+			if got.Sub(tt.want).Abs() > time.Second || clamped != tt.wantClamped {
+				t.Fatalf("ExtendRoom = %v, clamped=%v, 期望 %v, clamped=%v", got, clamped, tt.want, tt.wantClamped)
+			}
+			ws.roomsMux.RLock()
+			stored := ws.rooms[tt.code].ExpiresAt
+			ws.roomsMux.RUnlock()
+			if !stored.Equal(got) {
+				t.Fatalf("房间过期时间 = %v, 应与返回值 %v 一致", stored, got)
+			}
+		})
+	}
+
+	// 不限制最长使用时间时不截断
+	opts.MaxLifetime = 0
+	ws.opts.Store(opts)
+	got, clamped, err := as.ExtendRoom("AGED01", "", 0, now.Add(48*time.Hour))
+	if err != nil || clamped || !got.Equal(now.Add(48*time.Hour)) {
+		t.Fatalf("ExtendRoom = %v, %v, %v", got, clamped, err)
+	}
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"chuan/internal/logging"
+
+	"github.com/gorilla/websocket"
+)
+
+// 发送队列溢出策略
+const (
+	OverflowDrop  = "drop"  // 丢弃新消息，保持连接
+	OverflowClose = "close" // 断开过慢的客户端
+)
+
+// clientWriter 客户端出站队列：所有写操作都投递到队列，由独立的写协程完成网络 I/O
+type clientWriter struct {
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+	policy    string
+}
+
+func newClientWriter(opts Options) *clientWriter {
+	return &clientWriter{
+		send:   make(chan []byte, opts.SendQueueSize),
+		done:   make(chan struct{}),
+		policy: opts.OverflowPolicy,
+	}
+}
+
+// Send 将消息编码为 JSON 并投递到发送队列，不会阻塞调用方
+// 返回 false 表示消息未能入队（队列已满或客户端已关闭）
+func (c *WebRTCClient) Send(msg interface{}) bool {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		logging.Errorf("编码WebRTC消息失败: %v", err)
+		return false
+	}
+
+	select {
+	case <-c.writer.done:
+		return false
+	default:
+	}
+
+	select {
+	case c.writer.send <- data:
+		return true
+	default:
+		if c.writer.policy == OverflowClose {
+			logging.Warnf("WebRTC客户端发送队列已满，断开连接: %s (房间: %s)", c.ID, c.Room)
+			c.Close()
+		} else {
+			logging.Warnf("WebRTC客户端发送队列已满，丢弃消息: %s (房间: %s)", c.ID, c.Room)
+		}
+		return false
+	}
+}
+
+// SendAndClose 投递最后一条消息，写出后关闭连接；队列已满时直接关闭
+func (c *WebRTCClient) SendAndClose(msg interface{}) {
+	if c.Send(msg) {
+		select {
+		case c.writer.send <- nil: // nil 表示写完之前的消息后关闭
+			return
+		default:
+		}
+	}
+	c.Close()
+}
+
+// Close 停止写协程并关闭底层连接，可重复调用
+func (c *WebRTCClient) Close() {
+	c.writer.closeOnce.Do(func() {
+		close(c.writer.done)
+		if c.Connection != nil {
+			c.Connection.Close()
+		}
+	})
+}
+
+// writePump 写协程：串行地把队列中的消息写入 WebSocket
+func (c *WebRTCClient) writePump(writeTimeout time.Duration) {
+	for {
+		select {
+		case <-c.writer.done:
+			return
+		case data := <-c.writer.send:
+			if data == nil {
+				c.Close()
+				return
+			}
+			c.Connection.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := c.Connection.WriteMessage(websocket.TextMessage, data); err != nil {
+				logging.Errorf("写入WebRTC消息失败: %s (房间: %s): %v", c.ID, c.Room, err)
+				c.Close()
+				return
+			}
+		}
+	}
+}
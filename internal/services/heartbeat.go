@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// controlWriteTimeout 发送 ping 等控制帧的写超时
+const controlWriteTimeout = 10 * time.Second
+
+// startHeartbeat 为连接设置读超时并定期发送 ping，返回停止函数
+// 每收到一次 pong 都会调用 onAlive 并顺延读超时；超时后读循环会返回错误，由调用方按断线处理
+func startHeartbeat(conn *websocket.Conn, opts Options, onAlive func()) func() {
+	extendReadDeadline(conn, opts)
+	conn.SetPongHandler(func(string) error {
+		if onAlive != nil {
+			onAlive()
+		}
+		extendReadDeadline(conn, opts)
+		return nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(opts.PingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				// WriteControl 可以与其他写操作并发调用
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(controlWriteTimeout)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// extendReadDeadline 顺延连接的读超时
+func extendReadDeadline(conn *websocket.Conn, opts Options) {
+	conn.SetReadDeadline(time.Now().Add(opts.PongTimeout))
+}
+
+// isTimeoutError 判断是否为读超时（心跳超时）导致的错误
+func isTimeoutError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"chuan/internal/api"
+	"chuan/internal/i18n"
+)
+
+// 服务端主动发送的 WebSocket/WebTransport 控制消息，AsyncAPI 文档由这些结构体生成
+// 提示信息按连接时协商的语言（lang 参数或 Accept-Language）本地化，客户端应按 code/reason 判断类型
+
+// SignalError 信令连接无法加入房间时发送的错误，随后服务端断开连接
+type SignalError struct {
+	Type    string `json:"type"` // "error"
+	Code    string `json:"code"` // api 包中的错误码，如 room_not_found、room_full
+	Message string `json:"message"`
+}
+
+// newSignalError 返回按 lang 本地化的信令错误
+func newSignalError(lang, code string) SignalError {
+	return SignalError{Type: "error", Code: code, Message: i18n.T(lang, "error."+code)}
+}
+
+// PeerJoinedPayload peer-joined 信令的 payload：对方已加入房间
+type PeerJoinedPayload struct {
+	Role string `json:"role"`
+}
+
+// DisconnectionPayload disconnection 信令的 payload：对方离开或房间被关闭
+type DisconnectionPayload struct {
+	Role    string `json:"role"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// ConnectedPayload SSE 信令连接的首条 connected 消息的 payload，提交信令时需要携带 client_id 和 token
+type ConnectedPayload struct {
+	ClientID string `json:"client_id"`
+	Token    string `json:"token"`
+	Role     string `json:"role"`
+}
+
+// RelayReady 加入中继房间后发给自己的就绪通知
+type RelayReady struct {
+	Type          string `json:"type"` // "relay-ready"
+	Role          string `json:"role"`
+	PeerConnected bool   `json:"peer_connected"`
+}
+
+// RelayPeerJoined 对方加入中继房间
+type RelayPeerJoined struct {
+	Type     string `json:"type"` // "relay-peer-joined"
+	PeerRole string `json:"peer_role"`
+}
+
+// RelayPeerLeft 对方离开中继房间
+type RelayPeerLeft struct {
+	Type     string `json:"type"` // "relay-peer-left"
+	PeerRole string `json:"peer_role"`
+	Reason   string `json:"reason"`
+}
+
+// RelayDisconnection 中继房间被关闭（管理员关闭、超过最长使用时间等），随后服务端断开连接
+type RelayDisconnection struct {
+	Type    string `json:"type"` // "disconnection"
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// RelayError 中继连接无法建立或流量超出配额，随后服务端断开连接
+type RelayError struct {
+	Type   string `json:"type"` // "error"
+	Code   string `json:"code"` // api 包中的错误码，如 room_not_found、relay_quota_exceeded
+	Error  string `json:"error"`
+	Reason string `json:"reason,omitempty"` // quota_exceeded，保留给按 reason 判断的旧客户端
+}
+
+// newRelayError 返回按 lang 本地化的中继错误
+func newRelayError(lang, code string) RelayError {
+	e := RelayError{Type: "error", Code: code, Error: i18n.T(lang, "error."+code)}
+	if code == api.CodeRelayQuotaExceeded {
+		e.Reason = "quota_exceeded"
+	}
+	return e
+}
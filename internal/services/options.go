@@ -0,0 +1,90 @@
+package services
+
+import (
+	"sync/atomic"
+	"time"
+
+	"chuan/internal/accounting"
+)
+
+// Options 服务运行参数
+type Options struct {
+	// PingInterval 服务端主动发送 WebSocket ping 的间隔
+	PingInterval time.Duration
+	// PongTimeout 超过该时长未收到对端任何数据（包括 pong）即判定对端失联
+	PongTimeout time.Duration
+	// SendQueueSize 每个信令客户端出站队列的容量
+	SendQueueSize int
+	// WriteTimeout 单条消息的写超时
+	WriteTimeout time.Duration
+	// OverflowPolicy 出站队列已满时的处理策略：OverflowDrop 或 OverflowClose
+	OverflowPolicy string
+
+	// RoomTTL 房间创建后的默认有效期，无人使用的房间超过有效期后被清理
+	RoomTTL time.Duration
+	// MinRoomTTL、MaxRoomTTL 创建房间时可请求的有效期范围
+	MinRoomTTL time.Duration
+	MaxRoomTTL time.Duration
+	// IdleTimeout 有过活动的房间在所有人离开后保留的时间，期间可以重新加入
+	IdleTimeout time.Duration
+	// MaxLifetime 房间从创建起的最长使用时间，超过后断开所有连接，0 表示不限制
+	MaxLifetime time.Duration
+	// CleanupInterval 过期房间的清理间隔
+	CleanupInterval time.Duration
+	// CodeLength 取件码长度
+	CodeLength int
+	// ReservedCodes 保留给指定身份的房间码，其他人无法创建，随机生成时也会跳过
+	ReservedCodes []ReservedCode
+	// PersistentCodes 持久房间：启动时创建，清理任务不会删除，房间为空时只重置有效期
+	PersistentCodes []string
+
+	// RelayEnabled 是否接受数据中继连接（WebSocket 和 WebTransport）
+	RelayEnabled bool
+	// RelayBufferSize 中继 WebSocket 的读写缓冲区大小（字节）
+	RelayBufferSize int
+	// RelayMaxMessageSize 中继单条消息的最大尺寸（字节）
+	RelayMaxMessageSize int64
+
+	// Origins 允许发起 WebSocket/WebTransport 连接的跨域来源，nil 表示只允许同源
+	Origins *OriginPolicy
+
+	// Quota 按房间创建者计算的每日/每月配额
+	Quota accounting.Quota
+}
+
+// optionsValue 可在运行时原子替换的服务运行参数
+// 已建立的连接沿用建立时的参数，替换只影响之后的新连接和新房间
+type optionsValue struct {
+	p atomic.Pointer[Options]
+}
+
+func (v *optionsValue) Load() Options {
+	return *v.p.Load()
+}
+
+func (v *optionsValue) Store(opts Options) {
+	v.p.Store(&opts)
+}
+
+// DefaultOptions 返回默认服务运行参数
+func DefaultOptions() Options {
+	return Options{
+		PingInterval:   25 * time.Second,
+		PongTimeout:    60 * time.Second,
+		SendQueueSize:  64,
+		WriteTimeout:   10 * time.Second,
+		OverflowPolicy: OverflowDrop,
+
+		RoomTTL:         time.Hour,
+		MinRoomTTL:      time.Minute,
+		MaxRoomTTL:      24 * time.Hour,
+		IdleTimeout:     10 * time.Minute,
+		MaxLifetime:     24 * time.Hour,
+		CleanupInterval: time.Minute,
+		CodeLength:      6,
+
+		RelayEnabled:        true,
+		RelayBufferSize:     10 * 1024 * 1024,
+		RelayMaxMessageSize: 10 * 1024 * 1024,
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// OriginPolicy 跨域来源策略，统一用于 CORS 和 WebSocket/WebTransport 的 Origin 校验
+//
+// 同源请求始终允许，规则列表为空时只允许同源。支持的规则:
+//   - "*"                        允许所有来源，需要显式配置
+//   - "https://example.com"      精确匹配（忽略大小写）
+//   - "https://*.example.com"    匹配任意子域名（不含 example.com 本身）；省略协议时匹配任意协议
+//   - "regex:^https://.*\.example\.(com|cn)$"  正则表达式，需完整匹配，忽略大小写
+type OriginPolicy struct {
+	allowAll  bool
+	exact     map[string]bool
+	wildcards []originWildcard
+	patterns  []*regexp.Regexp
+}
+
+// originWildcard 子域名通配规则
+type originWildcard struct {
+	scheme string // 为空表示任意协议
+	suffix string // 如 ".example.com" 或 ".example.com:8443"
+}
+
+// NewOriginPolicy 解析来源规则列表
+func NewOriginPolicy(rules []string) (*OriginPolicy, error) {
+	p := &OriginPolicy{exact: make(map[string]bool)}
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		switch {
+		case rule == "":
+			continue
+		case rule == "*":
+			p.allowAll = true
+		case strings.HasPrefix(rule, "regex:"):
+			// 来源在匹配前统一为小写，正则忽略大小写以免大写的规则永远无法匹配
+			re, err := regexp.Compile("(?i)^(?:" + strings.TrimPrefix(rule, "regex:") + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("来源规则 %q 不是有效的正则表达式: %v", rule, err)
+			}
+			p.patterns = append(p.patterns, re)
+		case strings.Contains(rule, "*"):
+			w, err := parseOriginWildcard(rule)
+			if err != nil {
+				return nil, err
+			}
+			p.wildcards = append(p.wildcards, w)
+		default:
+			origin := normalizeOrigin(rule)
+			if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" {
+				if origin != "null" {
+					return nil, fmt.Errorf("来源规则 %q 无效，应为 scheme://host[:port] 格式", rule)
+				}
+			}
+			p.exact[origin] = true
+		}
+	}
+	return p, nil
+}
+
+// parseOriginWildcard 解析 "https://*.example.com" 或 "*.example.com" 形式的规则
+func parseOriginWildcard(rule string) (originWildcard, error) {
+	var w originWildcard
+	host := normalizeOrigin(rule)
+	if scheme, rest, ok := strings.Cut(host, "://"); ok {
+		w.scheme, host = scheme, rest
+	}
+	if !strings.HasPrefix(host, "*.") || strings.Contains(host[1:], "*") || len(host) <= 2 {
+		return w, fmt.Errorf("来源规则 %q 无效，通配符只能用于子域名，如 https://*.example.com", rule)
+	}
+	w.suffix = host[1:]
+	return w, nil
+}
+
+// normalizeOrigin 统一大小写并去掉末尾的斜杠
+func normalizeOrigin(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(origin)), "/")
+}
+
+// AllowAll 是否允许所有来源；nil 策略只允许同源
+func (p *OriginPolicy) AllowAll() bool {
+	return p != nil && p.allowAll
+}
+
+// Allowed 判断跨域来源是否被允许
+func (p *OriginPolicy) Allowed(origin string) bool {
+	if p == nil {
+		return false
+	}
+	if p.allowAll {
+		return true
+	}
+
+	origin = normalizeOrigin(origin)
+	if p.exact[origin] {
+		return true
+	}
+
+	if scheme, host, ok := strings.Cut(origin, "://"); ok {
+		for _, w := range p.wildcards {
+			if (w.scheme == "" || w.scheme == scheme) && len(host) > len(w.suffix) && strings.HasSuffix(host, w.suffix) {
+				return true
+			}
+		}
+	}
+
+	for _, re := range p.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckOrigin 供 WebSocket/WebTransport 升级时使用，防止跨站 WebSocket 劫持
+// 没有 Origin 头的请求来自非浏览器客户端，同源请求始终允许，其余请求按策略校验
+func (p *OriginPolicy) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return p.Allowed(origin)
+}
+
+// CheckOriginSameHost 供 WebTransport 使用：WebTransport 监听独立的 UDP 端口，
+// 站点自身的页面发起的连接端口不同，主机名相同即视为同源，其余请求按策略校验
+func (p *OriginPolicy) CheckOriginSameHost(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && u.Hostname() != "" && strings.EqualFold(u.Hostname(), hostname(r.Host)) {
+		return true
+	}
+	return p.Allowed(origin)
+}
+
+// hostname 去掉 Host 请求头中的端口
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return strings.Trim(host, "[]")
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"chuan/internal/accounting"
+	"chuan/internal/events"
+
+	"github.com/gorilla/websocket"
+)
+
+func mustOriginPolicy(t *testing.T, rules ...string) *OriginPolicy {
+	t.Helper()
+	p, err := NewOriginPolicy(rules)
+	if err != nil {
+		t.Fatalf("NewOriginPolicy(%q): %v", rules, err)
+	}
+	return p
+}
+
+func TestOriginPolicyAllowed(t *testing.T) {
+	tests := []struct {
+		name   string
+		rules  []string
+		origin string
+		want   bool
+	}{
+		{"空规则拒绝跨域", nil, "https://evil.example", false},
+		{"nil 策略拒绝跨域", []string{}, "https://transfer.example.com", false},
+		{"显式 * 允许所有", []string{"*"}, "https://evil.example", true},
+
+		{"精确匹配", []string{"https://transfer.example.com"}, "https://transfer.example.com", true},
+		{"精确匹配忽略大小写和末尾斜杠", []string{"https://Transfer.Example.com/"}, "HTTPS://transfer.example.COM", true},
+		{"精确匹配区分协议", []string{"https://transfer.example.com"}, "http://transfer.example.com", false},
+		{"精确匹配区分端口", []string{"https://transfer.example.com"}, "https://transfer.example.com:8443", false},
+		{"精确匹配不匹配后缀", []string{"https://example.com"}, "https://evil-example.com", false},
+
+		{"通配子域名", []string{"https://*.example.com"}, "https://a.b.example.com", true},
+		{"通配不含根域名", []string{"https://*.example.com"}, "https://example.com", false},
+		{"通配区分协议", []string{"https://*.example.com"}, "http://a.example.com", false},
+		{"通配省略协议", []string{"*.example.com"}, "http://a.example.com", true},
+		{"通配不匹配相似域名", []string{"https://*.example.com"}, "https://a.example.com.evil", false},
+		{"通配不匹配拼接域名", []string{"https://*.example.com"}, "https://evilexample.com", false},
+
+		{"正则完整匹配", []string{`regex:https://pr-[0-9]+\.preview\.example\.com`}, "https://pr-42.preview.example.com", true},
+		{"正则需要完整匹配", []string{`regex:https://pr-[0-9]+\.preview\.example\.com`}, "https://pr-42.preview.example.com.evil", false},
+		{"正则前缀不能绕过", []string{`regex:https://pr-[0-9]+\.preview\.example\.com`}, "https://evil/https://pr-1.preview.example.com", false},
+		{"正则忽略大小写", []string{`regex:https://PR-[0-9]+\.Preview\.Example\.com`}, "https://pr-7.preview.example.com", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := mustOriginPolicy(t, tt.rules...)
+			if got := p.Allowed(tt.origin); got != tt.want {
+				t.Errorf("Allowed(%q) = %v, 规则 %q, 期望 %v", tt.origin, got, tt.rules, tt.want)
+			}
+		})
+	}
+
+	var nilPolicy *OriginPolicy
+	if nilPolicy.Allowed("https://evil.example") || nilPolicy.AllowAll() {
+		t.Error("nil 策略应只允许同源")
+	}
+}
+
+func TestNewOriginPolicyInvalid(t *testing.T) {
+	for _, rule := range []string{"regex:(", "https://a.*.example.com", "https://*", "example.com"} {
+		if _, err := NewOriginPolicy([]string{rule}); err == nil {
+			t.Errorf("NewOriginPolicy(%q) 应返回错误", rule)
+		}
+	}
+}
+
+func TestOriginPolicyCheckOrigin(t *testing.T) {
+	p := mustOriginPolicy(t)
+	request := func(host, origin string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "http://"+host+"/api/ws/webrtc", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		return r
+	}
+
+	if !p.CheckOrigin(request("transfer.example.com", "")) {
+		t.Error("不带 Origin 的非浏览器客户端应允许")
+	}
+	if !p.CheckOrigin(request("transfer.example.com", "https://transfer.example.com")) {
+		t.Error("同源请求应允许")
+	}
+	if p.CheckOrigin(request("transfer.example.com", "https://evil.example")) {
+		t.Error("跨域请求应拒绝")
+	}
+	if p.CheckOrigin(request("transfer.example.com:8443", "https://transfer.example.com")) {
+		t.Error("WebSocket 的端口不同应视为跨域")
+	}
+	if !p.CheckOriginSameHost(request("transfer.example.com:8443", "https://transfer.example.com")) {
+		t.Error("WebTransport 主机名相同应视为同源")
+	}
+	if p.CheckOriginSameHost(request("transfer.example.com:8443", "https://evil.example:8443")) {
+		t.Error("WebTransport 主机名不同应拒绝")
+	}
+}
+
+// newUpgradeTestServer 启动提供信令和中继 WebSocket 的测试服务器
+func newUpgradeTestServer(t *testing.T, rules ...string) *httptest.Server {
+	t.Helper()
+	opts := DefaultOptions()
+	opts.Origins = mustOriginPolicy(t, rules...)
+
+	ledger, err := accounting.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ledger.Close() })
+	webrtcService := NewWebRTCService(opts, ledger, events.NewBus())
+	relayService := NewRelayService(webrtcService, opts)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/ws/webrtc", webrtcService.HandleWebSocket)
+	mux.HandleFunc("/api/ws/relay", relayService.HandleRelayWebSocket)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestUpgradeRejectsCrossSiteOrigin 跨站 WebSocket 劫持：其他站点的页面不能连接信令和中继
+func TestUpgradeRejectsCrossSiteOrigin(t *testing.T) {
+	server := newUpgradeTestServer(t, "https://*.trusted.example")
+	base := "ws" + strings.TrimPrefix(server.URL, "http")
+	self := server.URL
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://evil.example", false},
+		{"null", false},
+		{"https://app.trusted.example", true},
+		{self, true},
+		{"", true},
+	}
+	for _, path := range []string{"/api/ws/webrtc", "/api/ws/relay"} {
+		for _, tt := range tests {
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set("Origin", tt.origin)
+			}
+			conn, resp, err := websocket.DefaultDialer.Dial(base+path+"?code=ABCDEF&role=sender", header)
+			if conn != nil {
+				conn.Close()
+			}
+			if tt.want {
+				if err != nil {
+					t.Errorf("%s Origin=%q 应允许连接: %v", path, tt.origin, err)
+				}
+				continue
+			}
+			if err == nil {
+				t.Errorf("%s Origin=%q 应拒绝连接", path, tt.origin)
+				continue
+			}
+			if resp == nil || resp.StatusCode != http.StatusForbidden {
+				t.Errorf("%s Origin=%q 应返回 403, 实际 %v", path, tt.origin, resp)
+			}
+		}
+	}
+}
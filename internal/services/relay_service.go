@@ -3,38 +3,195 @@ package services
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"chuan/internal/api"
+	"chuan/internal/events"
+	"chuan/internal/i18n"
+	"chuan/internal/logging"
+
 	"github.com/gorilla/websocket"
+	"github.com/quic-go/webtransport-go"
 )
 
 // RelayService 处理 WebSocket 数据中继（当 P2P 失败时的降级方案）
 type RelayService struct {
 	rooms    map[string]*RelayRoom
 	roomsMux sync.RWMutex
-	upgrader websocket.Upgrader
 	// 复用 WebRTCService 来验证房间
 	webrtcService *WebRTCService
+	opts          optionsValue
+
+	totalBytes atomic.Int64 // 服务启动以来累计转发的字节数（含已关闭的房间）
 }
 
 // RelayRoom 中继房间
 type RelayRoom struct {
 	Code      string
+	Owner     string // 信令房间的创建者，中继流量计入其用量
 	Sender    *RelayClient
 	Receiver  *RelayClient
 	CreatedAt time.Time
 	mu        sync.Mutex
+
+	senderBytes   atomic.Int64  // 发送方 → 接收方已转发的字节数
+	receiverBytes atomic.Int64  // 接收方 → 发送方已转发的字节数
+	messages      atomic.Int64  // 已转发的消息和数据流数量
+	totalBytes    *atomic.Int64 // 指向 RelayService.totalBytes
+}
+
+// countForwarded 记录一次成功转发
+func (room *RelayRoom) countForwarded(fromRole string, n int64) {
+	if fromRole == "sender" {
+		room.senderBytes.Add(n)
+	} else {
+		room.receiverBytes.Add(n)
+	}
+	room.totalBytes.Add(n)
+}
+
+// charge 记录一次成功转发并计入房间创建者的用量
+// 创建者的中继流量超出配额时返回 accounting.ErrRelayQuota
+func (rs *RelayService) charge(room *RelayRoom, fromRole string, n int64) error {
+	room.countForwarded(fromRole, n)
+	now := time.Now()
+	rs.webrtcService.ledger.AddRelayBytes(room.Owner, n, now)
+	return rs.webrtcService.ledger.CheckRelayBytes(room.Owner, rs.opts.Load().Quota, now)
+}
+
+// closeRoom 通知中继房间内的所有客户端房间已关闭后断开，返回中继房间（不存在时为 nil）和断开的连接数
+// 提示信息为 "disconnect."+reason，按各客户端的语言本地化；中继连接的读循环退出后会自行清理中继房间
+func (rs *RelayService) closeRoom(code, reason string) (*RelayRoom, int) {
+	rs.roomsMux.RLock()
+	room := rs.rooms[code]
+	rs.roomsMux.RUnlock()
+	if room == nil {
+		return nil, 0
+	}
+
+	room.mu.Lock()
+	var clients []*RelayClient
+	for _, c := range []*RelayClient{room.Sender, room.Receiver} {
+		if c != nil {
+			clients = append(clients, c)
+		}
+	}
+	room.mu.Unlock()
+
+	// 先通知所有中继客户端再断开，避免对方先收到 relay-peer-left
+	for _, c := range clients {
+		c.sendJSON(RelayDisconnection{Type: "disconnection", Reason: reason, Message: i18n.T(c.Lang, "disconnect."+reason)})
+	}
+	for _, c := range clients {
+		c.close()
+	}
+	return room, len(clients)
+}
+
+// closeOverQuota 通知中继房间内的所有客户端流量配额已用完并断开
+func (rs *RelayService) closeOverQuota(room *RelayRoom) {
+	room.mu.Lock()
+	clients := []*RelayClient{room.Sender, room.Receiver}
+	room.mu.Unlock()
+
+	logging.Warnf("[Relay] 🚫 中继流量超出配额，断开房间: Room=%s, Owner=%s", room.Code, room.Owner)
+	for _, c := range clients {
+		if c != nil {
+			c.sendJSON(newRelayError(c.Lang, api.CodeRelayQuotaExceeded))
+		}
+	}
+	for _, c := range clients {
+		if c != nil {
+			c.close()
+		}
+	}
 }
 
 // RelayClient 中继客户端
+// 通过 WebSocket 接入时 Connection 非空；通过 WebTransport 接入时 Session 非空
 type RelayClient struct {
-	ID         string
-	Role       string // "sender" or "receiver"
-	Connection *websocket.Conn
-	mu         sync.Mutex
+	ID          string
+	Role        string // "sender" or "receiver"
+	ConnectedAt time.Time
+	Identity    string // 加入时通过身份验证的身份，匿名时为空
+	RemoteAddr  string
+	Lang        string // 连接时协商的语言，用于本地化发给客户端的提示信息
+	Connection  *websocket.Conn
+	Session     *webtransport.Session
+	control     *webtransport.Stream // WebTransport 控制流，承载按行分隔的 JSON 消息
+	mu          sync.Mutex
+
+	// writeTimeout 每次写入的超时，对方停止读取时转发失败，避免阻塞发送方
+	writeTimeout time.Duration
+}
+
+// writeDeadline 返回本次写入的截止时间，未设置超时时为零值（不限制）
+func (c *RelayClient) writeDeadline() time.Time {
+	if c.writeTimeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(c.writeTimeout)
+}
+
+// sendJSON 向客户端发送一条 JSON 控制消息
+func (c *RelayClient) sendJSON(msg interface{}) error {
+	if c.Session == nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.Connection.SetWriteDeadline(c.writeDeadline())
+		return c.Connection.WriteJSON(msg)
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.sendText(data)
+}
+
+// sendText 向客户端转发一条文本（JSON）消息
+func (c *RelayClient) sendText(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Session == nil {
+		c.Connection.SetWriteDeadline(c.writeDeadline())
+		return c.Connection.WriteMessage(websocket.TextMessage, data)
+	}
+	c.control.SetWriteDeadline(c.writeDeadline())
+	if _, err := c.control.Write(data); err != nil {
+		return err
+	}
+	_, err := c.control.Write([]byte{'\n'})
+	return err
+}
+
+// sendBinary 向 WebSocket 客户端转发一条二进制消息
+func (c *RelayClient) sendBinary(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Connection.SetWriteDeadline(c.writeDeadline())
+	return c.Connection.WriteMessage(websocket.BinaryMessage, data)
+}
+
+// transport 返回客户端使用的传输方式
+func (c *RelayClient) transport() string {
+	if c.Session != nil {
+		return "webtransport"
+	}
+	return "websocket"
+}
+
+// close 关闭客户端连接
+func (c *RelayClient) close() {
+	if c.Session != nil {
+		c.Session.CloseWithError(0, "replaced")
+		return
+	}
+	c.Connection.Close()
 }
 
 // RelayMessage 中继消息的包装格式
@@ -44,174 +201,127 @@ type RelayMessage struct {
 	Payload json.RawMessage `json:"payload,omitempty"` // JSON 消息体
 }
 
-func NewRelayService(webrtcService *WebRTCService) *RelayService {
-	return &RelayService{
+// fileMetadata 文件传输开始前发送方发出的 file-metadata 消息体
+type fileMetadata struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	Type string `json:"type"`
+}
+
+// publishFileShared 从转发的 file-metadata 消息中记录文件名和大小，其他消息忽略
+func (rs *RelayService) publishFileShared(room *RelayRoom, client *RelayClient, data []byte) {
+	var msg struct {
+		Type    string       `json:"type"`
+		Channel string       `json:"channel"`
+		Payload fileMetadata `json:"payload"`
+	}
+	if json.Unmarshal(data, &msg) != nil || msg.Type != "file-metadata" {
+		return
+	}
+	rs.webrtcService.bus.Publish(events.Event{
+		Type:       events.FileShared,
+		Room:       room.Code,
+		Identity:   client.Identity,
+		Role:       client.Role,
+		ClientID:   client.ID,
+		RemoteAddr: client.RemoteAddr,
+		Data: map[string]interface{}{
+			"file_id":   msg.Payload.ID,
+			"name":      msg.Payload.Name,
+			"size":      msg.Payload.Size,
+			"mime_type": msg.Payload.Type,
+			"channel":   msg.Channel,
+			"transport": client.transport(),
+			"owner":     room.Owner,
+		},
+	})
+}
+
+func NewRelayService(webrtcService *WebRTCService, opts Options) *RelayService {
+	rs := &RelayService{
 		rooms:         make(map[string]*RelayRoom),
 		roomsMux:      sync.RWMutex{},
 		webrtcService: webrtcService,
-		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				return true
-			},
-			// 增大消息尺寸限制以支持文件传输（10MB）
-			ReadBufferSize:  10 * 1024 * 1024,
-			WriteBufferSize: 10 * 1024 * 1024,
-		},
+	}
+	rs.opts.Store(opts)
+	// 信令房间超过最长使用时间时一并断开中继连接
+	closeRelay := func(code, reason string) {
+		rs.closeRoom(code, reason)
+	}
+	webrtcService.closeRelay.Store(&closeRelay)
+	return rs
+}
+
+// UpdateOptions 在运行时替换服务参数，只影响之后建立的中继连接
+func (rs *RelayService) UpdateOptions(opts Options) {
+	rs.opts.Store(opts)
+}
+
+// newUpgrader 按当前参数创建 WebSocket 升级器
+func (rs *RelayService) newUpgrader(opts Options) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		CheckOrigin: opts.Origins.CheckOrigin,
+		// 增大缓冲区以支持文件传输（默认 10MB）
+		ReadBufferSize:  opts.RelayBufferSize,
+		WriteBufferSize: opts.RelayBufferSize,
 	}
 }
 
 // HandleRelayWebSocket 处理中继 WebSocket 连接
 func (rs *RelayService) HandleRelayWebSocket(w http.ResponseWriter, r *http.Request) {
-	log.Printf("[Relay] 收到中继 WebSocket 连接请求: %s", r.URL.String())
+	logging.Infof("[Relay] 收到中继 WebSocket 连接请求: %s", r.URL.String())
 
-	conn, err := rs.upgrader.Upgrade(w, r, nil)
+	opts := rs.opts.Load()
+	conn, err := rs.newUpgrader(opts).Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("[Relay] WebSocket 升级失败: %v", err)
+		logging.Errorf("[Relay] WebSocket 升级失败: %v", err)
 		return
 	}
-	// 设置最大消息大小为 10MB
-	conn.SetReadLimit(10 * 1024 * 1024)
+	// 设置最大消息大小（默认 10MB）
+	conn.SetReadLimit(opts.RelayMaxMessageSize)
 	defer conn.Close()
 
 	// 获取参数
 	code := r.URL.Query().Get("code")
 	role := r.URL.Query().Get("role")
+	lang := i18n.Negotiate(r)
 
-	log.Printf("[Relay] 连接参数: code=%s, role=%s", code, role)
+	logging.Infof("[Relay] 连接参数: code=%s, role=%s", code, role)
 
-	if code == "" || (role != "sender" && role != "receiver") {
-		log.Printf("[Relay] 参数无效: code=%s, role=%s", code, role)
-		conn.WriteJSON(map[string]interface{}{
-			"type":  "error",
-			"error": "连接参数无效",
-		})
-		return
-	}
-
-	// 验证房间是否存在（通过 WebRTC service 验证）
-	status := rs.webrtcService.GetRoomStatus(code)
-	exists, _ := status["exists"].(bool)
-	if !exists {
-		log.Printf("[Relay] 房间不存在: %s", code)
-		conn.WriteJSON(map[string]interface{}{
-			"type":  "error",
-			"error": "房间不存在或已过期",
-		})
+	if errCode := rs.validateJoin(code, role); errCode != "" {
+		conn.WriteJSON(newRelayError(lang, errCode))
 		return
 	}
 
-	// 创建或获取中继房间
-	rs.roomsMux.Lock()
-	room, ok := rs.rooms[code]
-	if !ok {
-		room = &RelayRoom{
-			Code:      code,
-			CreatedAt: time.Now(),
-		}
-		rs.rooms[code] = room
-	}
-	rs.roomsMux.Unlock()
-
 	// 创建客户端
 	client := &RelayClient{
-		ID:         rs.webrtcService.generateClientID(),
-		Role:       role,
-		Connection: conn,
-	}
-
-	// 添加到房间
-	room.mu.Lock()
-	if role == "sender" {
-		// 关闭旧的 sender 连接
-		if room.Sender != nil {
-			room.Sender.Connection.Close()
-		}
-		room.Sender = client
-	} else {
-		// 关闭旧的 receiver 连接
-		if room.Receiver != nil {
-			room.Receiver.Connection.Close()
-		}
-		room.Receiver = client
+		ID:          rs.webrtcService.generateClientID(),
+		Role:        role,
+		ConnectedAt: time.Now(),
+		Identity:    requestIdentity(r),
+		RemoteAddr:  api.ClientIP(r),
+		Lang:        lang,
+		Connection:  conn,
+
+		writeTimeout: opts.WriteTimeout,
 	}
-
-	// 检查对方是否已连接，通知双方 relay 已就绪
-	peerConnected := false
-	if role == "sender" && room.Receiver != nil {
-		peerConnected = true
-	} else if role == "receiver" && room.Sender != nil {
-		peerConnected = true
+	room, errCode := rs.joinRoom(code, client)
+	if room == nil {
+		client.sendJSON(newRelayError(lang, errCode))
+		return
 	}
-	room.mu.Unlock()
-
-	log.Printf("[Relay] 客户端加入中继房间: ID=%s, Role=%s, Room=%s, 对方是否在线=%v", client.ID, role, code, peerConnected)
-
-	// 通知自己已就绪
-	conn.WriteJSON(map[string]interface{}{
-		"type":           "relay-ready",
-		"role":           role,
-		"peer_connected": peerConnected,
-	})
 
-	// 如果对方已连接，通知对方
-	if peerConnected {
-		room.mu.Lock()
-		var peer *RelayClient
-		if role == "sender" {
-			peer = room.Receiver
-		} else {
-			peer = room.Sender
-		}
-		room.mu.Unlock()
-
-		if peer != nil {
-			peer.mu.Lock()
-			peer.Connection.WriteJSON(map[string]interface{}{
-				"type":      "relay-peer-joined",
-				"peer_role": role,
-			})
-			peer.mu.Unlock()
-		}
-	}
+	// 启动心跳：中继连接同样需要及时发现失联的对端，避免占用房间
+	touch := func() { rs.webrtcService.touchRoom(code) }
+	touch()
+	stopHeartbeat := startHeartbeat(conn, opts, touch)
 
 	// 连接关闭时清理
+	reason := "closed"
 	defer func() {
-		room.mu.Lock()
-		if role == "sender" && room.Sender != nil && room.Sender.ID == client.ID {
-			room.Sender = nil
-		} else if role == "receiver" && room.Receiver != nil && room.Receiver.ID == client.ID {
-			room.Receiver = nil
-		}
-
-		// 通知对方断开
-		var peer *RelayClient
-		if role == "sender" {
-			peer = room.Receiver
-		} else {
-			peer = room.Sender
-		}
-
-		// 如果房间空了，清理
-		isEmpty := room.Sender == nil && room.Receiver == nil
-		room.mu.Unlock()
-
-		if peer != nil {
-			peer.mu.Lock()
-			peer.Connection.WriteJSON(map[string]interface{}{
-				"type":      "relay-peer-left",
-				"peer_role": role,
-			})
-			peer.mu.Unlock()
-		}
-
-		if isEmpty {
-			rs.roomsMux.Lock()
-			delete(rs.rooms, code)
-			rs.roomsMux.Unlock()
-			log.Printf("[Relay] 清理空的中继房间: %s", code)
-		}
-
-		log.Printf("[Relay] 客户端断开中继: ID=%s, Room=%s", client.ID, code)
+		stopHeartbeat()
+		rs.leaveRoom(room, client, reason)
 	}()
 
 	// 消息转发循环 - 带统计日志
@@ -220,19 +330,30 @@ func (rs *RelayService) HandleRelayWebSocket(w http.ResponseWriter, r *http.Requ
 	startTime := time.Now()
 	lastLogTime := startTime
 
-	log.Printf("[Relay] ▶ 开始消息转发: Room=%s, Role=%s", code, role)
+	logging.Infof("[Relay] ▶ 开始消息转发: Room=%s, Role=%s", code, role)
 
 	for {
 		msgType, data, err := conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
-				log.Printf("[Relay] 读取消息错误: Room=%s, Role=%s, err=%v", code, role, err)
+			if isTimeoutError(err) {
+				reason = "timeout"
+				logging.Warnf("[Relay] 心跳超时: Room=%s, Role=%s", code, role)
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				logging.Errorf("[Relay] 读取消息错误: Room=%s, Role=%s, err=%v", code, role, err)
 			}
 			break
 		}
+		extendReadDeadline(conn, opts)
+		touch()
 
 		dataLen := int64(len(data))
 
+		// 应用层心跳：直接回复 relay-pong，不转发给对方
+		if msgType == websocket.TextMessage && isRelayPing(data) {
+			client.sendJSON(RelayMessage{Type: "relay-pong"})
+			continue
+		}
+
 		// 统计消息类型
 		if msgType == websocket.TextMessage {
 			textMsgCount++
@@ -244,10 +365,10 @@ func (rs *RelayService) HandleRelayWebSocket(w http.ResponseWriter, r *http.Requ
 				Channel string `json:"channel"`
 			}
 			if json.Unmarshal(data, &peek) == nil {
-				log.Printf("[Relay] 📨 转发文本消息: Room=%s, %s→%s, type=%s, channel=%s, size=%d bytes",
+				logging.Debugf("[Relay] 📨 转发文本消息: Room=%s, %s→%s, type=%s, channel=%s, size=%d bytes",
 					code, role, peerRole(role), peek.Type, peek.Channel, dataLen)
 			} else {
-				log.Printf("[Relay] 📨 转发文本消息: Room=%s, %s→%s, size=%d bytes",
+				logging.Debugf("[Relay] 📨 转发文本消息: Room=%s, %s→%s, size=%d bytes",
 					code, role, peerRole(role), dataLen)
 			}
 		} else if msgType == websocket.BinaryMessage {
@@ -256,45 +377,220 @@ func (rs *RelayService) HandleRelayWebSocket(w http.ResponseWriter, r *http.Requ
 
 			// 二进制消息只在每 10 个包或每 5 秒输出一次摘要，避免日志过多
 			if binaryMsgCount%10 == 1 || time.Since(lastLogTime) > 5*time.Second {
-				log.Printf("[Relay] 📦 转发二进制数据: Room=%s, %s→%s, size=%d bytes (累计: %d 包, %s)",
+				logging.Debugf("[Relay] 📦 转发二进制数据: Room=%s, %s→%s, size=%d bytes (累计: %d 包, %s)",
 					code, role, peerRole(role), dataLen, binaryMsgCount, formatBytes(totalBinaryBytes))
 				lastLogTime = time.Now()
 			}
 		}
 
 		// 获取对方客户端
-		room.mu.Lock()
-		var peer *RelayClient
-		if role == "sender" {
-			peer = room.Receiver
-		} else {
-			peer = room.Sender
-		}
-		room.mu.Unlock()
-
+		peer := room.peerOf(role)
 		if peer == nil {
-			log.Printf("[Relay] ⚠ 对方不在线，丢弃消息: Room=%s, Role=%s, size=%d bytes", code, role, dataLen)
+			logging.Warnf("[Relay] ⚠ 对方不在线，丢弃消息: Room=%s, Role=%s, size=%d bytes", code, role, dataLen)
 			continue
 		}
 
-		// 直接转发消息（文本或二进制）
-		peer.mu.Lock()
-		err = peer.Connection.WriteMessage(msgType, data)
-		peer.mu.Unlock()
+		// 直接转发消息（文本或二进制）；joinRoom 保证双方使用相同的传输方式
+		if msgType == websocket.TextMessage {
+			err = peer.sendText(data)
+		} else {
+			err = peer.sendBinary(data)
+		}
 
 		if err != nil {
-			log.Printf("[Relay] ❌ 转发消息失败: Room=%s, %s→%s, err=%v", code, role, peerRole(role), err)
+			logging.Errorf("[Relay] ❌ 转发消息失败: Room=%s, %s→%s, err=%v", code, role, peerRole(role), err)
+			break
+		}
+		room.messages.Add(1)
+		if msgType == websocket.TextMessage {
+			rs.publishFileShared(room, client, data)
+		}
+		if err := rs.charge(room, role, dataLen); err != nil {
+			rs.closeOverQuota(room)
+			reason = "quota_exceeded"
 			break
 		}
 	}
 
 	elapsed := time.Since(startTime)
-	log.Printf("[Relay] ■ 消息转发结束: Room=%s, Role=%s, 持续=%v, 文本消息=%d(%s), 二进制消息=%d(%s)",
+	logging.Infof("[Relay] ■ 消息转发结束: Room=%s, Role=%s, 持续=%v, 文本消息=%d(%s), 二进制消息=%d(%s)",
 		code, role, elapsed.Round(time.Second),
 		textMsgCount, formatBytes(totalTextBytes),
 		binaryMsgCount, formatBytes(totalBinaryBytes))
 }
 
+// validateJoin 校验是否启用中继、连接参数、房间是否存在（通过 WebRTC service 验证）和创建者的中继流量配额，失败时返回错误码
+func (rs *RelayService) validateJoin(code, role string) string {
+	if !rs.opts.Load().RelayEnabled {
+		logging.Warnf("[Relay] 中继未启用，拒绝连接: code=%s", code)
+		return api.CodeRelayDisabled
+	}
+	if code == "" || (role != "sender" && role != "receiver") {
+		logging.Infof("[Relay] 参数无效: code=%s, role=%s", code, role)
+		return api.CodeInvalidParams
+	}
+	if !rs.webrtcService.roomExists(code) {
+		logging.Infof("[Relay] 房间不存在: %s", code)
+		return api.CodeRoomNotFound
+	}
+	if err := rs.checkQuota(code); err != nil {
+		return api.CodeRelayQuotaExceeded
+	}
+	return ""
+}
+
+// checkQuota 检查房间创建者的中继流量配额
+func (rs *RelayService) checkQuota(code string) error {
+	owner := rs.webrtcService.roomOwner(code)
+	return rs.webrtcService.ledger.CheckRelayBytes(owner, rs.opts.Load().Quota, time.Now())
+}
+
+// joinRoom 把客户端加入中继房间并通知双方，与传输方式无关
+// 文件数据在 WebSocket 中是二进制消息、在 WebTransport 中是单向流，无法互相转发，
+// 对方在线且使用不同的传输方式时拒绝加入，返回 nil 和错误码
+func (rs *RelayService) joinRoom(code string, client *RelayClient) (*RelayRoom, string) {
+	// 创建或获取中继房间
+	rs.roomsMux.Lock()
+	room, ok := rs.rooms[code]
+	if !ok {
+		room = &RelayRoom{
+			Code:       code,
+			Owner:      rs.webrtcService.roomOwner(code),
+			CreatedAt:  time.Now(),
+			totalBytes: &rs.totalBytes,
+		}
+		rs.rooms[code] = room
+	}
+	rs.roomsMux.Unlock()
+
+	if !ok {
+		rs.webrtcService.bus.Publish(events.Event{
+			Time:       room.CreatedAt,
+			Type:       events.RelaySessionStarted,
+			Room:       code,
+			Identity:   client.Identity,
+			Role:       client.Role,
+			ClientID:   client.ID,
+			RemoteAddr: client.RemoteAddr,
+			Data: map[string]interface{}{
+				"transport": client.transport(),
+				"owner":     room.Owner,
+			},
+		})
+	}
+
+	// 添加到房间
+	room.mu.Lock()
+	if other := room.peerLocked(client.Role); other != nil && other.transport() != client.transport() {
+		room.mu.Unlock()
+		logging.Warnf("[Relay] ⚠ 双方的传输方式不同，拒绝加入: Room=%s, Role=%s, %s≠%s",
+			code, client.Role, client.transport(), other.transport())
+		return nil, api.CodeRelayTransport
+	}
+	if client.Role == "sender" {
+		// 关闭旧的 sender 连接
+		if room.Sender != nil {
+			room.Sender.close()
+		}
+		room.Sender = client
+	} else {
+		// 关闭旧的 receiver 连接
+		if room.Receiver != nil {
+			room.Receiver.close()
+		}
+		room.Receiver = client
+	}
+	room.mu.Unlock()
+
+	// 检查对方是否已连接，通知双方 relay 已就绪
+	peer := room.peerOf(client.Role)
+	peerConnected := peer != nil
+
+	logging.Infof("[Relay] 客户端加入中继房间: ID=%s, Role=%s, Room=%s, 对方是否在线=%v", client.ID, client.Role, code, peerConnected)
+
+	// 通知自己已就绪
+	client.sendJSON(RelayReady{Type: "relay-ready", Role: client.Role, PeerConnected: peerConnected})
+
+	// 如果对方已连接，通知对方
+	if peer != nil {
+		peer.sendJSON(RelayPeerJoined{Type: "relay-peer-joined", PeerRole: client.Role})
+	}
+
+	return room, ""
+}
+
+// leaveRoom 把客户端移出中继房间并通知对方，房间为空时清理
+func (rs *RelayService) leaveRoom(room *RelayRoom, client *RelayClient, reason string) {
+	room.mu.Lock()
+	if client.Role == "sender" && room.Sender != nil && room.Sender.ID == client.ID {
+		room.Sender = nil
+	} else if client.Role == "receiver" && room.Receiver != nil && room.Receiver.ID == client.ID {
+		room.Receiver = nil
+	}
+
+	// 通知对方断开
+	var peer *RelayClient
+	if client.Role == "sender" {
+		peer = room.Receiver
+	} else {
+		peer = room.Sender
+	}
+
+	// 如果房间空了，清理
+	isEmpty := room.Sender == nil && room.Receiver == nil
+	room.mu.Unlock()
+
+	if peer != nil {
+		peer.sendJSON(RelayPeerLeft{Type: "relay-peer-left", PeerRole: client.Role, Reason: reason})
+	}
+
+	if isEmpty {
+		rs.roomsMux.Lock()
+		delete(rs.rooms, room.Code)
+		rs.roomsMux.Unlock()
+		logging.Infof("[Relay] 清理空的中继房间: %s", room.Code)
+		rs.webrtcService.bus.Publish(events.Event{
+			Type:       events.RelaySessionEnded,
+			Room:       room.Code,
+			Identity:   client.Identity,
+			Role:       client.Role,
+			ClientID:   client.ID,
+			RemoteAddr: client.RemoteAddr,
+			Data: map[string]interface{}{
+				"reason":           reason,
+				"owner":            room.Owner,
+				"sender_bytes":     room.senderBytes.Load(),
+				"receiver_bytes":   room.receiverBytes.Load(),
+				"messages":         room.messages.Load(),
+				"duration_seconds": int64(time.Since(room.CreatedAt).Seconds()),
+			},
+		})
+	}
+
+	logging.Infof("[Relay] 客户端断开中继: ID=%s, Room=%s, 原因=%s", client.ID, room.Code, reason)
+}
+
+// peerOf 返回指定角色的对方客户端
+func (room *RelayRoom) peerOf(role string) *RelayClient {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	return room.peerLocked(role)
+}
+
+// peerLocked 同 peerOf，调用方需持有 room.mu
+func (room *RelayRoom) peerLocked(role string) *RelayClient {
+	if role == "sender" {
+		return room.Receiver
+	}
+	return room.Sender
+}
+
+// isRelayPing 判断文本消息是否为应用层心跳
+func isRelayPing(data []byte) bool {
+	var msg RelayMessage
+	return json.Unmarshal(data, &msg) == nil && msg.Type == "relay-ping"
+}
+
 // peerRole 返回对方角色名
 func peerRole(role string) string {
 	if role == "sender" {
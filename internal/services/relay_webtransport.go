@@ -0,0 +1,230 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"chuan/internal/accounting"
+	"chuan/internal/api"
+	"chuan/internal/i18n"
+	"chuan/internal/logging"
+
+	"github.com/quic-go/webtransport-go"
+)
+
+// WebTransport 流/会话错误码
+const (
+	wtErrPeerOffline     webtransport.StreamErrorCode  = 1 // 对方不在线或不支持单向流
+	wtErrForwardFailed   webtransport.StreamErrorCode  = 2 // 转发失败
+	wtErrControlRequired webtransport.SessionErrorCode = 1 // 未在限定时间内打开控制流
+	wtErrJoinRejected    webtransport.SessionErrorCode = 2 // 无法加入中继房间，原因已通过控制流发送
+)
+
+// maxControlMessageSize 控制流上单行 JSON 消息的最大尺寸
+const maxControlMessageSize = 1 << 20
+
+// wtRejectGrace 拒绝加入时等待客户端读取错误消息的最长时间
+const wtRejectGrace = time.Second
+
+// HandleWebTransport 处理 WebTransport 中继会话（HTTP/3，P2P 失败时的降级方案）
+//
+// 与 WebSocket 中继共用房间和角色配对逻辑，传输约定如下：
+//   - 客户端建立会话后先打开一条双向流作为控制流，双方在其上交换按行分隔的 JSON 消息，
+//     内容与 WebSocket 中继的文本消息一致（relay-ready、relay-peer-joined、file-metadata 等）
+//   - 每个文件使用一条独立的单向流，服务端为对方打开新的单向流并原样转发，
+//     文件之间互不阻塞，避免单条 TCP 连接在弱网下的队头阻塞
+func (rs *RelayService) HandleWebTransport(server *webtransport.Server, w http.ResponseWriter, r *http.Request) {
+	logging.Infof("[Relay/WT] 收到 WebTransport 连接请求: %s", r.URL.String())
+
+	code := r.URL.Query().Get("code")
+	role := r.URL.Query().Get("role")
+	lang := api.Language(w, r)
+
+	if errCode := rs.validateJoin(code, role); errCode != "" {
+		status := http.StatusNotFound
+		switch errCode {
+		case api.CodeInvalidParams:
+			status = http.StatusBadRequest
+		case api.CodeRelayQuotaExceeded:
+			status = http.StatusTooManyRequests
+		case api.CodeRelayDisabled:
+			status = http.StatusForbidden
+		}
+		http.Error(w, i18n.T(lang, "error."+errCode), status)
+		return
+	}
+
+	session, err := server.Upgrade(w, r)
+	if err != nil {
+		logging.Errorf("[Relay/WT] WebTransport 升级失败: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// 等待客户端打开控制流
+	opts := rs.opts.Load()
+	ctx, cancel := context.WithTimeout(session.Context(), opts.PongTimeout)
+	control, err := session.AcceptStream(ctx)
+	cancel()
+	if err != nil {
+		logging.Errorf("[Relay/WT] 等待控制流失败: Room=%s, Role=%s, err=%v", code, role, err)
+		session.CloseWithError(wtErrControlRequired, "control stream required")
+		return
+	}
+
+	client := &RelayClient{
+		ID:          rs.webrtcService.generateClientID(),
+		Role:        role,
+		ConnectedAt: time.Now(),
+		Identity:    requestIdentity(r),
+		RemoteAddr:  api.ClientIP(r),
+		Lang:        lang,
+		Session:     session,
+		control:     control,
+
+		writeTimeout: opts.WriteTimeout,
+	}
+	room, errCode := rs.joinRoom(code, client)
+	if room == nil {
+		// 关闭会话会重置所有流，先等客户端读完错误消息（关闭控制流）或等待片刻
+		client.sendJSON(newRelayError(lang, errCode))
+		control.Close()
+		control.SetReadDeadline(time.Now().Add(wtRejectGrace))
+		io.Copy(io.Discard, control)
+		session.CloseWithError(wtErrJoinRejected, errCode)
+		return
+	}
+
+	touch := func() { rs.webrtcService.touchRoom(code) }
+	touch()
+
+	reason := "closed"
+	defer func() {
+		rs.leaveRoom(room, client, reason)
+		session.CloseWithError(0, "")
+	}()
+
+	// 文件数据：每条单向流转发给对方
+	go rs.acceptWebTransportStreams(room, client, touch)
+
+	// 控制消息：逐行读取并转发给对方
+	logging.Infof("[Relay/WT] ▶ 开始消息转发: Room=%s, Role=%s", code, role)
+	scanner := bufio.NewScanner(control)
+	scanner.Buffer(make([]byte, 64*1024), maxControlMessageSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		touch()
+
+		// 应用层心跳：直接回复 relay-pong，不转发给对方
+		if isRelayPing(line) {
+			client.sendJSON(RelayMessage{Type: "relay-pong"})
+			continue
+		}
+
+		peer := room.peerOf(role)
+		if peer == nil {
+			logging.Warnf("[Relay/WT] ⚠ 对方不在线，丢弃消息: Room=%s, Role=%s, size=%d bytes", code, role, len(line))
+			continue
+		}
+		if err := peer.sendText(line); err != nil {
+			logging.Errorf("[Relay/WT] ❌ 转发消息失败: Room=%s, %s→%s, err=%v", code, role, peerRole(role), err)
+			break
+		}
+		room.messages.Add(1)
+		rs.publishFileShared(room, client, line)
+		if err := rs.charge(room, role, int64(len(line))); err != nil {
+			rs.closeOverQuota(room)
+			reason = "quota_exceeded"
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil && isTimeoutError(err) {
+		reason = "timeout"
+	}
+
+	logging.Infof("[Relay/WT] ■ 消息转发结束: Room=%s, Role=%s", code, role)
+}
+
+// acceptWebTransportStreams 接收客户端打开的单向流并逐条转发
+func (rs *RelayService) acceptWebTransportStreams(room *RelayRoom, client *RelayClient, touch func()) {
+	for {
+		str, err := client.Session.AcceptUniStream(client.Session.Context())
+		if err != nil {
+			return
+		}
+		go rs.forwardWebTransportStream(room, client, str, touch)
+	}
+}
+
+// forwardWebTransportStream 为对方打开一条单向流并原样转发数据
+func (rs *RelayService) forwardWebTransportStream(room *RelayRoom, client *RelayClient, str *webtransport.ReceiveStream, touch func()) {
+	peer := room.peerOf(client.Role)
+	if peer == nil || peer.Session == nil {
+		// joinRoom 保证在线的对方也使用 WebTransport，这里只可能是对方不在线
+		logging.Warnf("[Relay/WT] ⚠ 对方不在线，拒绝数据流: Room=%s, Role=%s", room.Code, client.Role)
+		str.CancelRead(wtErrPeerOffline)
+		return
+	}
+
+	// 对方的并发流数量达到上限且迟迟不读取时，打开新流会一直等待
+	ctx, cancel := context.WithTimeout(client.Session.Context(), peer.writeTimeout)
+	out, err := peer.Session.OpenUniStreamSync(ctx)
+	cancel()
+	if err != nil {
+		logging.Errorf("[Relay/WT] ❌ 打开对方数据流失败: Room=%s, err=%v", room.Code, err)
+		str.CancelRead(wtErrForwardFailed)
+		return
+	}
+
+	startTime := time.Now()
+	room.messages.Add(1)
+	n, err := io.Copy(&touchWriter{w: out, deadline: out.SetWriteDeadline, timeout: peer.writeTimeout, touch: touch, count: func(n int) error {
+		return rs.charge(room, client.Role, int64(n))
+	}}, str)
+	if errors.Is(err, accounting.ErrRelayQuota) {
+		str.CancelRead(wtErrForwardFailed)
+		out.CancelWrite(wtErrForwardFailed)
+		rs.closeOverQuota(room)
+		return
+	}
+	if err != nil {
+		logging.Errorf("[Relay/WT] ❌ 数据流转发失败: Room=%s, %s→%s, 已转发=%s, err=%v",
+			room.Code, client.Role, peerRole(client.Role), formatBytes(n), err)
+		str.CancelRead(wtErrForwardFailed)
+		out.CancelWrite(wtErrForwardFailed)
+		return
+	}
+	out.Close()
+
+	logging.Infof("[Relay/WT] 📦 数据流转发完成: Room=%s, %s→%s, size=%s, 耗时=%v",
+		room.Code, client.Role, peerRole(client.Role), formatBytes(n), time.Since(startTime).Round(time.Millisecond))
+}
+
+// touchWriter 每次写入时记录房间活动和转发字节数，避免长时间的文件传输被判定为空闲
+// count 返回错误（如流量超出配额）时中止转发；每次写入前按 timeout 设置截止时间，对方停止读取时中止转发
+type touchWriter struct {
+	w        io.Writer
+	deadline func(time.Time) error
+	timeout  time.Duration
+	touch    func()
+	count    func(n int) error
+}
+
+func (t *touchWriter) Write(p []byte) (int, error) {
+	t.touch()
+	if t.timeout > 0 {
+		t.deadline(time.Now().Add(t.timeout))
+	}
+	n, err := t.w.Write(p)
+	if countErr := t.count(n); err == nil {
+		err = countErr
+	}
+	return n, err
+}
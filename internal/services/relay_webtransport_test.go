@@ -0,0 +1,280 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"chuan/internal/accounting"
+	"chuan/internal/api"
+	"chuan/internal/events"
+
+	"github.com/gorilla/websocket"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/quic-go/webtransport-go"
+)
+
+// relayTestEnv 本地环回的中继服务：WebSocket 走 httptest，WebTransport 走 UDP 上的 HTTP/3
+type relayTestEnv struct {
+	webrtc *WebRTCService
+	wsURL  string
+	wtURL  string
+}
+
+func newRelayTestEnv(t *testing.T, opts Options) *relayTestEnv {
+	t.Helper()
+	ledger, err := accounting.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ledger.Close() })
+	webrtcService := NewWebRTCService(opts, ledger, events.NewBus())
+	relayService := NewRelayService(webrtcService, opts)
+
+	udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	wtServer := &webtransport.Server{
+		H3: &http3.Server{
+			Handler:   mux,
+			TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: []tls.Certificate{testCertificate(t)}}),
+		},
+		CheckOrigin: func(*http.Request) bool { return true },
+	}
+	webtransport.ConfigureHTTP3Server(wtServer.H3)
+	mux.HandleFunc("/api/wt/relay", func(w http.ResponseWriter, r *http.Request) {
+		relayService.HandleWebTransport(wtServer, w, r)
+	})
+	go wtServer.Serve(udpConn)
+	t.Cleanup(func() { wtServer.Close() })
+
+	wsServer := httptest.NewServer(http.HandlerFunc(relayService.HandleRelayWebSocket))
+	t.Cleanup(wsServer.Close)
+	return &relayTestEnv{
+		webrtc: webrtcService,
+		wsURL:  "ws" + strings.TrimPrefix(wsServer.URL, "http") + "/api/ws/relay",
+		wtURL:  fmt.Sprintf("https://localhost:%d/api/wt/relay", udpConn.LocalAddr().(*net.UDPAddr).Port),
+	}
+}
+
+// testCertificate 生成 localhost 的自签名证书
+func testCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv}
+}
+
+// wtPeer WebTransport 中继客户端：会话加控制流
+type wtPeer struct {
+	session *webtransport.Session
+	control *webtransport.Stream
+	lines   *bufio.Scanner
+}
+
+func dialWebTransport(t *testing.T, ctx context.Context, env *relayTestEnv, code, role string) *wtPeer {
+	t.Helper()
+	dialer := webtransport.Dialer{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		QUICConfig:      &quic.Config{EnableDatagrams: true, EnableStreamResetPartialDelivery: true},
+	}
+	_, session, err := dialer.Dial(ctx, env.wtURL+"?code="+code+"&role="+role, nil)
+	if err != nil {
+		t.Fatalf("%s 建立 WebTransport 会话失败: %v", role, err)
+	}
+	t.Cleanup(func() { session.CloseWithError(0, "") })
+	control, err := session.OpenStreamSync(ctx)
+	if err != nil {
+		t.Fatalf("%s 打开控制流失败: %v", role, err)
+	}
+	// 控制流在第一次写入后才会被服务端接受
+	if _, err := control.Write([]byte("\n")); err != nil {
+		t.Fatal(err)
+	}
+	return &wtPeer{session: session, control: control, lines: bufio.NewScanner(control)}
+}
+
+// expect 读取控制流上的下一条消息，检查 type 后返回原始 JSON
+func (p *wtPeer) expect(t *testing.T, msgType string) map[string]any {
+	t.Helper()
+	p.control.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if !p.lines.Scan() {
+		t.Fatalf("等待 %s 失败: %v", msgType, p.lines.Err())
+	}
+	var msg map[string]any
+	if err := json.Unmarshal(p.lines.Bytes(), &msg); err != nil {
+		t.Fatalf("解析控制消息失败: %v (%s)", err, p.lines.Bytes())
+	}
+	if msg["type"] != msgType {
+		t.Fatalf("期望 %s, 实际 %s", msgType, p.lines.Bytes())
+	}
+	return msg
+}
+
+// TestWebTransportRelayLoopback 发送方打开单向流，接收方收到内容完全相同的单向流
+func TestWebTransportRelayLoopback(t *testing.T) {
+	env := newRelayTestEnv(t, DefaultOptions())
+	env.webrtc.CreateRoom("WTLOOP", "", time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sender := dialWebTransport(t, ctx, env, "WTLOOP", "sender")
+	sender.expect(t, "relay-ready")
+	receiver := dialWebTransport(t, ctx, env, "WTLOOP", "receiver")
+	if msg := receiver.expect(t, "relay-ready"); msg["peer_connected"] != true {
+		t.Fatalf("接收方加入时发送方应在线: %v", msg)
+	}
+	sender.expect(t, "relay-peer-joined")
+
+	// 控制消息原样转发
+	if _, err := sender.control.Write([]byte(`{"type":"file-metadata","name":"a.bin"}` + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	receiver.expect(t, "file-metadata")
+
+	// 文件数据：多个 QUIC 包大小，检查逐字节一致
+	payload := bytes.Repeat([]byte("chuan-relay-"), 64*1024)
+	out, err := sender.session.OpenUniStreamSync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		out.Write(payload)
+		out.Close()
+	}()
+
+	in, err := receiver.session.AcceptUniStream(ctx)
+	if err != nil {
+		t.Fatalf("接收方没有收到数据流: %v", err)
+	}
+	got, err := io.ReadAll(in)
+	if err != nil {
+		t.Fatalf("读取数据流失败: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("数据不一致: 收到 %d 字节, 期望 %d 字节", len(got), len(payload))
+	}
+}
+
+// TestRelayRejectsMixedTransports 一方使用 WebTransport 时，另一方用 WebSocket 加入会收到错误，
+// 而不是连接成功后文件数据被静默丢弃
+func TestRelayRejectsMixedTransports(t *testing.T) {
+	env := newRelayTestEnv(t, DefaultOptions())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("WebSocket 加入 WebTransport 房间", func(t *testing.T) {
+		env.webrtc.CreateRoom("MIXWT1", "", time.Minute)
+		sender := dialWebTransport(t, ctx, env, "MIXWT1", "sender")
+		sender.expect(t, "relay-ready")
+
+		conn, _, err := websocket.DefaultDialer.Dial(env.wsURL+"?code=MIXWT1&role=receiver", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var msg RelayError
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type != "error" || msg.Code != api.CodeRelayTransport {
+			t.Fatalf("期望 %s 错误, 实际 %+v", api.CodeRelayTransport, msg)
+		}
+		if _, _, err := conn.ReadMessage(); err == nil {
+			t.Fatal("被拒绝后连接应被关闭")
+		}
+	})
+
+	t.Run("WebTransport 加入 WebSocket 房间", func(t *testing.T) {
+		env.webrtc.CreateRoom("MIXWS1", "", time.Minute)
+		conn, _, err := websocket.DefaultDialer.Dial(env.wsURL+"?code=MIXWS1&role=sender", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		receiver := dialWebTransport(t, ctx, env, "MIXWS1", "receiver")
+		msg := receiver.expect(t, "error")
+		if msg["code"] != api.CodeRelayTransport {
+			t.Fatalf("期望 %s 错误, 实际 %v", api.CodeRelayTransport, msg)
+		}
+		select {
+		case <-receiver.session.Context().Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("被拒绝后会话应被关闭")
+		}
+	})
+}
+
+// TestRelayWriteTimeout 对方停止读取时，写入在 WriteTimeout 后失败，而不是一直阻塞发送方
+func TestRelayWriteTimeout(t *testing.T) {
+	opts := DefaultOptions()
+	opts.WriteTimeout = 200 * time.Millisecond
+	env := newRelayTestEnv(t, opts)
+	env.webrtc.CreateRoom("STALL1", "", time.Minute)
+
+	receiver, _, err := websocket.DefaultDialer.Dial(env.wsURL+"?code=STALL1&role=receiver", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer receiver.Close()
+	sender, _, err := websocket.DefaultDialer.Dial(env.wsURL+"?code=STALL1&role=sender", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	// 接收方从不读取，发送方持续写入：服务端转发写满缓冲区后应超时并断开发送方
+	chunk := make([]byte, 256*1024)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			sender.SetWriteDeadline(time.Now().Add(5 * time.Second))
+			if err := sender.WriteMessage(websocket.BinaryMessage, chunk); err != nil {
+				return
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("接收方停止读取后，发送方被一直阻塞")
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"chuan/internal/logging"
+)
+
+// PickupCodeChars 取件码字符集：大写字母和数字，排除容易混淆的数字0和字母O
+const PickupCodeChars = "123456789ABCDEFGHIJKLMNPQRSTUVWXYZ"
+
+var (
+	// ErrInvalidCode 指定的房间码长度不对或包含取件码字符集之外的字符
+	ErrInvalidCode = errors.New("房间码无效")
+	// ErrCodeReserved 房间码已保留给其他身份
+	ErrCodeReserved = errors.New("房间码已被保留")
+	// ErrCodeTaken 房间码正在被其他房间使用
+	ErrCodeTaken = errors.New("房间码已被占用")
+)
+
+// NormalizePickupCode 把用户输入的房间码转换为大写
+func NormalizePickupCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// ValidPickupCode 判断房间码是否为指定长度且只包含取件码字符
+func ValidPickupCode(code string, length int) bool {
+	if len(code) != length {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		if strings.IndexByte(PickupCodeChars, code[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ReservedCode 保留给指定身份的房间码
+type ReservedCode struct {
+	Code  string
+	Owner string // 只有该身份（如 api_key:qa-bot）可以创建此房间
+}
+
+// reservedOwner 返回房间码的保留者
+func (opts Options) reservedOwner(code string) (string, bool) {
+	for _, r := range opts.ReservedCodes {
+		if r.Code == code {
+			return r.Owner, true
+		}
+	}
+	return "", false
+}
+
+// isPersistent 判断房间码是否配置为持久房间
+func (opts Options) isPersistent(code string) bool {
+	for _, c := range opts.PersistentCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// syncPersistentRooms 创建配置中新增的持久房间，移出配置的房间恢复为普通房间，之后按普通规则过期清理
+func (ws *WebRTCService) syncPersistentRooms(opts Options) {
+	ws.roomsMux.Lock()
+	defer ws.roomsMux.Unlock()
+
+	for _, room := range ws.rooms {
+		if room.Persistent && !opts.isPersistent(room.Code) {
+			room.Persistent = false
+			logging.Infof("房间不再是持久房间: %s", room.Code)
+		}
+	}
+
+	now := time.Now()
+	for _, code := range opts.PersistentCodes {
+		owner, _ := opts.reservedOwner(code)
+		if room := ws.rooms[code]; room != nil {
+			room.Persistent = true
+			room.Owner = owner
+			continue
+		}
+		room := &WebRTCRoom{
+			Code:       code,
+			Owner:      owner,
+			Persistent: true,
+			CreatedAt:  now,
+			ExpiresAt:  now.Add(opts.RoomTTL),
+		}
+		room.lastSeen.Store(now.UnixNano())
+		ws.rooms[code] = room
+		logging.Infof("创建持久房间: %s", code)
+	}
+}
+
+// resetRoom 重置空的持久房间，使其重新开始计算有效期，调用方需持有 ws.roomsMux
+func (ws *WebRTCService) resetRoom(room *WebRTCRoom, now time.Time) {
+	room.ExpiresAt = now.Add(ws.opts.Load().RoomTTL)
+	room.lastSeen.Store(now.UnixNano())
+}
@@ -0,0 +1,431 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"chuan/internal/api"
+	"chuan/internal/events"
+	"chuan/internal/i18n"
+	"chuan/internal/logging"
+
+	"github.com/gorilla/websocket"
+)
+
+// 房间事件流中的状态变化，由事件总线上的房间事件转换而来
+const (
+	RoomEventCreated         = "created"
+	RoomEventSenderOnline    = "sender_online"
+	RoomEventSenderOffline   = "sender_offline"
+	RoomEventReceiverOnline  = "receiver_online"
+	RoomEventReceiverOffline = "receiver_offline"
+	RoomEventRelayStarted    = "relay_started"
+	RoomEventRelayEnded      = "relay_ended"
+	RoomEventExpired         = "expired" // 终止事件，推送后事件流结束
+)
+
+// RoomEventsSubprotocol 通过 WebSocket 订阅房间事件时使用的子协议
+const RoomEventsSubprotocol = "chuan.room-events"
+
+const (
+	roomEventHistory   = 64              // 每个房间保留的最近事件数，用于 Last-Event-ID 断线续传
+	roomEventRetention = 5 * time.Minute // 房间过期后事件记录的保留时间，供断线的客户端取回 expired 事件
+	roomEventQueueSize = 32              // 每个订阅者的待推送队列，溢出时断开，由客户端带 Last-Event-ID 重连
+	roomEventRetry     = 3000            // 建议 EventSource 断线后的重连间隔（毫秒）
+)
+
+// RoomEvent 推送给等待页面和脚本的房间状态变化，ID 在进程内单调递增
+type RoomEvent struct {
+	ID   uint64                 `json:"id"`
+	Type string                 `json:"type"`
+	Room string                 `json:"room"`
+	Time time.Time              `json:"time"`
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+// roomEventLog 一个房间的最近事件和订阅者
+type roomEventLog struct {
+	events      []RoomEvent
+	subscribers map[chan RoomEvent]struct{}
+	ended       bool // 已推送 expired，之后只保留记录，不再接收新事件
+}
+
+// roomEventHub 把事件总线上的房间事件转换为带序号的状态变化，分发给订阅者
+type roomEventHub struct {
+	mu    sync.Mutex
+	seq   uint64 // 以启动时间为起点，重启后 ID 仍然递增，旧的 Last-Event-ID 不会跳过新事件
+	rooms map[string]*roomEventLog
+}
+
+func newRoomEventHub(bus *events.Bus) *roomEventHub {
+	hub := &roomEventHub{
+		seq:   uint64(time.Now().UnixMicro()),
+		rooms: make(map[string]*roomEventLog),
+	}
+	if bus != nil {
+		bus.Subscribe(hub.handle)
+	}
+	return hub
+}
+
+// roomEventFrom 把总线事件转换为房间状态变化，不关心的事件返回 false
+// 只保留状态相关的字段，不向订阅者暴露身份和地址
+func roomEventFrom(e events.Event) (RoomEvent, bool) {
+	re := RoomEvent{Room: e.Room, Time: e.Time}
+	switch e.Type {
+	case events.RoomCreated:
+		re.Type = RoomEventCreated
+		re.Data = pick(e.Data, "ttl_seconds")
+	case events.ParticipantJoined, events.ParticipantLeft:
+		online := e.Type == events.ParticipantJoined
+		switch {
+		case e.Role == "sender" && online:
+			re.Type = RoomEventSenderOnline
+		case e.Role == "sender":
+			re.Type = RoomEventSenderOffline
+		case online:
+			re.Type = RoomEventReceiverOnline
+		default:
+			re.Type = RoomEventReceiverOffline
+		}
+		re.Data = pick(e.Data, "transport", "reason")
+	case events.RelaySessionStarted:
+		re.Type = RoomEventRelayStarted
+		re.Data = pick(e.Data, "transport")
+	case events.RelaySessionEnded:
+		re.Type = RoomEventRelayEnded
+		re.Data = pick(e.Data, "reason", "sender_bytes", "receiver_bytes", "duration_seconds")
+	case events.RoomExpired:
+		re.Type = RoomEventExpired
+		re.Data = pick(e.Data, "reason")
+	case events.AdminRoomClosed:
+		// 持久房间被关闭时只断开连接，房间本身仍然存在
+		if persistent, _ := e.Data["persistent"].(bool); persistent {
+			return RoomEvent{}, false
+		}
+		re.Type = RoomEventExpired
+		re.Data = map[string]interface{}{"reason": ReasonClosedByAdmin}
+	default:
+		return RoomEvent{}, false
+	}
+	return re, true
+}
+
+// pick 复制 data 中指定的字段
+func pick(data map[string]interface{}, keys ...string) map[string]interface{} {
+	var out map[string]interface{}
+	for _, k := range keys {
+		if v, ok := data[k]; ok {
+			if out == nil {
+				out = make(map[string]interface{}, len(keys))
+			}
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// handle 记录事件并推送给订阅者，在发布者的 goroutine 中调用，不会阻塞
+func (hub *roomEventHub) handle(e events.Event) {
+	re, ok := roomEventFrom(e)
+	if !ok || re.Room == "" {
+		return
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	rl := hub.rooms[re.Room]
+	if re.Type == RoomEventCreated && rl != nil && rl.ended {
+		// 房间码被重新使用，开始新的记录；旧记录的订阅者已在过期时断开
+		rl = nil
+	}
+	if rl == nil {
+		rl = &roomEventLog{subscribers: make(map[chan RoomEvent]struct{})}
+		hub.rooms[re.Room] = rl
+	}
+	if rl.ended {
+		// 过期后断开连接产生的离开事件不再推送
+		return
+	}
+
+	hub.seq++
+	re.ID = hub.seq
+	rl.events = append(rl.events, re)
+	if len(rl.events) > roomEventHistory {
+		rl.events = slices.Delete(rl.events, 0, len(rl.events)-roomEventHistory)
+	}
+
+	for ch := range rl.subscribers {
+		select {
+		case ch <- re:
+		default:
+			logging.Debugf("房间事件订阅者处理过慢，断开: %s", re.Room)
+			delete(rl.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	if re.Type == RoomEventExpired {
+		rl.ended = true
+		for ch := range rl.subscribers {
+			delete(rl.subscribers, ch)
+			close(ch)
+		}
+		time.AfterFunc(roomEventRetention, func() {
+			hub.mu.Lock()
+			defer hub.mu.Unlock()
+			if hub.rooms[re.Room] == rl {
+				delete(hub.rooms, re.Room)
+			}
+		})
+	}
+}
+
+// known 判断房间是否有事件记录
+func (hub *roomEventHub) known(code string) bool {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	return hub.rooms[code] != nil
+}
+
+// subscribe 返回需要补发的事件和之后的事件通道；afterID 为 0 时补发保留的全部事件
+// 房间已结束时 ended 为 true，通道立即关闭
+func (hub *roomEventHub) subscribe(code string, afterID uint64) (replay []RoomEvent, updates <-chan RoomEvent, cancel func(), ended bool) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	rl := hub.rooms[code]
+	if rl == nil {
+		rl = &roomEventLog{subscribers: make(map[chan RoomEvent]struct{})}
+		hub.rooms[code] = rl
+	}
+
+	for _, re := range rl.events {
+		if re.ID > afterID {
+			replay = append(replay, re)
+		}
+	}
+
+	ch := make(chan RoomEvent, roomEventQueueSize)
+	if rl.ended {
+		close(ch)
+		return replay, ch, func() {}, true
+	}
+	rl.subscribers[ch] = struct{}{}
+
+	cancel = func() {
+		hub.mu.Lock()
+		defer hub.mu.Unlock()
+		if _, ok := rl.subscribers[ch]; ok {
+			delete(rl.subscribers, ch)
+			close(ch)
+		}
+		// 没有事件也没有订阅者的记录（如尚无活动的持久房间）不保留
+		if len(rl.events) == 0 && len(rl.subscribers) == 0 && hub.rooms[code] == rl {
+			delete(hub.rooms, code)
+		}
+	}
+	return replay, ch, cancel, false
+}
+
+// ended 判断房间的事件流是否已结束（已推送 expired）
+func (hub *roomEventHub) ended(code string) bool {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	rl := hub.rooms[code]
+	return rl != nil && rl.ended
+}
+
+// lastEventID 读取断线续传的位置：EventSource 重连时自动携带 Last-Event-ID 请求头，
+// 浏览器的 WebSocket 无法设置请求头，可改用 last_event_id 查询参数
+func lastEventID(r *http.Request) uint64 {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	id, _ := strconv.ParseUint(value, 10, 64)
+	return id
+}
+
+// roomExists 判断房间是否存在（包括尚未被清理的已过期房间）
+func (ws *WebRTCService) roomExists(code string) bool {
+	ws.roomsMux.RLock()
+	defer ws.roomsMux.RUnlock()
+	return ws.rooms[code] != nil
+}
+
+// HandleRoomEvents 推送房间状态变化，代替轮询 /api/room-info
+// 默认以 SSE 推送；请求 WebSocket 升级并携带 chuan.room-events 子协议时改用 WebSocket，每条消息为一个 RoomEvent
+func (ws *WebRTCService) HandleRoomEvents(w http.ResponseWriter, r *http.Request, code string) {
+	if code == "" || (!ws.roomExists(code) && !ws.roomEvents.known(code)) {
+		if api.IsVersioned(r.Context()) {
+			api.WriteError(w, r, http.StatusNotFound, api.CodeRoomNotFound, nil)
+			return
+		}
+		api.WriteLegacyError(w, r, http.StatusNotFound, api.CodeRoomNotFound)
+		return
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		if !slices.Contains(websocket.Subprotocols(r), RoomEventsSubprotocol) {
+			if api.IsVersioned(r.Context()) {
+				api.WriteError(w, r, http.StatusBadRequest, api.CodeInvalidRequest, map[string]interface{}{
+					"subprotocol": RoomEventsSubprotocol,
+				})
+				return
+			}
+			api.WriteJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"code":    api.CodeInvalidRequest,
+				"message": i18n.T(api.Language(w, r), "room_events.subprotocol_required", RoomEventsSubprotocol),
+			})
+			return
+		}
+		ws.serveRoomEventsWebSocket(w, r, code)
+		return
+	}
+	ws.serveRoomEventsSSE(w, r, code)
+}
+
+// serveRoomEventsSSE 以 SSE 推送房间事件，事件 ID 写入 id 字段供 EventSource 断线续传
+func (ws *WebRTCService) serveRoomEventsSSE(w http.ResponseWriter, r *http.Request, code string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	replay, ch, cancel, ended := ws.roomEvents.subscribe(code, lastEventID(r))
+	defer cancel()
+
+	// 房间已结束且没有需要补发的事件：返回 204，EventSource 收到后不再重连
+	if ended && len(replay) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	opts := ws.opts.Load()
+	write := func(re RoomEvent) error {
+		data, err := json.Marshal(re)
+		if err != nil {
+			return err
+		}
+		rc.SetWriteDeadline(time.Now().Add(opts.WriteTimeout))
+		_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", re.ID, re.Type, data)
+		return err
+	}
+
+	rc.SetWriteDeadline(time.Now().Add(opts.WriteTimeout))
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", roomEventRetry); err != nil {
+		return
+	}
+	for _, re := range replay {
+		if err := write(re); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(opts.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case re, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := write(re); err != nil {
+				logging.Debugf("写入房间事件失败: %s: %v", code, err)
+				return
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			rc.SetWriteDeadline(time.Now().Add(opts.WriteTimeout))
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// serveRoomEventsWebSocket 以 WebSocket 推送房间事件，房间结束后以正常关闭帧断开
+func (ws *WebRTCService) serveRoomEventsWebSocket(w http.ResponseWriter, r *http.Request, code string) {
+	upgrader := websocket.Upgrader{
+		CheckOrigin:  ws.CheckOrigin,
+		Subprotocols: []string{RoomEventsSubprotocol},
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logging.Errorf("房间事件 WebSocket 升级失败: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	replay, ch, cancel, _ := ws.roomEvents.subscribe(code, lastEventID(r))
+	defer cancel()
+
+	opts := ws.opts.Load()
+	stopHeartbeat := startHeartbeat(conn, opts, nil)
+	defer stopHeartbeat()
+
+	// 客户端不需要发送消息，读循环只用于处理 pong 和检测断开
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	write := func(re RoomEvent) error {
+		conn.SetWriteDeadline(time.Now().Add(opts.WriteTimeout))
+		return conn.WriteJSON(re)
+	}
+	for _, re := range replay {
+		if err := write(re); err != nil {
+			return
+		}
+	}
+
+	for {
+		select {
+		case <-closed:
+			return
+		case re, ok := <-ch:
+			if !ok {
+				// 房间结束时正常关闭；推送过慢被断开时提示客户端带 last_event_id 重连
+				message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "room ended")
+				if !ws.roomEvents.ended(code) {
+					message = websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "reconnect with last_event_id")
+				}
+				conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(controlWriteTimeout))
+				return
+			}
+			if err := write(re); err != nil {
+				logging.Debugf("写入房间事件失败: %s: %v", code, err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"chuan/internal/i18n"
+)
+
+// 房间过期原因，记入 room_expired 事件，max_lifetime 同时作为客户端收到的断开原因
+const (
+	ExpireReasonTTL         = "expired"      // 创建后无人使用，超过有效期
+	ExpireReasonIdle        = "idle"         // 所有人离开后空闲超过 IdleTimeout
+	ExpireReasonMaxLifetime = "max_lifetime" // 超过最长使用时间，连接会被断开
+)
+
+// ErrInvalidTTL 请求的房间有效期超出允许范围
+var ErrInvalidTTL = errors.New("房间有效期超出允许范围")
+
+// roomTTL 返回创建房间时使用的有效期，requested 为 0 时使用默认值
+func (opts Options) roomTTL(requested time.Duration) (time.Duration, error) {
+	if requested == 0 {
+		return opts.RoomTTL, nil
+	}
+	if requested < opts.MinRoomTTL || requested > opts.MaxRoomTTL {
+		return 0, fmt.Errorf("%w: %v - %v", ErrInvalidTTL, opts.MinRoomTTL, opts.MaxRoomTTL)
+	}
+	return requested, nil
+}
+
+// expireReason 按房间策略判断房间是否应被清理，返回过期原因，未过期时返回空字符串，调用方需持有 ws.roomsMux
+//   - 持久房间永不过期
+//   - 超过最长使用时间（MaxLifetime）的房间无论是否有人连接都会被关闭
+//   - 有信令客户端在线的房间保留，不受有效期和空闲时间限制
+//   - 无人在线时：超过有效期（ExpiresAt）即过期；有过活动的房间空闲超过 IdleTimeout 也会过期
+func (room *WebRTCRoom) expireReason(now time.Time, opts Options) string {
+	if room.Persistent {
+		return ""
+	}
+	if opts.MaxLifetime > 0 && now.Sub(room.CreatedAt) >= opts.MaxLifetime {
+		return ExpireReasonMaxLifetime
+	}
+	if room.Sender != nil || room.Receiver != nil {
+		return ""
+	}
+	if now.After(room.ExpiresAt) {
+		return ExpireReasonTTL
+	}
+	lastSeen := room.LastSeen()
+	if opts.IdleTimeout > 0 && lastSeen.After(room.CreatedAt) && now.Sub(lastSeen) >= opts.IdleTimeout {
+		return ExpireReasonIdle
+	}
+	return ""
+}
+
+// disconnectClients 通知信令客户端房间已关闭后断开连接，提示信息为 "disconnect."+reason 按各客户端的语言本地化
+func disconnectClients(clients []*WebRTCClient, reason string) {
+	for _, c := range clients {
+		c.SendAndClose(&WebRTCMessage{
+			Type: "disconnection",
+			To:   c.ID,
+			Payload: DisconnectionPayload{
+				Role:    c.Role,
+				Reason:  reason,
+				Message: i18n.T(c.Lang, "disconnect."+reason),
+			},
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"chuan/internal/api"
+	"chuan/internal/logging"
+)
+
+// maxSignalBodySize 通过 HTTP POST 提交的单条信令消息的最大尺寸
+const maxSignalBodySize = 1 << 20
+
+// HandleSSE 处理 SSE 信令连接（WebSocket 被代理拦截时的降级方案）
+// 服务端 → 客户端的消息以 SSE 事件推送，客户端 → 服务端的消息通过 HandleSignalPost 提交
+func (ws *WebRTCService) HandleSSE(w http.ResponseWriter, r *http.Request, code string) {
+	role := r.URL.Query().Get("role")
+	logging.Infof("收到SSE信令连接请求: code=%s, role=%s", code, role)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// SSE 是长连接，取消服务器级别的写超时，改为逐条消息设置
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // 禁止 nginx 缓冲
+	w.WriteHeader(http.StatusOK)
+
+	opts := ws.opts.Load()
+	client := ws.newClient(r, code, role, nil, opts)
+	client.token = generateToken()
+
+	// 首条消息告知客户端 ID 和令牌，之后提交信令时需要携带
+	client.Send(&WebRTCMessage{
+		Type: "connected",
+		To:   client.ID,
+		Payload: ConnectedPayload{
+			ClientID: client.ID,
+			Token:    client.token,
+			Role:     role,
+		},
+	})
+
+	room, errCode := ws.joinRoom(code, role, client)
+	if room == nil {
+		data, _ := json.Marshal(newSignalError(client.Lang, errCode))
+		writeSSEEvent(w, data)
+		flusher.Flush()
+		return
+	}
+
+	reason := "closed"
+	defer func() {
+		ws.leaveRoom(client, reason)
+	}()
+
+	// SSE 没有 pong，依靠定期写入注释行保活，并由连接断开（写失败或请求上下文取消）判定对端失联
+	ticker := time.NewTicker(opts.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-client.writer.done:
+			return
+		case data := <-client.writer.send:
+			if data == nil {
+				client.Close()
+				return
+			}
+			rc.SetWriteDeadline(time.Now().Add(opts.WriteTimeout))
+			if err := writeSSEEvent(w, data); err != nil {
+				logging.Errorf("写入SSE信令失败: %s (房间: %s): %v", client.ID, code, err)
+				reason = "timeout"
+				return
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			rc.SetWriteDeadline(time.Now().Add(opts.WriteTimeout))
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				reason = "timeout"
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// HandleSignalPost 处理 SSE 客户端通过 HTTP POST 提交的信令消息
+func (ws *WebRTCService) HandleSignalPost(w http.ResponseWriter, r *http.Request, code string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	clientID := r.Header.Get("X-Client-ID")
+	if clientID == "" {
+		clientID = r.URL.Query().Get("client_id")
+	}
+	token := r.Header.Get("X-Signal-Token")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+
+	client, room := ws.findClient(code, clientID)
+	if client == nil || client.token == "" || subtle.ConstantTimeCompare([]byte(client.token), []byte(token)) != 1 {
+		api.WriteLegacyError(w, r, http.StatusForbidden, api.CodeInvalidSignalToken)
+		return
+	}
+
+	var msg WebRTCMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSignalBodySize)).Decode(&msg); err != nil || msg.Type == "" {
+		api.WriteLegacyError(w, r, http.StatusBadRequest, api.CodeInvalidMessage)
+		return
+	}
+
+	ws.handleClientMessage(room, client, &msg)
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// findClient 按客户端ID查找房间内的客户端
+func (ws *WebRTCService) findClient(code, clientID string) (*WebRTCClient, *WebRTCRoom) {
+	ws.roomsMux.RLock()
+	defer ws.roomsMux.RUnlock()
+
+	room := ws.rooms[code]
+	if room == nil || clientID == "" {
+		return nil, nil
+	}
+	if room.Sender != nil && room.Sender.ID == clientID {
+		return room.Sender, room
+	}
+	if room.Receiver != nil && room.Receiver.ID == clientID {
+		return room.Receiver, room
+	}
+	return nil, nil
+}
+
+// writeSSEEvent 写入一条 SSE 事件，data 为已编码的 JSON
+func writeSSEEvent(w http.ResponseWriter, data []byte) error {
+	_, err := fmt.Fprintf(w, "data: %s\n\n", data)
+	return err
+}
+
+// generateToken 生成随机令牌
+func generateToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -2,12 +2,19 @@ package services
 
 import (
 	"fmt"
-	"log"
 	"math/rand"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"chuan/internal/accounting"
+	"chuan/internal/api"
+	"chuan/internal/auth"
+	"chuan/internal/events"
+	"chuan/internal/i18n"
+	"chuan/internal/logging"
+
 	"github.com/gorilla/websocket"
 )
 
@@ -15,32 +22,72 @@ type WebRTCService struct {
 	rooms    map[string]*WebRTCRoom
 	roomsMux sync.RWMutex
 	upgrader websocket.Upgrader
+	opts     optionsValue
+	ledger   *accounting.Ledger // 按房间创建者统计用量
+	bus      *events.Bus        // 房间生命周期和传输事件，供审计日志等订阅
+
+	roomEvents *roomEventHub // 推送给等待页面的房间状态变化
+
+	// closeRelay 断开房间内的中继连接，由 NewRelayService 设置（清理协程已在运行，需原子读写）
+	closeRelay atomic.Pointer[func(code, reason string)]
 }
 
 type WebRTCRoom struct {
-	Code      string
-	Sender    *WebRTCClient
-	Receiver  *WebRTCClient
-	CreatedAt time.Time
-	ExpiresAt time.Time      // 添加过期时间
+	Code       string
+	Owner      string // 创建者身份，匿名创建时为空
+	Persistent bool   // 持久房间不会过期，为空时只重置有效期
+	Sender     *WebRTCClient
+	Receiver   *WebRTCClient
+	CreatedAt  time.Time
+	ExpiresAt  time.Time    // 添加过期时间
+	lastSeen   atomic.Int64 // 最近一次收到房间内客户端数据的时间（UnixNano）
+}
+
+// LastSeen 返回最近一次收到房间内客户端数据的时间
+func (r *WebRTCRoom) LastSeen() time.Time {
+	return time.Unix(0, r.lastSeen.Load())
+}
+
+// touch 记录房间内客户端的活动时间
+func (r *WebRTCRoom) touch() {
+	r.lastSeen.Store(time.Now().UnixNano())
 }
 
 type WebRTCClient struct {
-	ID         string
-	Role       string // "sender" or "receiver"
-	Connection *websocket.Conn
-	Room       string
+	ID          string
+	Role        string // "sender" or "receiver"
+	ConnectedAt time.Time
+	Connection  *websocket.Conn
+	Room        string
+	Identity    string // 加入房间时通过身份验证的身份，匿名时为空
+	RemoteAddr  string
+	Lang        string        // 连接时协商的语言，用于本地化发给客户端的提示信息
+	writer      *clientWriter // 出站队列，所有写操作经由 Send 投递
+	token       string        // SSE 客户端提交信令时使用的令牌
+}
+
+// transport 返回客户端使用的信令传输方式
+func (c *WebRTCClient) transport() string {
+	if c.Connection == nil {
+		return "sse"
+	}
+	return "websocket"
 }
 
-func NewWebRTCService() *WebRTCService {
+func NewWebRTCService(opts Options, ledger *accounting.Ledger, bus *events.Bus) *WebRTCService {
 	service := &WebRTCService{
 		rooms:    make(map[string]*WebRTCRoom),
 		roomsMux: sync.RWMutex{},
-		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				return true // 允许所有来源，生产环境应当限制
-			},
-		},
+		ledger:   ledger,
+		bus:      bus,
+
+		roomEvents: newRoomEventHub(bus),
+	}
+	service.opts.Store(opts)
+	service.syncPersistentRooms(opts)
+	service.upgrader = websocket.Upgrader{
+		// 按当前生效的来源策略校验，防止跨站 WebSocket 劫持
+		CheckOrigin: service.CheckOrigin,
 	}
 
 	// 启动房间清理任务
@@ -58,11 +105,11 @@ type WebRTCMessage struct {
 
 // HandleWebSocket 处理WebRTC信令WebSocket连接
 func (ws *WebRTCService) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	log.Printf("收到WebRTC WebSocket连接请求: %s", r.URL.String())
+	logging.Infof("收到WebRTC WebSocket连接请求: %s", r.URL.String())
 
 	conn, err := ws.upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("WebRTC WebSocket升级失败: %v", err)
+		logging.Errorf("WebRTC WebSocket升级失败: %v", err)
 		return
 	}
 	defer conn.Close()
@@ -71,77 +118,25 @@ func (ws *WebRTCService) HandleWebSocket(w http.ResponseWriter, r *http.Request)
 	code := r.URL.Query().Get("code")
 	role := r.URL.Query().Get("role")
 
-	log.Printf("WebRTC连接参数: code=%s, role=%s", code, role)
-
-	if code == "" || (role != "sender" && role != "receiver") {
-		log.Printf("WebRTC连接参数无效: code=%s, role=%s", code, role)
-		conn.WriteJSON(map[string]interface{}{
-			"type":    "error",
-			"message": "连接参数无效",
-		})
-		return
-	}
-
-	// 验证房间是否存在
-	ws.roomsMux.RLock()
-	room := ws.rooms[code]
-	ws.roomsMux.RUnlock()
+	logging.Infof("WebRTC连接参数: code=%s, role=%s", code, role)
 
+	opts := ws.opts.Load()
+	client := ws.newClient(r, code, role, conn, opts)
+	room, errCode := ws.joinRoom(code, role, client)
 	if room == nil {
-		log.Printf("房间不存在: %s", code)
-		conn.WriteJSON(map[string]interface{}{
-			"type":    "error",
-			"message": "房间不存在或已过期",
-		})
-		return
-	}
-
-	// 检查房间是否已过期
-	if time.Now().After(room.ExpiresAt) {
-		log.Printf("房间已过期: %s", code)
-		conn.WriteJSON(map[string]interface{}{
-			"type":    "error",
-			"message": "房间已过期",
-		})
-		return
-	}
-
-	// 检查房间是否已满（两个连接都已存在）
-	ws.roomsMux.RLock()
-	isRoomFull := room.Sender != nil && room.Receiver != nil
-	ws.roomsMux.RUnlock()
-
-	if isRoomFull {
-		log.Printf("房间已满，拒绝连接: %s", code)
-		conn.WriteJSON(map[string]interface{}{
-			"type":    "error",
-			"message": "当前房间人数已满，正在传输中无法加入",
-		})
+		conn.WriteJSON(newSignalError(client.Lang, errCode))
 		return
 	}
+	go client.writePump(opts.WriteTimeout)
 
-	// 生成客户端ID
-	clientID := ws.generateClientID()
-	client := &WebRTCClient{
-		ID:         clientID,
-		Role:       role,
-		Connection: conn,
-		Room:       code,
-	}
-
-	log.Printf("WebRTC客户端已创建: ID=%s, Role=%s, Room=%s", clientID, role, code)
-
-	// 添加客户端到房间
-	ws.addClientToRoom(code, client)
-	log.Printf("WebRTC %s连接到房间: %s (客户端ID: %s)", role, code, clientID)
+	// 启动心跳：定期 ping，超时未收到任何数据则判定对端失联
+	stopHeartbeat := startHeartbeat(conn, opts, room.touch)
 
 	// 连接关闭时清理
+	reason := "closed"
 	defer func() {
-		ws.removeClientFromRoom(code, clientID)
-		log.Printf("WebRTC客户端断开连接: %s (房间: %s)", clientID, code)
-
-		// 通知房间内其他客户端对方已断开连接
-		ws.notifyRoomDisconnection(code, clientID, client.Role)
+		stopHeartbeat()
+		ws.leaveRoom(client, reason)
 	}()
 
 	// 处理消息
@@ -149,58 +144,156 @@ func (ws *WebRTCService) HandleWebSocket(w http.ResponseWriter, r *http.Request)
 		var msg WebRTCMessage
 		err := conn.ReadJSON(&msg)
 		if err != nil {
-			log.Printf("读取WebRTC WebSocket消息失败: %v", err)
+			if isTimeoutError(err) {
+				reason = "timeout"
+				logging.Warnf("WebRTC客户端心跳超时: %s (房间: %s)", client.ID, code)
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				logging.Errorf("读取WebRTC WebSocket消息失败: %v", err)
+			} else {
+				logging.Infof("WebRTC客户端断开连接: %s (房间: %s)", client.ID, code)
+			}
 			break
 		}
+		extendReadDeadline(conn, opts)
 
-		msg.From = clientID
-		log.Printf("收到WebRTC信令: 类型=%s, 来自=%s, 房间=%s", msg.Type, clientID, code)
+		ws.handleClientMessage(room, client, &msg)
+	}
+}
 
-		// 转发信令消息给对方
-		ws.forwardMessage(code, clientID, &msg)
+// newClient 创建信令客户端，conn 为 nil 表示非 WebSocket 传输（如 SSE）
+func (ws *WebRTCService) newClient(r *http.Request, code, role string, conn *websocket.Conn, opts Options) *WebRTCClient {
+	return &WebRTCClient{
+		ID:          ws.generateClientID(),
+		Role:        role,
+		ConnectedAt: time.Now(),
+		Connection:  conn,
+		Room:        code,
+		Identity:    requestIdentity(r),
+		RemoteAddr:  api.ClientIP(r),
+		Lang:        i18n.Negotiate(r),
+		writer:      newClientWriter(opts),
 	}
 }
 
-// 添加客户端到房间
-func (ws *WebRTCService) addClientToRoom(code string, client *WebRTCClient) {
-	ws.roomsMux.Lock()
-	defer ws.roomsMux.Unlock()
+// requestIdentity 返回请求携带的已验证身份，匿名时为空
+func requestIdentity(r *http.Request) string {
+	if principal := auth.PrincipalFrom(r.Context()); principal != nil {
+		return principal.Identity()
+	}
+	return ""
+}
+
+// joinRoom 校验房间并把客户端加入房间，与传输方式无关
+// 成功时返回房间；失败时返回 nil 和发给客户端的错误码
+func (ws *WebRTCService) joinRoom(code, role string, client *WebRTCClient) (*WebRTCRoom, string) {
+	if code == "" || (role != "sender" && role != "receiver") {
+		logging.Infof("WebRTC连接参数无效: code=%s, role=%s", code, role)
+		return nil, api.CodeInvalidParams
+	}
 
+	ws.roomsMux.Lock()
 	room := ws.rooms[code]
+
+	// 验证房间是否存在
 	if room == nil {
-		log.Printf("尝试加入不存在的WebRTC房间: %s", code)
-		return
+		ws.roomsMux.Unlock()
+		logging.Infof("房间不存在: %s", code)
+		return nil, api.CodeRoomNotFound
+	}
+
+	// 检查房间是否已过期（清理任务尚未删除的过期房间同样拒绝加入）
+	if room.expireReason(time.Now(), ws.opts.Load()) != "" {
+		ws.roomsMux.Unlock()
+		logging.Infof("房间已过期: %s", code)
+		return nil, api.CodeRoomExpired
+	}
+
+	// 检查房间是否已满（两个连接都已存在）
+	if room.Sender != nil && room.Receiver != nil {
+		ws.roomsMux.Unlock()
+		logging.Warnf("房间已满，拒绝连接: %s", code)
+		return nil, api.CodeRoomFull
 	}
 
-	if client.Role == "sender" {
+	var peer *WebRTCClient
+	if role == "sender" {
 		room.Sender = client
-		// 如果发送方连接，检查是否有接收方在等待，通知接收方
-		if room.Receiver != nil {
-			log.Printf("通知接收方：发送方已连接")
-			peerJoinedMsg := &WebRTCMessage{
-				Type: "peer-joined",
-				From: client.ID,
-				Payload: map[string]interface{}{
-					"role": "sender",
-				},
-			}
-			room.Receiver.Connection.WriteJSON(peerJoinedMsg)
-		}
+		peer = room.Receiver
 	} else {
 		room.Receiver = client
-		// 如果接收方连接，通知发送方可以开始建立P2P连接
-		if room.Sender != nil {
-			log.Printf("通知发送方：接收方已连接，可以开始建立P2P连接")
-			peerJoinedMsg := &WebRTCMessage{
-				Type: "peer-joined",
-				From: client.ID,
-				Payload: map[string]interface{}{
-					"role": "receiver",
-				},
-			}
-			room.Sender.Connection.WriteJSON(peerJoinedMsg)
+		peer = room.Sender
+	}
+	ws.roomsMux.Unlock()
+
+	room.touch()
+	logging.Infof("WebRTC %s连接到房间: %s (客户端ID: %s)", role, code, client.ID)
+	ws.bus.Publish(events.Event{
+		Type:       events.ParticipantJoined,
+		Room:       code,
+		Identity:   client.Identity,
+		Role:       role,
+		ClientID:   client.ID,
+		RemoteAddr: client.RemoteAddr,
+		Data:       map[string]interface{}{"transport": client.transport()},
+	})
+
+	// 在释放锁之后再通知对方，避免慢连接阻塞整个服务
+	if peer != nil {
+		if role == "sender" {
+			// 如果发送方连接，检查是否有接收方在等待，通知接收方
+			logging.Infof("通知接收方：发送方已连接")
+		} else {
+			// 如果接收方连接，通知发送方可以开始建立P2P连接
+			logging.Infof("通知发送方：接收方已连接，可以开始建立P2P连接")
 		}
+		peer.Send(&WebRTCMessage{
+			Type:    "peer-joined",
+			From:    client.ID,
+			Payload: PeerJoinedPayload{Role: role},
+		})
 	}
+
+	return room, ""
+}
+
+// leaveRoom 客户端离开房间并通知对方，reason 见 notifyRoomDisconnection
+func (ws *WebRTCService) leaveRoom(client *WebRTCClient, reason string) {
+	client.Close()
+	ws.removeClientFromRoom(client.Room, client.ID)
+	logging.Infof("WebRTC客户端断开连接: %s (房间: %s, 原因: %s)", client.ID, client.Room, reason)
+	ws.bus.Publish(events.Event{
+		Type:       events.ParticipantLeft,
+		Room:       client.Room,
+		Identity:   client.Identity,
+		Role:       client.Role,
+		ClientID:   client.ID,
+		RemoteAddr: client.RemoteAddr,
+		Data: map[string]interface{}{
+			"transport":        client.transport(),
+			"reason":           reason,
+			"duration_seconds": int64(time.Since(client.ConnectedAt).Seconds()),
+		},
+	})
+
+	// 通知房间内其他客户端对方已断开连接
+	ws.notifyRoomDisconnection(client.Room, client.ID, client.Role, reason)
+}
+
+// handleClientMessage 处理客户端发来的一条信令消息
+func (ws *WebRTCService) handleClientMessage(room *WebRTCRoom, client *WebRTCClient, msg *WebRTCMessage) {
+	room.touch()
+
+	// 应用层心跳：直接回复，不转发
+	if msg.Type == "ping" {
+		client.Send(&WebRTCMessage{Type: "pong", To: client.ID})
+		return
+	}
+
+	msg.From = client.ID
+	logging.Debugf("收到WebRTC信令: 类型=%s, 来自=%s, 房间=%s", msg.Type, client.ID, client.Room)
+
+	// 转发信令消息给对方
+	ws.forwardMessage(client.Room, client.ID, msg)
 }
 
 // 从房间移除客户端
@@ -220,90 +313,155 @@ func (ws *WebRTCService) removeClientFromRoom(code string, clientID string) {
 		room.Receiver = nil
 	}
 
-	// 如果房间为空，删除房间
-	if room.Sender == nil && room.Receiver == nil {
-		delete(ws.rooms, code)
-		log.Printf("清理WebRTC房间: %s", code)
+	// 房间为空时保留，空闲超过 IdleTimeout 后由清理任务删除，期间可以重新加入；持久房间重置有效期
+	if room.Persistent && room.Sender == nil && room.Receiver == nil {
+		ws.resetRoom(room, time.Now())
 	}
 }
 
 // 转发信令消息
 func (ws *WebRTCService) forwardMessage(roomCode string, fromClientID string, msg *WebRTCMessage) {
-	ws.roomsMux.Lock()
-	defer ws.roomsMux.Unlock()
-
+	ws.roomsMux.RLock()
 	room := ws.rooms[roomCode]
-	if room == nil {
-		return
+	var targetClient *WebRTCClient
+	if room != nil {
+		if room.Sender != nil && room.Sender.ID == fromClientID {
+			// 消息来自sender，转发给receiver
+			targetClient = room.Receiver
+		} else if room.Receiver != nil && room.Receiver.ID == fromClientID {
+			// 消息来自receiver，转发给sender
+			targetClient = room.Sender
+		}
 	}
+	ws.roomsMux.RUnlock()
 
-	var targetClient *WebRTCClient
-	if room.Sender != nil && room.Sender.ID == fromClientID {
-		// 消息来自sender，转发给receiver
-		targetClient = room.Receiver
-	} else if room.Receiver != nil && room.Receiver.ID == fromClientID {
-		// 消息来自receiver，转发给sender
-		targetClient = room.Sender
+	if room == nil {
+		return
 	}
 
-	if targetClient != nil && targetClient.Connection != nil {
+	if targetClient != nil {
 		msg.To = targetClient.ID
-		err := targetClient.Connection.WriteJSON(msg)
-		if err != nil {
-			log.Printf("转发WebRTC信令失败: %v", err)
+		if targetClient.Send(msg) {
+			logging.Debugf("转发WebRTC信令: 类型=%s, 从=%s到=%s", msg.Type, fromClientID, targetClient.ID)
 		} else {
-			log.Printf("转发WebRTC信令: 类型=%s, 从=%s到=%s", msg.Type, fromClientID, targetClient.ID)
+			logging.Errorf("转发WebRTC信令失败: 类型=%s, 从=%s到=%s", msg.Type, fromClientID, targetClient.ID)
 		}
 	} else {
-		log.Printf("目标客户端不在线，消息类型=%s", msg.Type)
+		logging.Infof("目标客户端不在线，消息类型=%s", msg.Type)
 	}
 }
 
-// CreateRoom 创建或获取房间
-func (ws *WebRTCService) CreateRoom(code string) {
+// CreateRoom 创建有效期为 ttl 的房间，房间码已存在时返回 false
+func (ws *WebRTCService) CreateRoom(code, owner string, ttl time.Duration) bool {
 	ws.roomsMux.Lock()
 	defer ws.roomsMux.Unlock()
 
-	if _, exists := ws.rooms[code]; !exists {
-		ws.rooms[code] = &WebRTCRoom{
-			Code:      code,
-			CreatedAt: time.Now(),
-			ExpiresAt: time.Now().Add(time.Hour), // 1小时后过期
-		}
-		log.Printf("创建WebRTC房间: %s", code)
+	if _, exists := ws.rooms[code]; exists {
+		return false
 	}
+	opts := ws.opts.Load()
+	now := time.Now()
+	room := &WebRTCRoom{
+		Code:       code,
+		Owner:      owner,
+		Persistent: opts.isPersistent(code),
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(ttl),
+	}
+	room.lastSeen.Store(room.CreatedAt.UnixNano())
+	ws.rooms[code] = room
+	logging.Infof("创建WebRTC房间: %s", code)
+	return true
 }
 
-// CreateNewRoom 创建新房间并返回房间码 - 确保不重复
-func (ws *WebRTCService) CreateNewRoom() string {
+// CreateNewRoom 为创建者创建新房间并返回房间码 - 确保不重复
+// requested 非空时使用指定的房间码：需符合取件码规则（ErrInvalidCode），不能保留给其他身份（ErrCodeReserved），
+// 也不能正在被其他房间使用（ErrCodeTaken）；指定的是已存在的持久房间时直接返回该房间码
+// ttl 为请求的有效期，0 表示使用默认值，超出允许范围时返回 ErrInvalidTTL
+// 创建者超出创建房间配额时返回 accounting.ErrRoomQuota
+func (ws *WebRTCService) CreateNewRoom(owner, remoteAddr, requested string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	opts := ws.opts.Load()
+
+	ttl, err := opts.roomTTL(ttl)
+	if err != nil {
+		return "", err
+	}
+
 	var code string
+	if requested != "" {
+		code = NormalizePickupCode(requested)
+		if !ValidPickupCode(code, opts.CodeLength) {
+			return "", ErrInvalidCode
+		}
+		if reservedOwner, reserved := opts.reservedOwner(code); reserved && reservedOwner != owner {
+			return "", ErrCodeReserved
+		}
+		if ws.roomPersistent(code) {
+			return code, nil
+		}
+	}
 
-	// 生成唯一房间码，确保不重复
-	for {
-		code = ws.generatePickupCode()
-		ws.roomsMux.RLock()
-		_, exists := ws.rooms[code]
-		ws.roomsMux.RUnlock()
+	if err := ws.ledger.CheckRoom(owner, opts.Quota, now); err != nil {
+		return "", err
+	}
 
-		if !exists {
-			break // 找到了不重复的代码
+	if code != "" {
+		if !ws.CreateRoom(code, owner, ttl) {
+			return "", ErrCodeTaken
+		}
+	} else {
+		// 生成唯一房间码，确保不重复，并跳过保留的房间码
+		for {
+			code = ws.generatePickupCode()
+			if _, reserved := opts.reservedOwner(code); reserved {
+				continue
+			}
+			if ws.CreateRoom(code, owner, ttl) {
+				break // 找到了不重复的代码
+			}
+			// 如果重复了，继续生成新的
 		}
-		// 如果重复了，继续生成新的
 	}
 
-	ws.CreateRoom(code)
-	return code
+	ws.ledger.AddRoom(owner, now)
+	ws.bus.Publish(events.Event{
+		Time:       now,
+		Type:       events.RoomCreated,
+		Room:       code,
+		Identity:   owner,
+		RemoteAddr: remoteAddr,
+		Data:       map[string]interface{}{"ttl_seconds": int64(ttl.Seconds())},
+	})
+	return code, nil
+}
+
+// roomPersistent 判断房间是否为已存在的持久房间
+func (ws *WebRTCService) roomPersistent(code string) bool {
+	ws.roomsMux.RLock()
+	defer ws.roomsMux.RUnlock()
+	room := ws.rooms[code]
+	return room != nil && room.Persistent
+}
+
+// roomOwner 返回房间创建者，房间不存在或匿名创建时为空
+func (ws *WebRTCService) roomOwner(code string) string {
+	ws.roomsMux.RLock()
+	defer ws.roomsMux.RUnlock()
+	if room := ws.rooms[code]; room != nil {
+		return room.Owner
+	}
+	return ""
 }
 
-// generatePickupCode 生成6位取件码 - 统一规则：只使用大写字母和数字，排除0和O避免混淆
+// generatePickupCode 生成取件码（默认6位） - 统一规则：只使用大写字母和数字，排除0和O避免混淆
 func (ws *WebRTCService) generatePickupCode() string {
-	// 只使用大写字母和数字，排除容易混淆的字符：数字0和字母O
-	chars := "123456789ABCDEFGHIJKLMNPQRSTUVWXYZ"
+	chars := PickupCodeChars
 	source := rand.NewSource(time.Now().UnixNano())
 	rng := rand.New(source)
 
-	result := make([]byte, 6)
-	for i := 0; i < 6; i++ {
+	result := make([]byte, ws.opts.Load().CodeLength)
+	for i := range result {
 		result[i] = chars[rng.Intn(len(chars))]
 	}
 	return string(result)
@@ -311,20 +469,98 @@ func (ws *WebRTCService) generatePickupCode() string {
 
 // cleanupExpiredRooms 定期清理过期房间
 func (ws *WebRTCService) cleanupExpiredRooms() {
-	ticker := time.NewTicker(5 * time.Minute)
+	interval := ws.opts.Load().CleanupInterval
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for range ticker.C {
-		ws.roomsMux.Lock()
-		now := time.Now()
-		for code, room := range ws.rooms {
-			// 房间过期或无客户端连接则删除
-			if now.After(room.ExpiresAt) || (room.Sender == nil && room.Receiver == nil) {
-				delete(ws.rooms, code)
-				log.Printf("清理过期WebRTC房间: %s", code)
+		// 清理间隔可能已在运行时修改
+		if next := ws.opts.Load().CleanupInterval; next != interval {
+			interval = next
+			ticker.Reset(interval)
+		}
+
+		ws.expireRooms(time.Now())
+	}
+}
+
+// expireRooms 按房间策略删除过期房间；超过最长使用时间的房间先断开所有连接
+func (ws *WebRTCService) expireRooms(now time.Time) {
+	opts := ws.opts.Load()
+
+	type expiredRoom struct {
+		room    *WebRTCRoom
+		reason  string
+		clients []*WebRTCClient
+	}
+	var expired []expiredRoom
+
+	ws.roomsMux.Lock()
+	for code, room := range ws.rooms {
+		// 持久房间不删除，为空时重置有效期
+		if room.Persistent {
+			if room.Sender == nil && room.Receiver == nil {
+				ws.resetRoom(room, now)
 			}
+			continue
 		}
-		ws.roomsMux.Unlock()
+		reason := room.expireReason(now, opts)
+		if reason == "" {
+			continue
+		}
+		delete(ws.rooms, code)
+		var clients []*WebRTCClient
+		for _, c := range []*WebRTCClient{room.Sender, room.Receiver} {
+			if c != nil {
+				clients = append(clients, c)
+			}
+		}
+		expired = append(expired, expiredRoom{room, reason, clients})
+		logging.Infof("清理过期WebRTC房间: %s (原因: %s)", code, reason)
+	}
+	ws.roomsMux.Unlock()
+
+	for _, e := range expired {
+		if e.reason == ExpireReasonMaxLifetime {
+			disconnectClients(e.clients, ExpireReasonMaxLifetime)
+			if closeRelay := ws.closeRelay.Load(); closeRelay != nil {
+				(*closeRelay)(e.room.Code, ExpireReasonMaxLifetime)
+			}
+		}
+		ws.bus.Publish(events.Event{
+			Time:     now,
+			Type:     events.RoomExpired,
+			Room:     e.room.Code,
+			Identity: e.room.Owner,
+			Data:     map[string]interface{}{"reason": e.reason},
+		})
+	}
+}
+
+// CheckOrigin 按当前来源策略校验 WebSocket 升级请求
+func (ws *WebRTCService) CheckOrigin(r *http.Request) bool {
+	return ws.opts.Load().Origins.CheckOrigin(r)
+}
+
+// CheckWebTransportOrigin 按当前来源策略校验 WebTransport 会话，与本服务主机名相同的来源视为同源
+func (ws *WebRTCService) CheckWebTransportOrigin(r *http.Request) bool {
+	return ws.opts.Load().Origins.CheckOriginSameHost(r)
+}
+
+// UpdateOptions 在运行时替换服务参数，只影响之后创建的房间和连接
+func (ws *WebRTCService) UpdateOptions(opts Options) {
+	ws.opts.Store(opts)
+	ws.syncPersistentRooms(opts)
+}
+
+// touchRoom 记录房间活动时间（供中继连接使用）
+func (ws *WebRTCService) touchRoom(code string) {
+	ws.roomsMux.RLock()
+	room := ws.rooms[code]
+	ws.roomsMux.RUnlock()
+
+	if room != nil {
+		room.touch()
 	}
 }
 
@@ -334,67 +570,111 @@ func (ws *WebRTCService) generateClientID() string {
 }
 
 // 通知房间内客户端有人断开连接
-func (ws *WebRTCService) notifyRoomDisconnection(roomCode string, disconnectedClientID string, disconnectedRole string) {
-	ws.roomsMux.Lock()
-	defer ws.roomsMux.Unlock()
-
+// reason 为 "closed"（连接正常关闭）或 "timeout"（心跳超时）
+func (ws *WebRTCService) notifyRoomDisconnection(roomCode string, disconnectedClientID string, disconnectedRole string, reason string) {
+	ws.roomsMux.RLock()
 	room := ws.rooms[roomCode]
-	if room == nil {
-		return
-	}
-
-	// 构建断开连接通知消息
-	disconnectionMsg := &WebRTCMessage{
-		Type: "disconnection",
-		From: disconnectedClientID,
-		Payload: map[string]interface{}{
-			"role":    disconnectedRole,
-			"message": "对方已停止传输",
-		},
+	var others []*WebRTCClient
+	if room != nil {
+		if room.Sender != nil && room.Sender.ID != disconnectedClientID {
+			others = append(others, room.Sender)
+		}
+		if room.Receiver != nil && room.Receiver.ID != disconnectedClientID {
+			others = append(others, room.Receiver)
+		}
 	}
+	ws.roomsMux.RUnlock()
 
-	// 通知房间内其他客户端
-	if room.Sender != nil && room.Sender.ID != disconnectedClientID {
-		err := room.Sender.Connection.WriteJSON(disconnectionMsg)
-		if err != nil {
-			log.Printf("通知发送方断开连接失败: %v", err)
+	// 通知房间内其他客户端，提示信息按各自的语言本地化
+	for _, other := range others {
+		disconnectionMsg := &WebRTCMessage{
+			Type: "disconnection",
+			From: disconnectedClientID,
+			Payload: DisconnectionPayload{
+				Role:    disconnectedRole,
+				Reason:  reason,
+				Message: i18n.T(other.Lang, "disconnect.peer_left"),
+			},
+		}
+		if other.Send(disconnectionMsg) {
+			logging.Infof("已通知%s: 对方已断开连接", roleName(other.Role))
 		} else {
-			log.Printf("已通知发送方: 对方已断开连接")
+			logging.Errorf("通知%s断开连接失败", roleName(other.Role))
 		}
 	}
+}
 
-	if room.Receiver != nil && room.Receiver.ID != disconnectedClientID {
-		err := room.Receiver.Connection.WriteJSON(disconnectionMsg)
-		if err != nil {
-			log.Printf("通知接收方断开连接失败: %v", err)
-		} else {
-			log.Printf("已通知接收方: 对方已断开连接")
-		}
+// roleName 返回角色的中文名称
+func roleName(role string) string {
+	if role == "sender" {
+		return "发送方"
 	}
+	return "接收方"
 }
 
-func (ws *WebRTCService) GetRoomStatus(code string) map[string]interface{} {
+// RoomStatus 房间的公开状态
+type RoomStatus struct {
+	Code           string
+	SenderOnline   bool
+	ReceiverOnline bool
+	Persistent     bool
+	CreatedAt      time.Time
+	ExpiresAt      time.Time
+	LastSeen       time.Time
+}
+
+// Full 发送方和接收方都已加入
+func (s RoomStatus) Full() bool {
+	return s.SenderOnline && s.ReceiverOnline
+}
+
+// RoomStatus 返回房间状态，房间不存在或已过期（等待清理）时返回 false
+func (ws *WebRTCService) RoomStatus(code string) (RoomStatus, bool) {
 	ws.roomsMux.RLock()
 	defer ws.roomsMux.RUnlock()
 
 	room := ws.rooms[code]
-	if room == nil {
+	if room == nil || room.expireReason(time.Now(), ws.opts.Load()) != "" {
+		return RoomStatus{}, false
+	}
+	return RoomStatus{
+		Code:           room.Code,
+		SenderOnline:   room.Sender != nil,
+		ReceiverOnline: room.Receiver != nil,
+		Persistent:     room.Persistent,
+		CreatedAt:      room.CreatedAt,
+		ExpiresAt:      room.ExpiresAt,
+		LastSeen:       room.LastSeen(),
+	}, true
+}
+
+// TTLRange 返回创建房间时允许指定的有效期范围
+func (ws *WebRTCService) TTLRange() (time.Duration, time.Duration) {
+	opts := ws.opts.Load()
+	return opts.MinRoomTTL, opts.MaxRoomTTL
+}
+
+// GetRoomStatus 获取房间状态（旧版 /api/room-info 的响应格式），房间不存在时的提示信息按 lang 本地化
+func (ws *WebRTCService) GetRoomStatus(code, lang string) map[string]interface{} {
+	status, ok := ws.RoomStatus(code)
+	if !ok {
 		return map[string]interface{}{
 			"success": false,
 			"exists":  false,
-			"message": "房间不存在或已过期",
+			"code":    api.CodeRoomNotFound,
+			"message": i18n.T(lang, "error."+api.CodeRoomNotFound),
 		}
 	}
 
-	// 检查房间是否已满（两个连接都已存在）
-	isRoomFull := room.Sender != nil && room.Receiver != nil
-
 	return map[string]interface{}{
 		"success":         true,
 		"exists":          true,
-		"sender_online":   room.Sender != nil,
-		"receiver_online": room.Receiver != nil,
-		"is_room_full":    isRoomFull,
-		"created_at":      room.CreatedAt,
+		"sender_online":   status.SenderOnline,
+		"receiver_online": status.ReceiverOnline,
+		"is_room_full":    status.Full(),
+		"persistent":      status.Persistent,
+		"created_at":      status.CreatedAt,
+		"expires_at":      status.ExpiresAt,
+		"last_seen":       status.LastSeen,
 	}
 }
@@ -0,0 +1,320 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"chuan/internal/logging"
+
+	"github.com/andybalholm/brotli"
+)
+
+// 内嵌前端文件的服务方式：
+//   - 启动时按文件内容计算强 ETag，支持 If-None-Match 返回 304
+//   - 文本类文件在后台预压缩为 brotli 和 gzip，按 Accept-Encoding 返回对应版本，请求时不再动态压缩
+//   - 未压缩的版本直接从 embed.FS 读取，不复制文件内容
+//   - 只有带内容哈希的 _next/static 文件使用 immutable 长期缓存，其他文件每次使用前用 ETag 校验
+
+// immutablePrefix Next.js 构建产物中文件名带内容哈希（或构建 ID）的目录，内容永不变化
+const immutablePrefix = "_next/static/"
+
+// asset 一个静态文件
+type asset struct {
+	name         string // fs 中的路径
+	etag         string // 内容的 SHA-256 前 16 字节，不含引号
+	compressible bool
+	html         bool
+	scriptHashes []string // HTML 文件中内联脚本的 CSP 哈希源
+
+	// 预压缩版本，压缩后没有变小时为 nil；ready 之前不可读取
+	ready atomic.Bool
+	br    []byte
+	gz    []byte
+
+	// 注入了前端配置或加上了路径前缀的 HTML 页面，配置或前缀变化后重新生成
+	page atomic.Pointer[renderedPage]
+}
+
+// renderedPage 注入脚本、改写路径后的 HTML 页面及其预压缩版本
+type renderedPage struct {
+	script       string
+	prefix       string
+	body         []byte
+	br           []byte
+	gz           []byte
+	etag         string
+	scriptHashes []string
+}
+
+// assetStore 启动时建立的静态文件索引
+type assetStore struct {
+	fs     fs.FS
+	assets map[string]*asset
+}
+
+// newAssetStore 遍历 fsys 计算每个文件的 ETag 和 HTML 内联脚本哈希，并在后台预压缩文本类文件
+func newAssetStore(fsys fs.FS) (*assetStore, error) {
+	s := &assetStore{fs: fsys, assets: make(map[string]*asset)}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		a := &asset{
+			name:         name,
+			compressible: isCompressible(name),
+			html:         strings.EqualFold(path.Ext(name), ".html"),
+		}
+		h := sha256.New()
+		if a.html {
+			// HTML 文件较小，整体读入以便计算内联脚本哈希
+			data, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return err
+			}
+			h.Write(data)
+			a.scriptHashes = inlineScriptHashes(data)
+		} else {
+			f, err := fsys.Open(name)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if _, err := io.Copy(h, f); err != nil {
+				return err
+			}
+		}
+		a.etag = hex.EncodeToString(h.Sum(nil)[:16])
+		s.assets[name] = a
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go s.precompress()
+	return s, nil
+}
+
+// lookup 返回文件，不存在时返回 nil
+func (s *assetStore) lookup(name string) *asset {
+	return s.assets[name]
+}
+
+// precompress 依次压缩所有文本类文件，完成前请求到的文件以未压缩版本返回
+func (s *assetStore) precompress() {
+	start := time.Now()
+	names := make([]string, 0, len(s.assets))
+	for name, a := range s.assets {
+		if a.compressible {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var original, compressed int64
+	for _, name := range names {
+		a := s.assets[name]
+		data, err := fs.ReadFile(s.fs, name)
+		if err != nil {
+			logging.Errorf("读取前端文件失败: %s: %v", name, err)
+			continue
+		}
+		a.br, a.gz = encodeBrotli(data), encodeGzip(data)
+		a.ready.Store(true)
+
+		original += int64(len(data))
+		switch {
+		case a.br != nil:
+			compressed += int64(len(a.br))
+		case a.gz != nil:
+			compressed += int64(len(a.gz))
+		default:
+			compressed += int64(len(data))
+		}
+	}
+	log.Printf("📦 前端文件预压缩完成: %d 个文件, %d → %d 字节 (brotli), 耗时 %v",
+		len(names), original, compressed, time.Since(start).Round(time.Millisecond))
+}
+
+func encodeBrotli(data []byte) []byte {
+	return encode(data, func(w io.Writer) io.WriteCloser {
+		return brotli.NewWriterLevel(w, brotli.BestCompression)
+	})
+}
+
+func encodeGzip(data []byte) []byte {
+	return encode(data, func(w io.Writer) io.WriteCloser {
+		zw, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+		return zw
+	})
+}
+
+// encode 压缩 data，压缩后没有变小时返回 nil
+func encode(data []byte, newWriter func(io.Writer) io.WriteCloser) []byte {
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil
+	}
+	if err := w.Close(); err != nil {
+		return nil
+	}
+	if buf.Len() >= len(data) {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// serve 返回文件，按 Accept-Encoding 选择预压缩版本；Range、If-None-Match、HEAD 由 http.ServeContent 处理
+func (s *assetStore) serve(w http.ResponseWriter, r *http.Request, a *asset) {
+	setContentType(w, a.name)
+	w.Header().Set("Cache-Control", cacheControl(a.name))
+
+	if a.compressible {
+		// 同一 URL 按 Accept-Encoding 返回不同内容，各版本使用不同的 ETag
+		w.Header().Add("Vary", "Accept-Encoding")
+		if a.ready.Load() && serveCompressed(w, r, a.name, a.etag, a.br, a.gz) {
+			return
+		}
+	}
+
+	f, err := s.fs.Open(a.name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		http.Error(w, "file is not seekable", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", `"`+a.etag+`"`)
+	http.ServeContent(w, r, a.name, time.Time{}, content)
+}
+
+// renderPage 返回加上路径前缀 prefix、注入了 script 的页面，同一 script 和 prefix 只生成一次
+func (s *assetStore) renderPage(a *asset, script, prefix string) (*renderedPage, error) {
+	if p := a.page.Load(); p != nil && p.script == script && p.prefix == prefix {
+		return p, nil
+	}
+	data, err := fs.ReadFile(s.fs, a.name)
+	if err != nil {
+		return nil, err
+	}
+	body := rewriteRootPaths(data, prefix)
+	if script != "" {
+		body = injectScript(body, script)
+	}
+	sum := sha256.Sum256(body)
+	p := &renderedPage{
+		script:       script,
+		prefix:       prefix,
+		body:         body,
+		br:           encodeBrotli(body),
+		gz:           encodeGzip(body),
+		etag:         hex.EncodeToString(sum[:16]),
+		scriptHashes: inlineScriptHashes(body),
+	}
+	a.page.Store(p)
+	return p, nil
+}
+
+// servePage 返回 renderPage 生成的页面，缓存策略与其他 HTML 文件相同
+func (s *assetStore) servePage(w http.ResponseWriter, r *http.Request, a *asset, p *renderedPage) {
+	setContentType(w, a.name)
+	w.Header().Set("Cache-Control", cacheControl(a.name))
+	w.Header().Add("Vary", "Accept-Encoding")
+	if serveCompressed(w, r, a.name, p.etag, p.br, p.gz) {
+		return
+	}
+	w.Header().Set("ETag", `"`+p.etag+`"`)
+	http.ServeContent(w, r, a.name, time.Time{}, bytes.NewReader(p.body))
+}
+
+// serveCompressed 按 Accept-Encoding 返回 brotli 或 gzip 版本，客户端都不接受时返回 false
+func serveCompressed(w http.ResponseWriter, r *http.Request, name, etag string, br, gz []byte) bool {
+	var available []string
+	if br != nil {
+		available = append(available, "br")
+	}
+	if gz != nil {
+		available = append(available, "gzip")
+	}
+	encoding := preferredEncoding(r.Header.Get("Accept-Encoding"), available)
+	var data []byte
+	switch encoding {
+	case "br":
+		data = br
+	case "gzip":
+		data = gz
+	default:
+		return false
+	}
+	w.Header().Set("Content-Encoding", encoding)
+	w.Header().Set("ETag", `"`+etag+"-"+encoding+`"`)
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+	return true
+}
+
+// preferredEncoding 按 Accept-Encoding 的权重从 available 中选择编码，权重相同时按 available 的顺序，都不接受时返回空字符串
+func preferredEncoding(header string, available []string) string {
+	if header == "" || len(available) == 0 {
+		return ""
+	}
+	weights := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		weights[coding] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range available {
+		q, ok := weights[encoding]
+		if !ok {
+			q = weights["*"] // 未列出的编码按 * 的权重，没有 * 时不接受
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// cacheControl 带内容哈希的文件长期缓存；HTML 和 public 目录下的文件可能在新版本中原地替换，每次使用前校验
+func cacheControl(name string) string {
+	if strings.HasPrefix(name, immutablePrefix) {
+		return "public, max-age=31536000, immutable"
+	}
+	return "no-cache"
+}
+
+// isCompressible 判断文件是否值得压缩，图片、字体（woff/woff2）等已压缩格式不再压缩
+func isCompressible(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".html", ".css", ".js", ".mjs", ".json", ".map", ".svg", ".txt", ".xml", ".webmanifest", ".ico", ".ttf":
+		return true
+	}
+	return false
+}
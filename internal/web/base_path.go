@@ -0,0 +1,36 @@
+package web
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// Next.js 导出的页面使用以 / 开头的地址引用构建产物（/_next/static/...）和其他页面，
+// 部署在子路径下时需要在返回页面时加上路径前缀。
+
+// rootPathAttr 匹配以 / 开头的 src、href、action、poster 属性，匹配结束于开头的 /
+var rootPathAttr = regexp.MustCompile(`(?i)\s(?:src|href|action|poster)\s*=\s*["']?/`)
+
+// rewriteRootPaths 为页面中以 / 开头的地址加上 prefix：
+//   - 属性中的地址，协议相对地址 (//cdn.example.com) 和已带有前缀的地址除外
+//   - 内联脚本中的 "/_next/ 字符串（如 RSC 数据中引用的样式表和脚本）
+func rewriteRootPaths(html []byte, prefix string) []byte {
+	if prefix == "" {
+		return html
+	}
+	var out bytes.Buffer
+	out.Grow(len(html) + 1024)
+	last := 0
+	for _, loc := range rootPathAttr.FindAllIndex(html, -1) {
+		slash := loc[1] - 1
+		rest := html[slash:]
+		if bytes.HasPrefix(rest, []byte("//")) || bytes.HasPrefix(rest, []byte(prefix+"/")) {
+			continue
+		}
+		out.Write(html[last:slash])
+		out.WriteString(prefix)
+		last = slash
+	}
+	out.Write(html[last:])
+	return bytes.ReplaceAll(out.Bytes(), []byte(`"/_next/`), []byte(`"`+prefix+`/_next/`))
+}
@@ -0,0 +1,115 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sync/atomic"
+
+	"chuan/internal/api"
+)
+
+// 前端构建时写死的配置（STUN 服务器、功能开关等）改为运行时从服务器获取：
+// /api/client-config 返回 JSON，也可以注入到页面的 window.__CHUAN_CONFIG__，页面加载时无需额外请求。
+
+// ClientConfig 运行时下发给前端的配置
+type ClientConfig struct {
+	ICEServers []ICEServer    `json:"ice_servers,omitempty"` // 为空时前端使用内置的默认列表
+	Features   ClientFeatures `json:"features"`
+	Branding   ClientBranding `json:"branding"`
+	PublicURL  string         `json:"public_url,omitempty"` // 对外访问地址，为空时前端使用当前页面地址
+	BasePath   string         `json:"base_path,omitempty"`  // 部署路径前缀，按请求填写，接口和 WebSocket 地址需要加上该前缀
+}
+
+// ICEServer 与浏览器 RTCIceServer 的格式一致
+type ICEServer struct {
+	URLs       string `json:"urls"`
+	Username   string `json:"username,omitempty"`
+	Credential string `json:"credential,omitempty"`
+}
+
+// ClientFeatures 前端功能开关
+type ClientFeatures struct {
+	Relay        bool  `json:"relay"`         // P2P 失败时可以降级为服务器中继
+	DesktopShare bool  `json:"desktop_share"` // 显示桌面共享
+	MaxFileSize  int64 `json:"max_file_size"` // 单个文件最大字节数，0 表示不限制
+}
+
+// ClientBranding 站点名称和图标
+type ClientBranding struct {
+	Name    string `json:"name,omitempty"`
+	LogoURL string `json:"logo_url,omitempty"`
+}
+
+// ClientConfigStore 可在运行时替换的前端配置
+type ClientConfigStore struct {
+	current atomic.Pointer[clientConfigValue]
+}
+
+type clientConfigValue struct {
+	config ClientConfig
+	inject bool
+	json   []byte // 部署在根路径时的 JSON
+}
+
+// marshal 返回带有 basePath 的 JSON
+func (v *clientConfigValue) marshal(basePath string) []byte {
+	if basePath == "" {
+		return v.json
+	}
+	config := v.config
+	config.BasePath = basePath
+	data, _ := json.Marshal(config)
+	return data
+}
+
+// NewClientConfigStore 创建前端配置，inject 为 true 时注入到前端页面
+func NewClientConfigStore(config ClientConfig, inject bool) *ClientConfigStore {
+	s := &ClientConfigStore{}
+	s.Update(config, inject)
+	return s
+}
+
+// Update 替换前端配置，之后的请求和页面立即生效
+func (s *ClientConfigStore) Update(config ClientConfig, inject bool) {
+	config.BasePath = ""
+	data, _ := json.Marshal(config)
+	s.current.Store(&clientConfigValue{config: config, inject: inject, json: data})
+}
+
+// ServeHTTP 返回 JSON 格式的前端配置
+func (s *ClientConfigStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(s.current.Load().marshal(api.BasePath(r)))
+}
+
+// pageScript 返回注入页面的脚本内容，s 为 nil 或不注入时返回空字符串
+func (s *ClientConfigStore) pageScript(basePath string) string {
+	if s == nil {
+		return ""
+	}
+	v := s.current.Load()
+	if !v.inject {
+		return ""
+	}
+	// json.Marshal 会转义 <、>、&，内容中不会出现 </script>
+	return "window.__CHUAN_CONFIG__=" + string(v.marshal(basePath)) + ";"
+}
+
+// headTagPattern 匹配 <head> 开始标签
+var headTagPattern = regexp.MustCompile(`(?i)<head(\s[^>]*)?>`)
+
+// injectScript 在 <head> 开始标签之后插入内联脚本，使其先于页面中的其他脚本执行；页面没有 <head> 时原样返回
+func injectScript(html []byte, script string) []byte {
+	loc := headTagPattern.FindIndex(html)
+	if loc == nil {
+		return html
+	}
+	out := make([]byte, 0, len(html)+len(script)+17)
+	out = append(out, html[:loc[1]]...)
+	out = append(out, "<script>"...)
+	out = append(out, script...)
+	out = append(out, "</script>"...)
+	return append(out, html[loc[1]:]...)
+}
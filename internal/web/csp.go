@@ -0,0 +1,41 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"regexp"
+)
+
+// Next.js 静态导出的页面包含内联脚本（如 self.__next_f.push(...)），CSP 不允许时页面无法水合。
+// 页面是构建时生成的静态文件，使用哈希而不是 nonce 放行：nonce 需要每次请求改写 HTML，
+// 会使预压缩和 ETag 失效；哈希在启动时计算一次，页面内容不变时保持不变。
+
+// PageHeaderFunc 返回 HTML 页面前调用，设置与页面内容相关的响应头（如 Content-Security-Policy）
+// scriptHashes 为页面中内联脚本的 CSP 哈希源表达式，形如 'sha256-...'
+type PageHeaderFunc func(h http.Header, r *http.Request, scriptHashes []string)
+
+// inlineScriptPattern 匹配 script 元素，第 1 组为属性，第 2 组为内容
+var inlineScriptPattern = regexp.MustCompile(`(?is)<script\b([^>]*)>(.*?)</script\s*>`)
+
+// srcAttrPattern 匹配 src 属性，带 src 的是外部脚本，不需要哈希
+var srcAttrPattern = regexp.MustCompile(`(?i)(^|\s)src\s*=`)
+
+// inlineScriptHashes 计算 HTML 中所有内联脚本的 SHA-256 哈希源，重复的只保留一个
+func inlineScriptHashes(html []byte) []string {
+	var hashes []string
+	seen := map[string]bool{}
+	for _, m := range inlineScriptPattern.FindAllSubmatch(html, -1) {
+		attrs, content := m[1], m[2]
+		if len(content) == 0 || srcAttrPattern.Match(attrs) {
+			continue
+		}
+		sum := sha256.Sum256(content)
+		hash := "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+		if !seen[hash] {
+			seen[hash] = true
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
@@ -1,133 +1,76 @@
 package web
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"embed"
-	"io"
+	"encoding/hex"
 	"io/fs"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"chuan/internal/api"
+	"chuan/internal/logging"
+
+	"github.com/go-chi/chi/v5/middleware"
 )
 
 // 前端文件嵌入 - 这个路径会在构建脚本中被替换
+// 使用 all: 前缀，Next.js 构建产物中以 _ 开头的文件（如 _next/static/<构建ID>/_buildManifest.js）同样需要嵌入
 //
-//go:embed frontend/*
+//go:embed all:frontend
 var FrontendFiles embed.FS
 
-// hasFrontendFiles 检查是否有前端文件
+// hasFrontendFiles 检查是否有前端文件（frontend 目录中只有 .gitkeep 时视为未构建）
 func hasFrontendFiles() bool {
-	entries, err := FrontendFiles.ReadDir("frontend")
-	if err != nil {
-		return false
-	}
-	return len(entries) > 0
+	_, err := fs.Stat(FrontendFiles, "frontend/index.html")
+	return err == nil
+}
+
+// FrontendOptions 前端文件处理器的参数
+type FrontendOptions struct {
+	Dir          string             // 外部前端目录，为空或不可用时使用内嵌文件
+	PageHeaders  PageHeaderFunc     // 返回 HTML 页面前设置页面相关的响应头，为 nil 时不调用
+	ClientConfig *ClientConfigStore // 注入到 HTML 页面的前端配置，为 nil 时不注入
 }
 
 // CreateFrontendHandler 创建前端文件处理器
-func CreateFrontendHandler() http.Handler {
+func CreateFrontendHandler(opts FrontendOptions) http.Handler {
 	// 检查是否配置了外部前端目录
-	if frontendDir := os.Getenv("FRONTEND_DIR"); frontendDir != "" {
-		if info, err := os.Stat(frontendDir); err == nil && info.IsDir() {
-			// 使用外部前端目录
-			return &externalSpaHandler{baseDir: frontendDir}
+	if opts.Dir != "" {
+		if info, err := os.Stat(opts.Dir); err == nil && info.IsDir() {
+			// 使用外部前端目录，文件可能随时被替换，不做预压缩，由中间件动态压缩
+			return middleware.Compress(5)(&externalSpaHandler{baseDir: opts.Dir, opts: opts})
 		}
 	}
 
 	// 使用内嵌的前端文件
+	placeholder := &placeholderHandler{pageHeaders: opts.PageHeaders}
 	if !hasFrontendFiles() {
-		return &placeholderHandler{}
+		return placeholder
 	}
 
 	frontendFS, err := fs.Sub(FrontendFiles, "frontend")
 	if err != nil {
-		return &placeholderHandler{}
+		return placeholder
+	}
+	assets, err := newAssetStore(frontendFS)
+	if err != nil {
+		logging.Errorf("读取内嵌前端文件失败: %v", err)
+		return placeholder
 	}
 
-	return &spaHandler{fs: frontendFS}
-}
-
-// placeholderHandler 占位处理器
-type placeholderHandler struct{}
-
-func (h *placeholderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`
-<!DOCTYPE html>
-<html lang="zh-CN">
-<head>
-    <title>文件传输服务</title>
-    <meta charset="utf-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1">
-    <style>
-        body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; margin: 0; padding: 20px; background: #f5f5f5; }
-        .container { max-width: 800px; margin: 0 auto; background: white; padding: 40px; border-radius: 8px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); }
-        h1 { color: #333; margin-bottom: 20px; }
-        .status { padding: 15px; background: #fff3cd; border: 1px solid #ffeaa7; border-radius: 4px; margin: 20px 0; }
-        .commands { background: #f8f9fa; padding: 15px; border-radius: 4px; margin: 20px 0; }
-        pre { margin: 0; overflow-x: auto; }
-        .api-list { margin: 20px 0; }
-        .api-item { margin: 10px 0; padding: 10px; background: #e3f2fd; border-radius: 4px; }
-        .env-config { background: #e8f5e8; padding: 15px; border-radius: 4px; margin: 20px 0; }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <h1>🚀 文件传输服务</h1>
-        
-        <div class="status">
-            ⚠️ 前端界面未构建，当前显示的是后端 API 服务。
-        </div>
-        
-        <h2>� 环境变量配置</h2>
-        <div class="env-config">
-            <strong>FRONTEND_DIR</strong> - 指定外部前端文件目录<br>
-            <strong>PORT</strong> - 自定义服务端口 (默认: 8080)<br><br>
-            <strong>示例:</strong><br>
-            <pre>export FRONTEND_DIR=/path/to/frontend
-export PORT=3000
-./file-transfer-server</pre>
-        </div>
-        
-        <h2>�📋 可用的 API 接口</h2>
-        <div class="api-list">
-            <div class="api-item"><strong>POST</strong> /api/create-room - 创建WebRTC房间</div>
-            <div class="api-item"><strong>GET</strong> /api/room-info - 获取房间信息</div>
-            <div class="api-item"><strong>WebSocket</strong> /api/ws/webrtc - WebRTC 信令连接</div>
-        </div>
-        
-        <h2>🛠️ 构建前端</h2>
-        <div class="commands">
-            <pre># 进入前端目录
-cd chuan-next
-
-# 安装依赖
-npm install
-
-# 构建静态文件
-npm run build
-
-# 方法1: 重新构建 Go 项目以嵌入前端文件
-cd ..
-go build -o file-transfer-server ./cmd
-
-# 方法2: 使用外部前端目录
-export FRONTEND_DIR=./chuan-next/out
-./file-transfer-server</pre>
-        </div>
-        
-        <p><strong>提示:</strong> 构建完成后刷新页面即可看到完整的前端界面。</p>
-    </div>
-</body>
-</html>
-	`))
+	return &spaHandler{assets: assets, opts: opts}
 }
 
 // externalSpaHandler 外部文件目录处理器
 type externalSpaHandler struct {
 	baseDir string
+	opts    FrontendOptions
 }
 
 func (h *externalSpaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -139,20 +82,20 @@ func (h *externalSpaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// 构建完整文件路径
 	fullPath := filepath.Join(h.baseDir, upath)
-	
+
 	// 安全检查：确保文件在基础目录内
 	absBasePath, err := filepath.Abs(h.baseDir)
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-	
+
 	absFullPath, err := filepath.Abs(fullPath)
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-	
+
 	if !strings.HasPrefix(absFullPath, absBasePath) {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
@@ -166,7 +109,35 @@ func (h *externalSpaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 服务文件
-	http.ServeFile(w, r, fullPath)
+	h.serveFile(w, r, fullPath)
+}
+
+// serveFile 服务外部目录中的文件
+// HTML 页面在每次请求时注入前端配置并计算内联脚本哈希（文件可能随时被替换），按内容计算 ETag，
+// 不使用 Last-Modified，避免配置变化后浏览器仍使用缓存的页面
+func (h *externalSpaHandler) serveFile(w http.ResponseWriter, r *http.Request, fullPath string) {
+	if !strings.EqualFold(filepath.Ext(fullPath), ".html") {
+		http.ServeFile(w, r, fullPath)
+		return
+	}
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	prefix := api.BasePath(r)
+	data = rewriteRootPaths(data, prefix)
+	if script := h.opts.ClientConfig.pageScript(prefix); script != "" {
+		data = injectScript(data, script)
+	}
+	if h.opts.PageHeaders != nil {
+		h.opts.PageHeaders(w.Header(), r, inlineScriptHashes(data))
+	}
+	sum := sha256.Sum256(data)
+	setContentType(w, fullPath)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	http.ServeContent(w, r, fullPath, time.Time{}, bytes.NewReader(data))
 }
 
 // serveIndexHTML 服务外部目录的 index.html 文件
@@ -176,96 +147,63 @@ func (h *externalSpaHandler) serveIndexHTML(w http.ResponseWriter, r *http.Reque
 		http.NotFound(w, r)
 		return
 	}
-	
-	http.ServeFile(w, r, indexPath)
+
+	h.serveFile(w, r, indexPath)
 }
 
-// spaHandler SPA 应用处理器
+// spaHandler SPA 应用处理器，服务内嵌的前端文件
 type spaHandler struct {
-	fs fs.FS
+	assets *assetStore
+	opts   FrontendOptions
 }
 
 func (h *spaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// 清理路径
-	upath := strings.TrimPrefix(r.URL.Path, "/")
+	upath := strings.Trim(path.Clean("/"+r.URL.Path), "/")
 	if upath == "" {
 		upath = "index.html"
 	}
 
-	// 尝试打开请求的文件
-	file, err := h.fs.Open(upath)
-	if err != nil {
+	// 请求的文件，或目录下的 index.html
+	a := h.assets.lookup(upath)
+	if a == nil {
+		a = h.assets.lookup(path.Join(upath, "index.html"))
+	}
+	if a == nil {
+		// 构建产物缺失时返回 404，避免浏览器把 index.html 当作脚本或样式
+		if strings.HasPrefix(upath, "_next/") {
+			http.NotFound(w, r)
+			return
+		}
 		// 文件不存在，对于 SPA 应用返回 index.html
-		h.serveIndexHTML(w, r)
-		return
+		a = h.assets.lookup("index.html")
 	}
-	defer file.Close()
 
-	// 获取文件信息
-	stat, err := file.Stat()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if !a.html {
+		h.assets.serve(w, r, a)
 		return
 	}
 
-	// 如果是目录，查找 index.html
-	if stat.IsDir() {
-		indexPath := path.Join(upath, "index.html")
-		indexFile, err := h.fs.Open(indexPath)
+	// HTML 页面：加上路径前缀、注入前端配置，设置 CSP 等页面相关的响应头
+	scriptHashes := a.scriptHashes
+	var page *renderedPage
+	prefix := api.BasePath(r)
+	if script := h.opts.ClientConfig.pageScript(prefix); script != "" || prefix != "" {
+		p, err := h.assets.renderPage(a, script, prefix)
 		if err != nil {
-			h.serveIndexHTML(w, r)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		defer indexFile.Close()
-
-		h.serveFile(w, r, "index.html", indexFile)
-		return
+		page, scriptHashes = p, p.scriptHashes
 	}
-
-	// 服务静态文件
-	h.serveFile(w, r, stat.Name(), file)
-}
-
-// serveIndexHTML 服务 index.html 文件
-func (h *spaHandler) serveIndexHTML(w http.ResponseWriter, r *http.Request) {
-	file, err := h.fs.Open("index.html")
-	if err != nil {
-		http.NotFound(w, r)
-		return
+	if h.opts.PageHeaders != nil {
+		h.opts.PageHeaders(w.Header(), r, scriptHashes)
 	}
-	defer file.Close()
-
-	h.serveFile(w, r, "index.html", file)
-}
-
-// serveFile 服务文件
-func (h *spaHandler) serveFile(w http.ResponseWriter, r *http.Request, filename string, file fs.File) {
-	// 设置 Content-Type
-	setContentType(w, filename)
-
-	// 获取文件信息
-	stat, err := file.Stat()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// 读取文件内容
-	content, err := io.ReadAll(file)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if page != nil {
+		h.assets.servePage(w, r, a, page)
 		return
 	}
-
-	// 设置缓存头
-	if shouldCache(filename) {
-		w.Header().Set("Cache-Control", "public, max-age=31536000") // 1年
-	} else {
-		w.Header().Set("Cache-Control", "no-cache")
-	}
-
-	// 服务内容
-	http.ServeContent(w, r, filename, stat.ModTime(), strings.NewReader(string(content)))
+	h.assets.serve(w, r, a)
 }
 
 // setContentType 设置 Content-Type
@@ -302,14 +240,3 @@ func setContentType(w http.ResponseWriter, filename string) {
 		w.Header().Set("Content-Type", "application/octet-stream")
 	}
 }
-
-// shouldCache 判断文件是否应该被缓存
-func shouldCache(filename string) bool {
-	ext := path.Ext(filename)
-	switch ext {
-	case ".css", ".js", ".png", ".jpg", ".jpeg", ".gif", ".svg", ".ico", ".woff", ".woff2", ".ttf":
-		return true
-	default:
-		return false
-	}
-}
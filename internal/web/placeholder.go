@@ -0,0 +1,111 @@
+package web
+
+import (
+	"html/template"
+	"net/http"
+
+	"chuan/internal/i18n"
+	"chuan/internal/logging"
+)
+
+// placeholderHandler 占位处理器，未构建前端时显示，按 lang 参数或 Accept-Language 选择中文或英文
+type placeholderHandler struct {
+	pageHeaders PageHeaderFunc
+}
+
+// placeholderPage 占位页面的模板数据，模板中通过 {{.T "键"}} 取本地化文本
+type placeholderPage struct {
+	Lang string
+}
+
+func (p placeholderPage) T(key string) string {
+	return i18n.T(p.Lang, key)
+}
+
+func (h *placeholderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	page := placeholderPage{Lang: i18n.Negotiate(r)}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Language", page.Lang)
+	w.Header().Add("Vary", "Accept-Language")
+	if h.pageHeaders != nil {
+		h.pageHeaders(w.Header(), r, nil) // 占位页面只有内联样式，没有内联脚本
+	}
+	w.WriteHeader(http.StatusOK)
+	if err := placeholderTemplate.Execute(w, page); err != nil {
+		logging.Errorf("渲染占位页面失败: %v", err)
+	}
+}
+
+var placeholderTemplate = template.Must(template.New("placeholder").Parse(`
+<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+    <title>{{.T "page.title"}}</title>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <style>
+        body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; margin: 0; padding: 20px; background: #f5f5f5; }
+        .container { max-width: 800px; margin: 0 auto; background: white; padding: 40px; border-radius: 8px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); }
+        h1 { color: #333; margin-bottom: 20px; }
+        .lang { float: right; font-size: 14px; }
+        .status { padding: 15px; background: #fff3cd; border: 1px solid #ffeaa7; border-radius: 4px; margin: 20px 0; }
+        .commands { background: #f8f9fa; padding: 15px; border-radius: 4px; margin: 20px 0; }
+        pre { margin: 0; overflow-x: auto; }
+        .api-list { margin: 20px 0; }
+        .api-item { margin: 10px 0; padding: 10px; background: #e3f2fd; border-radius: 4px; }
+        .env-config { background: #e8f5e8; padding: 15px; border-radius: 4px; margin: 20px 0; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="lang"><a href="?lang=zh-CN">中文</a> | <a href="?lang=en">English</a></div>
+        <h1>🚀 {{.T "page.title"}}</h1>
+        
+        <div class="status">
+            ⚠️ {{.T "page.not_built"}}
+        </div>
+        
+        <h2>🔧 {{.T "page.env_heading"}}</h2>
+        <div class="env-config">
+            <strong>FRONTEND_DIR</strong> - {{.T "page.env_frontend_dir"}}<br>
+            <strong>PORT</strong> - {{.T "page.env_port"}}<br><br>
+            <strong>{{.T "page.example"}}</strong><br>
+            <pre>export FRONTEND_DIR=/path/to/frontend
+export PORT=3000
+./file-transfer-server</pre>
+        </div>
+        
+        <h2>📋 {{.T "page.api_heading"}}</h2>
+        <div class="api-list">
+            <div class="api-item"><strong>POST</strong> /api/create-room - {{.T "page.api_create_room"}}</div>
+            <div class="api-item"><strong>GET</strong> /api/room-info - {{.T "page.api_room_info"}}</div>
+            <div class="api-item"><strong>GET</strong> /api/rooms/{code}/events - {{.T "page.api_room_events"}} (SSE / WebSocket)</div>
+            <div class="api-item"><strong>GET</strong> /api/openapi.json - {{.T "page.api_openapi"}}</div>
+            <div class="api-item"><strong>WebSocket</strong> /api/ws/webrtc - {{.T "page.api_signal"}}</div>
+        </div>
+        
+        <h2>🛠️ {{.T "page.build_heading"}}</h2>
+        <div class="commands">
+            <pre># {{.T "page.build_enter_dir"}}
+cd chuan-next
+
+# {{.T "page.build_install"}}
+npm install
+
+# {{.T "page.build_static"}}
+npm run build
+
+# {{.T "page.build_embed"}}
+cd ..
+go build -o file-transfer-server ./cmd
+
+# {{.T "page.build_external"}}
+export FRONTEND_DIR=./chuan-next/out
+./file-transfer-server</pre>
+        </div>
+        
+        <p><strong>{{.T "page.hint_label"}}</strong> {{.T "page.hint"}}</p>
+    </div>
+</body>
+</html>
+`))
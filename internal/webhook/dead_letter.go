@@ -0,0 +1,75 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// deadLetterRecord 死信文件中的一行，payload 为原始请求体，可据此手动重放
+type deadLetterRecord struct {
+	Time     time.Time       `json:"time"`
+	URL      string          `json:"url"`
+	Event    string          `json:"event"`
+	Delivery string          `json:"delivery"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+// deadLetter 追加写入的死信文件，未配置路径时不写入
+type deadLetter struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func openDeadLetter(path string) (*deadLetter, error) {
+	if path == "" {
+		return &deadLetter{}, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("创建 webhook 死信目录失败: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("打开 webhook 死信文件失败: %w", err)
+	}
+	return &deadLetter{file: file}, nil
+}
+
+func (dl *deadLetter) write(url string, item delivery, attempts int, reason string) error {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	if dl.file == nil {
+		return nil
+	}
+
+	line, err := json.Marshal(deadLetterRecord{
+		Time:     time.Now(),
+		URL:      url,
+		Event:    item.eventType,
+		Delivery: item.id,
+		Attempts: attempts,
+		Error:    reason,
+		Payload:  item.body,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = dl.file.Write(append(line, '\n'))
+	return err
+}
+
+func (dl *deadLetter) close() error {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	if dl.file == nil {
+		return nil
+	}
+	err := dl.file.Close()
+	dl.file = nil
+	return err
+}
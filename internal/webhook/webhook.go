@@ -0,0 +1,330 @@
+// Package webhook 把房间事件以 HMAC-SHA256 签名的 JSON 推送到外部地址
+// 投递失败时按指数退避重试，重试耗尽或无法重试的事件写入死信文件
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"chuan/internal/events"
+	"chuan/internal/logging"
+)
+
+// 请求头
+const (
+	EventHeader     = "X-Chuan-Event"     // 事件类型
+	DeliveryHeader  = "X-Chuan-Delivery"  // 投递 ID，重试时不变，接收方可据此去重
+	TimestampHeader = "X-Chuan-Timestamp" // 签名时的 Unix 时间戳（秒）
+	SignatureHeader = "X-Chuan-Signature" // sha256=<hex(HMAC-SHA256(secret, 时间戳 + "." + 请求体))>
+)
+
+const (
+	queueSize      = 1024              // 每个地址的待投递队列容量
+	initialBackoff = time.Second       // 第一次重试前的等待时间，之后每次翻倍
+	maxBackoff     = time.Minute       // 重试等待时间上限
+	drainTimeout   = 5 * time.Second   // 关闭时等待队列投递完成的最长时间
+	maxReadBody    = 64 << 10          // 读取响应体的上限，只用于复用连接
+	userAgent      = "chuan-webhook/1" // 请求的 User-Agent
+)
+
+// Options webhook 配置
+type Options struct {
+	URLs        []string      // 接收事件的地址，每个地址都会收到所有订阅的事件
+	Secret      string        // HMAC-SHA256 签名密钥，为空时不签名
+	Events      []string      // 订阅的事件类型
+	Timeout     time.Duration // 单次请求超时
+	MaxAttempts int           // 每个事件最多投递次数（含第一次）
+	DeadLetter  string        // 死信文件 (JSONL)，为空时只记录错误日志
+
+	// 事件发往第三方服务，默认不包含身份和客户端 IP
+	IncludeIdentity   bool // 是否包含创建者/操作者身份（identity 和 data.owner）
+	IncludeRemoteAddr bool // 是否包含客户端 IP（remote_addr）
+}
+
+// Payload 请求体：投递 ID 加上事件中可以发往第三方的字段
+type Payload struct {
+	ID         string                 `json:"id"`
+	Time       time.Time              `json:"time"`
+	Type       string                 `json:"type"`
+	Room       string                 `json:"room,omitempty"`
+	Role       string                 `json:"role,omitempty"`
+	ClientID   string                 `json:"client_id,omitempty"`
+	Identity   string                 `json:"identity,omitempty"`    // 仅在 IncludeIdentity 时填写
+	RemoteAddr string                 `json:"remote_addr,omitempty"` // 仅在 IncludeRemoteAddr 时填写
+	Data       map[string]interface{} `json:"data,omitempty"`
+}
+
+// identityDataKeys 事件 data 中表示身份的字段，未启用 IncludeIdentity 时删除
+var identityDataKeys = []string{"owner"}
+
+// newPayload 按配置从事件中挑选字段，事件由所有订阅者共享，data 需要复制后再修改
+func newPayload(id string, e events.Event, opts Options) Payload {
+	p := Payload{
+		ID:       id,
+		Time:     e.Time,
+		Type:     e.Type,
+		Room:     e.Room,
+		Role:     e.Role,
+		ClientID: e.ClientID,
+		Data:     e.Data,
+	}
+	if opts.IncludeRemoteAddr {
+		p.RemoteAddr = e.RemoteAddr
+	}
+	if opts.IncludeIdentity {
+		p.Identity = e.Identity
+		return p
+	}
+	if len(e.Data) > 0 {
+		p.Data = make(map[string]interface{}, len(e.Data))
+		for k, v := range e.Data {
+			p.Data[k] = v
+		}
+		for _, k := range identityDataKeys {
+			delete(p.Data, k)
+		}
+	}
+	return p
+}
+
+// Sign 计算请求签名，接收方用同样的方式计算并与 X-Chuan-Signature 比较
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// delivery 一个待投递的事件
+type delivery struct {
+	id        string
+	eventType string
+	body      []byte
+}
+
+// endpoint 一个接收地址，事件按发生顺序逐个投递
+type endpoint struct {
+	url   string
+	name  string // 日志中显示的地址，只保留协议和主机，路径中可能包含令牌
+	queue chan delivery
+}
+
+// redactURL 返回地址的协议和主机部分
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "(无效地址)"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// Dispatcher 订阅事件总线并异步投递 webhook
+type Dispatcher struct {
+	opts       Options
+	events     map[string]bool
+	client     *http.Client
+	endpoints  []*endpoint
+	deadLetter *deadLetter
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex // 保护 closed，避免向已关闭的队列发送
+	closed bool
+}
+
+// New 创建投递器并为每个地址启动投递协程
+func New(opts Options) (*Dispatcher, error) {
+	dl, err := openDeadLetter(opts.DeadLetter)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		opts:       opts,
+		events:     make(map[string]bool),
+		client:     &http.Client{Timeout: opts.Timeout},
+		deadLetter: dl,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	for _, eventType := range opts.Events {
+		d.events[eventType] = true
+	}
+	for _, raw := range opts.URLs {
+		ep := &endpoint{url: raw, name: redactURL(raw), queue: make(chan delivery, queueSize)}
+		d.endpoints = append(d.endpoints, ep)
+		d.wg.Add(1)
+		go d.run(ep)
+	}
+	return d, nil
+}
+
+// Handle 把订阅的事件放入各地址的投递队列，不阻塞调用方；队列已满时直接写入死信
+func (d *Dispatcher) Handle(e events.Event) {
+	if !d.events[e.Type] {
+		return
+	}
+
+	payload := newPayload(newDeliveryID(), e, d.opts)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logging.Errorf("❌ 序列化 webhook 事件失败: %s: %v", e.Type, err)
+		return
+	}
+	item := delivery{id: payload.ID, eventType: e.Type, body: body}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return
+	}
+	for _, ep := range d.endpoints {
+		select {
+		case ep.queue <- item:
+		default:
+			d.fail(ep, item, 0, "投递队列已满")
+		}
+	}
+}
+
+// Close 停止接收新事件，等待队列中的事件投递完成；超时后未投递的事件写入死信
+func (d *Dispatcher) Close() error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	for _, ep := range d.endpoints {
+		close(ep.queue)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(drainTimeout):
+		d.cancel()
+		<-done
+	}
+	d.cancel()
+	return d.deadLetter.close()
+}
+
+func (d *Dispatcher) run(ep *endpoint) {
+	defer d.wg.Done()
+	for item := range ep.queue {
+		d.deliver(ep, item)
+	}
+}
+
+// deliver 投递一个事件，可重试的失败按指数退避重试
+func (d *Dispatcher) deliver(ep *endpoint, item delivery) {
+	for attempt := 1; ; attempt++ {
+		if d.ctx.Err() != nil {
+			d.fail(ep, item, attempt-1, "服务关闭，未完成投递")
+			return
+		}
+
+		retry, err := d.post(ep.url, item)
+		if err == nil {
+			logging.Debugf("📮 webhook 投递成功: %s → %s (第 %d 次)", item.eventType, ep.name, attempt)
+			return
+		}
+		if !retry || attempt >= d.opts.MaxAttempts {
+			d.fail(ep, item, attempt, err.Error())
+			return
+		}
+
+		wait := backoff(attempt)
+		logging.Warnf("⚠️ webhook 投递失败，%v 后重试: %s → %s (第 %d 次): %v", wait.Round(time.Millisecond), item.eventType, ep.name, attempt, err)
+		select {
+		case <-time.After(wait):
+		case <-d.ctx.Done():
+		}
+	}
+}
+
+// post 发送一次请求，返回失败是否值得重试（网络错误、429 和 5xx）
+func (d *Dispatcher) post(target string, item delivery) (bool, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, target, bytes.NewReader(item.body))
+	if err != nil {
+		return false, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(EventHeader, item.eventType)
+	req.Header.Set(DeliveryHeader, item.id)
+	req.Header.Set(TimestampHeader, timestamp)
+	if d.opts.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(d.opts.Secret, timestamp, item.body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		// url.Error 的消息包含完整地址，只保留操作和原因
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = fmt.Errorf("%s: %w", urlErr.Op, urlErr.Err)
+		}
+		return true, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxReadBody))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("HTTP %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+}
+
+// fail 记录投递失败的事件
+func (d *Dispatcher) fail(ep *endpoint, item delivery, attempts int, reason string) {
+	logging.Errorf("❌ webhook 投递失败，已放弃: %s → %s (尝试 %d 次): %s", item.eventType, ep.name, attempts, reason)
+	if err := d.deadLetter.write(ep.url, item, attempts, reason); err != nil {
+		logging.Errorf("❌ 写入 webhook 死信失败: %v", err)
+	}
+}
+
+// backoff 返回第 attempt 次失败后的等待时间：指数增长并加入最多 20% 的随机抖动，避免多个事件同时重试
+func backoff(attempt int) time.Duration {
+	wait := maxBackoff
+	if shift := attempt - 1; shift < 16 {
+		wait = min(initialBackoff<<shift, maxBackoff)
+	}
+	return wait + mathrand.N(wait/5+1)
+}
+
+// newDeliveryID 生成随机投递 ID
+func newDeliveryID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
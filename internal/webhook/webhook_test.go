@@ -0,0 +1,298 @@
+package webhook
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"chuan/internal/events"
+)
+
+// received 接收方收到的一次请求
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver 按 statuses 依次返回状态码（用完后重复最后一个）的 webhook 接收方
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []received
+	statuses []int
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	t.Helper()
+	rc := &receiver{statuses: statuses}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rc.mu.Lock()
+		rc.requests = append(rc.requests, received{header: r.Header.Clone(), body: body})
+		status := rc.statuses[min(len(rc.requests), len(rc.statuses))-1]
+		rc.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func (rc *receiver) received() []received {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]received{}, rc.requests...)
+}
+
+func newTestDispatcher(t *testing.T, opts Options) *Dispatcher {
+	t.Helper()
+	if opts.Events == nil {
+		opts.Events = []string{events.RoomCreated}
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.MaxAttempts == 0 {
+		opts.MaxAttempts = 1
+	}
+	d, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func testEvent() events.Event {
+	return events.Event{
+		Time:       time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Type:       events.RoomCreated,
+		Room:       "ABC123",
+		Identity:   "alice@example.com",
+		ClientID:   "client_1",
+		RemoteAddr: "203.0.113.7",
+		Data:       map[string]interface{}{"owner": "alice@example.com", "ttl_seconds": 3600},
+	}
+}
+
+// TestSignature 接收方用相同的密钥和时间戳请求头可以验证签名
+func TestSignature(t *testing.T) {
+	rc := newReceiver(t, http.StatusOK)
+	d := newTestDispatcher(t, Options{URLs: []string{rc.URL}, Secret: "s3cret"})
+	d.Handle(testEvent())
+	d.Close()
+
+	reqs := rc.received()
+	if len(reqs) != 1 {
+		t.Fatalf("期望 1 次请求, 实际 %d", len(reqs))
+	}
+	h := reqs[0].header
+	if h.Get(EventHeader) != events.RoomCreated || h.Get(DeliveryHeader) == "" {
+		t.Fatalf("请求头不完整: %v", h)
+	}
+	want := Sign("s3cret", h.Get(TimestampHeader), reqs[0].body)
+	if got := h.Get(SignatureHeader); got != want {
+		t.Fatalf("签名 = %q, 期望 %q", got, want)
+	}
+	if Sign("other", h.Get(TimestampHeader), reqs[0].body) == want {
+		t.Fatal("不同密钥的签名不应相同")
+	}
+	if Sign("s3cret", h.Get(TimestampHeader), append(reqs[0].body, ' ')) == want {
+		t.Fatal("请求体被修改后签名不应相同")
+	}
+}
+
+// TestNoSecretNoSignature 未配置密钥时不发送签名请求头
+func TestNoSecretNoSignature(t *testing.T) {
+	rc := newReceiver(t, http.StatusOK)
+	d := newTestDispatcher(t, Options{URLs: []string{rc.URL}})
+	d.Handle(testEvent())
+	d.Close()
+
+	reqs := rc.received()
+	if len(reqs) != 1 || reqs[0].header.Get(SignatureHeader) != "" {
+		t.Fatalf("未配置密钥时不应签名: %v", reqs)
+	}
+}
+
+// TestPayloadPrivacy 默认不向第三方发送身份和客户端 IP，显式开启后才包含
+func TestPayloadPrivacy(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         Options
+		wantIdentity bool
+		wantAddr     bool
+	}{
+		{"默认", Options{}, false, false},
+		{"包含身份", Options{IncludeIdentity: true}, true, false},
+		{"包含 IP", Options{IncludeRemoteAddr: true}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := newReceiver(t, http.StatusOK)
+			tt.opts.URLs = []string{rc.URL}
+			d := newTestDispatcher(t, tt.opts)
+			e := testEvent()
+			d.Handle(e)
+			d.Close()
+
+			if _, ok := e.Data["owner"]; !ok {
+				t.Fatal("不应修改其他订阅者共享的事件")
+			}
+			reqs := rc.received()
+			if len(reqs) != 1 {
+				t.Fatalf("期望 1 次请求, 实际 %d", len(reqs))
+			}
+			var body map[string]interface{}
+			if err := json.Unmarshal(reqs[0].body, &body); err != nil {
+				t.Fatal(err)
+			}
+			if body["room"] != "ABC123" || body["type"] != events.RoomCreated || body["id"] != reqs[0].header.Get(DeliveryHeader) {
+				t.Fatalf("请求体缺少事件字段: %s", reqs[0].body)
+			}
+			data, _ := body["data"].(map[string]interface{})
+			_, hasOwner := data["owner"]
+			if _, ok := body["identity"]; ok != tt.wantIdentity || hasOwner != tt.wantIdentity {
+				t.Errorf("identity/owner 是否出现 = %v/%v, 期望 %v: %s", ok, hasOwner, tt.wantIdentity, reqs[0].body)
+			}
+			if _, ok := body["remote_addr"]; ok != tt.wantAddr {
+				t.Errorf("remote_addr 是否出现 = %v, 期望 %v: %s", ok, tt.wantAddr, reqs[0].body)
+			}
+			if data["ttl_seconds"] == nil {
+				t.Errorf("其他 data 字段应保留: %s", reqs[0].body)
+			}
+		})
+	}
+}
+
+// TestUnsubscribedEvent 未订阅的事件不投递
+func TestUnsubscribedEvent(t *testing.T) {
+	rc := newReceiver(t, http.StatusOK)
+	d := newTestDispatcher(t, Options{URLs: []string{rc.URL}})
+	e := testEvent()
+	e.Type = events.RoomExpired
+	d.Handle(e)
+	d.Close()
+
+	if n := len(rc.received()); n != 0 {
+		t.Fatalf("未订阅的事件不应投递, 实际 %d 次请求", n)
+	}
+}
+
+// TestRetry 5xx 后按退避重试，重试使用相同的投递 ID，成功后不写死信
+func TestRetry(t *testing.T) {
+	rc := newReceiver(t, http.StatusServiceUnavailable, http.StatusOK)
+	deadLetter := filepath.Join(t.TempDir(), "dead.jsonl")
+	d := newTestDispatcher(t, Options{URLs: []string{rc.URL}, MaxAttempts: 3, DeadLetter: deadLetter})
+	start := time.Now()
+	d.Handle(testEvent())
+	d.Close()
+
+	reqs := rc.received()
+	if len(reqs) != 2 {
+		t.Fatalf("期望 2 次请求, 实际 %d", len(reqs))
+	}
+	if elapsed := time.Since(start); elapsed < initialBackoff {
+		t.Errorf("重试前应等待至少 %v, 实际 %v", initialBackoff, elapsed)
+	}
+	if reqs[0].header.Get(DeliveryHeader) != reqs[1].header.Get(DeliveryHeader) {
+		t.Error("重试时投递 ID 应保持不变")
+	}
+	if records := readDeadLetter(t, deadLetter); len(records) != 0 {
+		t.Fatalf("投递成功后不应写入死信: %+v", records)
+	}
+}
+
+// TestDeadLetter 不可重试的状态码和重试耗尽的事件写入死信，payload 为原始请求体
+func TestDeadLetter(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		maxAttempts  int
+		wantAttempts int
+	}{
+		{"4xx 不重试", http.StatusBadRequest, 3, 1},
+		{"5xx 重试耗尽", http.StatusInternalServerError, 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := newReceiver(t, tt.status)
+			deadLetter := filepath.Join(t.TempDir(), "dead.jsonl")
+			d := newTestDispatcher(t, Options{URLs: []string{rc.URL}, MaxAttempts: tt.maxAttempts, DeadLetter: deadLetter})
+			d.Handle(testEvent())
+			d.Close()
+
+			reqs := rc.received()
+			if len(reqs) != tt.wantAttempts {
+				t.Fatalf("期望 %d 次请求, 实际 %d", tt.wantAttempts, len(reqs))
+			}
+			records := readDeadLetter(t, deadLetter)
+			if len(records) != 1 {
+				t.Fatalf("期望 1 条死信, 实际 %d", len(records))
+			}
+			r := records[0]
+			if r.URL != rc.URL || r.Event != events.RoomCreated || r.Attempts != tt.wantAttempts ||
+				r.Delivery != reqs[0].header.Get(DeliveryHeader) {
+				t.Fatalf("死信记录不正确: %+v", r)
+			}
+			if string(r.Payload) != string(reqs[0].body) {
+				t.Fatalf("死信 payload 应为原始请求体:\n%s\n%s", r.Payload, reqs[0].body)
+			}
+		})
+	}
+}
+
+// TestBackoff 等待时间按指数增长，加入不超过 20% 的抖动，且不超过上限
+func TestBackoff(t *testing.T) {
+	for attempt := 1; attempt <= 40; attempt++ {
+		base := maxBackoff
+		if attempt <= 16 {
+			base = min(initialBackoff<<(attempt-1), maxBackoff)
+		}
+		for range 20 {
+			wait := backoff(attempt)
+			if wait < base || wait > base+base/5+1 {
+				t.Fatalf("backoff(%d) = %v, 期望在 [%v, %v] 之间", attempt, wait, base, base+base/5)
+			}
+		}
+	}
+}
+
+func readDeadLetter(t *testing.T, path string) []deadLetterRecord {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var records []deadLetterRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var r deadLetterRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+// TestRedactURL 日志中的地址只保留协议和主机，路径中的令牌不会输出
+func TestRedactURL(t *testing.T) {
+	tests := map[string]string{
+		"https://hooks.slack.com/services/T000/B000/XXXX": "https://hooks.slack.com",
+		"https://discord.com/api/webhooks/1/TOKEN?wait=1": "https://discord.com",
+		"http://127.0.0.1:9000/hook":                      "http://127.0.0.1:9000",
+		"://bad":                                          "(无效地址)",
+	}
+	for raw, want := range tests {
+		if got := redactURL(raw); got != want {
+			t.Errorf("redactURL(%q) = %q, 期望 %q", raw, got, want)
+		}
+	}
+}